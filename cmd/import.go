@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/krevetkou/test-rutube/internal/config"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"github.com/krevetkou/test-rutube/internal/importer"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const importUsage = `usage: main import [flags] FILE
+
+Uploads employees from a CSV or JSON file to a running server.
+The token is a personal access token with the admin scope of an HR or admin user.
+
+`
+
+// runImport is the import subcommand, it returns the exit code.
+func runImport(args []string) int {
+	cfg := config.Load()
+
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), importUsage)
+		flags.PrintDefaults()
+	}
+	server := flags.String("url", cfg.BaseURL, "server address")
+	token := flags.String("token", os.Getenv("APP_API_TOKEN"), "personal access token, APP_API_TOKEN by default")
+	format := flags.String("format", "", "csv or json, guessed from the file name by default")
+	dryRun := flags.Bool("dry-run", false, "only validate the file and show what would change")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 || *token == "" {
+		flags.Usage()
+		return 2
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = importer.FormatOf(path)
+	}
+	if *format != importer.FormatCSV && *format != importer.FormatJSON {
+		fmt.Fprintln(os.Stderr, "unknown format, use -format csv or -format json")
+		return 2
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer file.Close()
+
+	endpoint := strings.TrimSuffix(*server, "/") + "/admin/users/import?" + url.Values{
+		"dryRun": {fmt.Sprint(*dryRun)},
+	}.Encode()
+	req, err := http.NewRequest(http.MethodPost, endpoint, file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	req.Header.Set("Authorization", "Bearer "+*token)
+	req.Header.Set("Content-Type", map[string]string{
+		importer.FormatCSV:  "text/csv",
+		importer.FormatJSON: "application/json",
+	}[*format])
+
+	client := http.Client{Timeout: time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "%s: %s", resp.Status, body)
+		return 1
+	}
+
+	var result domain.ImportResult
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	for _, rowErr := range result.Errors {
+		fmt.Printf("row %d %s: %s\n", rowErr.Row, rowErr.Email, rowErr.Error)
+	}
+	if result.DryRun {
+		fmt.Print("dry run, nothing is saved: ")
+	}
+	fmt.Printf("created %d, updated %d, skipped %d\n", result.Created, result.Updated, result.Skipped)
+
+	if len(result.Errors) > 0 {
+		return 1
+	}
+
+	return 0
+}
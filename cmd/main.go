@@ -1,40 +1,216 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"github.com/bxcodec/faker/v3"
 	"github.com/go-chi/chi/v5"
 	"github.com/krevetkou/test-rutube/internal/api"
+	"github.com/krevetkou/test-rutube/internal/auth"
+	"github.com/krevetkou/test-rutube/internal/config"
+	"github.com/krevetkou/test-rutube/internal/directory"
 	"github.com/krevetkou/test-rutube/internal/domain"
+	"github.com/krevetkou/test-rutube/internal/events"
+	"github.com/krevetkou/test-rutube/internal/mailer"
+	"github.com/krevetkou/test-rutube/internal/oidc"
 	"github.com/krevetkou/test-rutube/internal/services"
 	"github.com/krevetkou/test-rutube/internal/storage"
+	"github.com/krevetkou/test-rutube/internal/telegram"
 	"github.com/rs/cors"
 	"log"
 	"math/rand/v2"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(os.Args[2:]))
+	}
+
+	cfg := config.Load()
+
+	passwords, err := services.LoadPasswordPolicy(cfg.PasswordMinLength, cfg.BreachedPasswordsFile)
+	if err != nil {
+		log.Fatalf("load password policy: %s", err)
+	}
+
+	if cfg.TokenSecret == "" {
+		// a random secret keeps tokens from being forged, they don't survive a restart
+		cfg.TokenSecret, _, err = auth.RandomToken()
+		if err != nil {
+			log.Fatalf("generate token secret: %s", err)
+		}
+		log.Println("APP_TOKEN_SECRET is not set, using a random secret")
+	}
+
 	usersStorage := storage.NewStorage()
-	userService := services.NewUserService(usersStorage)
+	eventsHub := events.NewHub(cfg.EventsHistory)
+	appMailer := newMailer(cfg)
+
+	var bot services.BotAPI
+	if cfg.BotToken != "" {
+		bot = telegram.NewClient(cfg.BotAPIURL, cfg.BotToken)
+	}
+
+	notificationsService := services.NewNotificationsService(usersStorage, appMailer, eventsHub, bot)
+	followRequestsService := services.NewFollowRequestsService(usersStorage, appMailer, eventsHub, notificationsService, cfg)
+	userService := services.NewUserService(usersStorage, appMailer, auth.NewSigner(cfg.TokenSecret), passwords, eventsHub,
+		notificationsService, followRequestsService, cfg)
+	botService := services.NewBotService(usersStorage, userService, bot)
+
 	userHandler := api.NewUsersHandler(userService)
+	notificationsHandler := api.NewNotificationsHandler(notificationsService)
+	followRequestsHandler := api.NewFollowRequestsHandler(followRequestsService)
+	botHandler := api.NewBotHandler(botService)
+	greetingsHandler := api.NewGreetingsHandler(services.NewGreetingsService(usersStorage, appMailer, eventsHub, notificationsService, cfg))
+	fundsHandler := api.NewFundsHandler(services.NewFundsService(usersStorage, appMailer))
+	wishlistHandler := api.NewWishlistHandler(services.NewWishlistService(usersStorage))
+	eventsHandler := api.NewEventsHandler(userHandler, cfg.EventsHeartbeat)
+	dashboardHandler := api.NewDashboardHandler(userHandler, cfg.TimeZone, strings.Fields(cfg.DashboardOrigins))
 
 	insertUsers(usersStorage)
 
+	err = userService.BootstrapAdmin(cfg.AdminEmail, cfg.AdminPassword)
+	if err != nil {
+		log.Fatalf("bootstrap admin: %s", err)
+	}
+
+	go notificationsService.StartReminders(context.Background(), cfg.ReminderInterval)
+	if bot != nil {
+		go botService.StartBot(context.Background())
+	}
+
+	directorySync := newDirectorySync(cfg, userService)
+	if directorySync != nil {
+		go directorySync.Start(context.Background(), cfg.DirectorySyncInterval)
+	}
+
 	r := chi.NewRouter()
 	r.Route("/user", func(r chi.Router) {
-		r.Get("/list-today", userHandler.ListToday)
-		r.Get("/list", userHandler.List)
 		r.Get("/info", userHandler.GetUserInfo)
+		r.Post("/register", userHandler.Register)
+		r.Get("/verify", userHandler.Verify)
+		r.Post("/verify/resend", userHandler.ResendVerification)
 		r.Post("/login", userHandler.Login)
-		r.Post("/subscribe", userHandler.Subscribe)
-		r.Post("/unsubscribe", userHandler.Unsubscribe)
+		r.Post("/login/2fa", userHandler.LoginTwoFactor)
+		r.Post("/2fa/enroll", userHandler.EnrollTwoFactor)
+		r.Post("/2fa/confirm", userHandler.ConfirmTwoFactor)
+		r.Post("/2fa/disable", userHandler.DisableTwoFactor)
+		r.Post("/password", userHandler.ChangePassword)
+		r.Post("/password/forgot", userHandler.ForgotPassword)
+		r.Post("/password/reset", userHandler.ResetPassword)
 		r.Post("/settings", userHandler.Settings)
+		r.Patch("/settings", userHandler.Settings)
+		r.Post("/followers/remove", userHandler.RemoveFollower)
+		r.Get("/blocked", userHandler.Blocked)
+		r.Post("/block", userHandler.Block)
+		r.Post("/unblock", userHandler.Unblock)
+		r.Get("/follow-requests/incoming", followRequestsHandler.IncomingFollowRequests)
+		r.Get("/follow-requests/outgoing", followRequestsHandler.OutgoingFollowRequests)
+		r.Post("/follow-requests/{id:[0-9]+}/approve", followRequestsHandler.ApproveFollowRequest)
+		r.Post("/follow-requests/{id:[0-9]+}/reject", followRequestsHandler.RejectFollowRequest)
+		r.Delete("/follow-requests/{id:[0-9]+}", followRequestsHandler.CancelFollowRequest)
+		r.Get("/notifications", notificationsHandler.Notifications)
+		r.Get("/notifications/unread-count", notificationsHandler.UnreadCount)
+		r.Post("/notifications/{id:[0-9]+}/read", notificationsHandler.MarkNotificationRead)
+		r.Post("/notifications/read-all", notificationsHandler.MarkAllNotificationsRead)
+		r.Get("/events", eventsHandler.Stream)
+		r.Get("/dashboard", dashboardHandler.Connect)
+		r.Get("/bot", botHandler.BotStatus)
+		r.Post("/bot/link", botHandler.CreateBotLink)
+		r.Delete("/bot", botHandler.UnlinkBot)
+		r.Get("/email/confirm", userHandler.ConfirmEmail)
+		r.Get("/email/revert", userHandler.RevertEmail)
+		r.Get("/tokens", userHandler.PersonalTokens)
+		r.Post("/tokens", userHandler.CreatePersonalToken)
+		r.Delete("/tokens/{id:[0-9]+}", userHandler.RevokePersonalToken)
+		r.Post("/{id:[0-9]+}/greetings", greetingsHandler.PostGreeting)
+		r.Patch("/greetings/{id:[0-9]+}", greetingsHandler.UpdateGreeting)
+		r.Delete("/greetings/{id:[0-9]+}", greetingsHandler.DeleteGreeting)
+		r.Get("/funds", fundsHandler.Funds)
+		r.Post("/funds", fundsHandler.CreateFund)
+		r.Get("/funds/{id:[0-9]+}", fundsHandler.Fund)
+		r.Delete("/funds/{id:[0-9]+}", fundsHandler.DeleteFund)
+		r.Post("/funds/{id:[0-9]+}/invite", fundsHandler.InviteFollowers)
+		r.Patch("/funds/{id:[0-9]+}/pledges/{userId:[0-9]+}", fundsHandler.SetPledge)
+		r.Get("/wishlist", wishlistHandler.Wishlist)
+		r.Post("/wishlist", wishlistHandler.AddWishItem)
+		r.Patch("/wishlist/{id:[0-9]+}", wishlistHandler.UpdateWishItem)
+		r.Delete("/wishlist/{id:[0-9]+}", wishlistHandler.DeleteWishItem)
+		r.Post("/{id:[0-9]+}/wishlist/{itemId:[0-9]+}/reserve", wishlistHandler.ReserveWishItem)
+		r.Delete("/{id:[0-9]+}/wishlist/{itemId:[0-9]+}/reserve", wishlistHandler.ReleaseWishItem)
+
+		if cfg.OIDCIssuer != "" {
+			provider := oidc.NewClient(oidc.Config{
+				Issuer:         cfg.OIDCIssuer,
+				ClientID:       cfg.OIDCClientID,
+				ClientSecret:   cfg.OIDCClientSecret,
+				RedirectURL:    cfg.OIDCRedirectURL,
+				Scopes:         strings.Fields(cfg.OIDCScopes),
+				BirthdateClaim: cfg.OIDCBirthdateClaim,
+			})
+			oidcHandler := api.NewOIDCHandler(userHandler, provider, cfg.FrontendURL)
+			r.Get("/oidc/login", oidcHandler.Login)
+			r.Get("/oidc/callback", oidcHandler.Callback)
+		}
+
+		// routes that personal access tokens can use
+		r.Group(func(r chi.Router) {
+			r.Use(userHandler.RequireScope(domain.ScopeReadBirthdays))
+			r.Get("/list-today", userHandler.ListToday)
+			r.Get("/list", userHandler.List)
+			r.Get("/upcoming", userHandler.Upcoming)
+			r.Get("/followers", userHandler.Followers)
+			r.Get("/following", userHandler.Following)
+			r.Get("/teams", userHandler.Teams)
+			r.Get("/teams/{id:[0-9]+}/members", userHandler.TeamMembers)
+			r.Get("/greetings", greetingsHandler.ReceivedGreetings)
+			r.Get("/{id:[0-9]+}", userHandler.GetProfile)
+			r.Get("/{id:[0-9]+}/greetings", greetingsHandler.BirthdayGreetings)
+			r.Get("/{id:[0-9]+}/wishlist", wishlistHandler.UserWishlist)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(userHandler.RequireScope(domain.ScopeWriteSubscriptions))
+			r.Post("/subscribe", userHandler.Subscribe)
+			r.Post("/unsubscribe", userHandler.Unsubscribe)
+			r.Post("/teams/subscribe", userHandler.SubscribeTeam)
+			r.Post("/teams/unsubscribe", userHandler.UnsubscribeTeam)
+		})
+	})
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(userHandler.RequireScope(domain.ScopeAdmin))
+
+		r.Group(func(r chi.Router) {
+			r.Use(userHandler.RequirePermission(domain.PermViewUsers))
+			r.Get("/users", userHandler.Users)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(userHandler.RequirePermission(domain.PermEditUsers))
+			r.Patch("/users/{id:[0-9]+}", userHandler.UpdateUser)
+			r.Post("/users/import", userHandler.ImportUsers)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(userHandler.RequirePermission(domain.PermManageUsers))
+			r.Post("/users/unlock", userHandler.UnlockAccount)
+			r.Put("/users/{id:[0-9]+}/role", userHandler.SetRole)
+			r.Post("/users/{id:[0-9]+}/disable", userHandler.DisableUser)
+			r.Post("/users/{id:[0-9]+}/enable", userHandler.EnableUser)
+			r.Delete("/users/{id:[0-9]+}", userHandler.DeleteUser)
+
+			if directorySync != nil {
+				directoryHandler := api.NewDirectoryHandler(directorySync)
+				r.Get("/directory/sync", directoryHandler.LastReport)
+				r.Post("/directory/sync", directoryHandler.Run)
+			}
+		})
 	})
 
 	handler := cors.Default().Handler(r)
-	err := http.ListenAndServe(":8080", handler)
+	err = http.ListenAndServe(cfg.Addr, handler)
 	if errors.Is(err, http.ErrServerClosed) {
 		log.Println("server closed")
 		return
@@ -44,6 +220,44 @@ func main() {
 	}
 }
 
+func newMailer(cfg config.Config) services.Mailer {
+	if cfg.SMTPAddr == "" {
+		return mailer.LogMailer{}
+	}
+
+	return mailer.SMTPMailer{
+		Addr:     cfg.SMTPAddr,
+		From:     cfg.SMTPFrom,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+	}
+}
+
+func newDirectorySync(cfg config.Config, users services.UsersService) *services.DirectorySync {
+	mapping := directory.Mapping{
+		Email:       cfg.DirectoryEmailAttr,
+		Name:        cfg.DirectoryNameAttr,
+		DateOfBirth: cfg.DirectoryBirthdateAttr,
+		Department:  cfg.DirectoryDepartmentAttr,
+	}
+
+	switch {
+	case cfg.DirectoryURL != "":
+		return services.NewDirectorySync(users, directory.LDAPSource{
+			URL:        cfg.DirectoryURL,
+			BindDN:     cfg.DirectoryBindDN,
+			Password:   cfg.DirectoryPassword,
+			BaseDN:     cfg.DirectoryBaseDN,
+			Filter:     cfg.DirectoryFilter,
+			Attributes: mapping.Attributes(),
+		}, mapping)
+	case cfg.DirectoryLDIF != "":
+		return services.NewDirectorySync(users, directory.LDIFSource{Path: cfg.DirectoryLDIF}, mapping)
+	default:
+		return nil
+	}
+}
+
 func insertUsers(storage *storage.Storage) {
 	users := make([]domain.RegisterRequest, 0)
 
@@ -69,9 +283,22 @@ func insertUsers(storage *storage.Storage) {
 	})
 
 	for _, user := range users {
-		_, err := storage.InsertUser(user)
+		hash, err := auth.HashPassword(user.Password)
+		if err != nil {
+			log.Printf("hash password error: %s", err)
+			continue
+		}
+		user.Password = hash
+
+		newUser, err := storage.InsertUser(user)
 		if err != nil {
 			log.Printf("insert user error: %s", err)
+			continue
+		}
+
+		err = storage.MarkVerified(newUser.ID, newUser.Email)
+		if err != nil {
+			log.Printf("verify user error: %s", err)
 		}
 	}
 }
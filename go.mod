@@ -3,13 +3,19 @@ module github.com/krevetkou/test-rutube
 go 1.22.4
 
 require (
+	github.com/bxcodec/faker/v3 v3.8.1
+	github.com/coder/websocket v1.8.13
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
+	github.com/go-asn1-ber/asn1-ber v1.5.7
+	github.com/go-chi/chi/v5 v5.0.12
+	github.com/go-ldap/ldap/v3 v3.4.10
+	github.com/rs/cors v1.11.0
 	github.com/sirupsen/logrus v1.9.3
+	golang.org/x/crypto v0.31.0
 )
 
 require (
-	github.com/bxcodec/faker/v3 v3.8.1 // indirect
-	github.com/go-chi/chi/v5 v5.0.12 // indirect
-	github.com/rs/cors v1.11.0 // indirect
-	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
+	github.com/Azure/go-ntlmssp v0.0.0-20221128193559-754e69321358 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
 )
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"github.com/krevetkou/test-rutube/internal/importer"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+func (h UsersHandler) UnlockAccount(w http.ResponseWriter, r *http.Request) {
+	var request domain.UnlockRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	err := h.Service.UnlockAccount(request.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			http.Error(w, "user not found", http.StatusNotFound)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
+
+func (h UsersHandler) Users(w http.ResponseWriter, r *http.Request) {
+	users, err := h.Service.GetUsers()
+	if err != nil {
+		http.Error(w, "failed to get users", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, users)
+}
+
+func (h UsersHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	var request domain.AdminSettingsRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	user, err := h.Service.UpdateUser(id, request)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (h UsersHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	var request domain.RoleRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	user, err := h.Service.SetRole(token, id, request.Role)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (h UsersHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, true)
+}
+
+func (h UsersHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, false)
+}
+
+func (h UsersHandler) setDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	token, err := authToken(r)
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.Service.SetDisabled(token, id, disabled)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (h UsersHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Service.DeleteUser(token, id)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
+
+func writeAdminError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrNotExists):
+		http.Error(w, "need to login", http.StatusUnauthorized)
+	case errors.Is(err, domain.ErrNotFound):
+		http.Error(w, "user not found", http.StatusNotFound)
+	case errors.Is(err, domain.ErrInvalidSettings):
+		http.Error(w, "invalid values", http.StatusBadRequest)
+	case errors.Is(err, domain.ErrSelfManage):
+		http.Error(w, "can't change own role or access", http.StatusConflict)
+	default:
+		http.Error(w, "unexpected error", http.StatusInternalServerError)
+	}
+	log.Println(err)
+}
+
+// MaxImportSize limits HR uploads to 10 MB.
+const MaxImportSize = 10 << 20
+
+// ImportUsers takes a CSV or JSON upload by its Content-Type,
+// ?dryRun=true only validates it.
+func (h UsersHandler) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	format := importer.FormatOf(r.Header.Get("Content-Type"))
+	if format == "" {
+		http.Error(w, "content type must be text/csv or application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	dryRun, err := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	if err != nil && r.URL.Query().Get("dryRun") != "" {
+		http.Error(w, "invalid query parameters", http.StatusBadRequest)
+		return
+	}
+
+	records, err := importer.Parse(http.MaxBytesReader(w, r.Body, MaxImportSize), format)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "the upload is too large", http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "failed to read the upload", http.StatusBadRequest)
+		}
+		log.Println(err)
+		return
+	}
+
+	result, err := h.Service.ImportUsers(records, dryRun)
+	if err != nil {
+		http.Error(w, "import failed", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
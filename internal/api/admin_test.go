@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/krevetkou/test-rutube/internal/config"
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func TestRequirePermission(t *testing.T) {
+	h, s := newTestHandler(t, config.Config{})
+	tokens := make(map[domain.Role]string)
+	for i, role := range domain.Roles {
+		email := string(role) + "@corp.ru"
+		tokens[role] = login(t, h, s, email)
+		if _, err := s.SetRole(i+1, role); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		permission domain.Permission
+		token      string
+		wantStatus int
+	}{
+		{name: "user can't view", permission: domain.PermViewUsers, token: tokens[domain.RoleUser], wantStatus: http.StatusForbidden},
+		{name: "hr views", permission: domain.PermViewUsers, token: tokens[domain.RoleHR], wantStatus: http.StatusOK},
+		{name: "hr edits", permission: domain.PermEditUsers, token: tokens[domain.RoleHR], wantStatus: http.StatusOK},
+		{name: "hr can't manage", permission: domain.PermManageUsers, token: tokens[domain.RoleHR], wantStatus: http.StatusForbidden},
+		{name: "admin manages", permission: domain.PermManageUsers, token: tokens[domain.RoleAdmin], wantStatus: http.StatusOK},
+		{name: "not logged in", permission: domain.PermViewUsers, wantStatus: http.StatusUnauthorized},
+		{name: "unknown token", permission: domain.PermViewUsers, token: "nobody", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			handler := h.RequirePermission(tt.permission)(ok)
+
+			w := serve(t, handler.ServeHTTP, "/admin", http.MethodGet, "/admin", tt.token, nil)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
+
+func TestSetRole(t *testing.T) {
+	h, s := newTestHandler(t, config.Config{})
+	admin := login(t, h, s, "admin@corp.ru")
+	login(t, h, s, "anna@corp.ru")
+	if _, err := s.SetRole(1, domain.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		target     string
+		body       string
+		wantStatus int
+	}{
+		{name: "gives a role", target: "/admin/users/2/role", body: `{"role":"hr"}`, wantStatus: http.StatusOK},
+		{name: "unknown role", target: "/admin/users/2/role", body: `{"role":"boss"}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown user", target: "/admin/users/100/role", body: `{"role":"hr"}`, wantStatus: http.StatusNotFound},
+		{name: "own role", target: "/admin/users/1/role", body: `{"role":"user"}`, wantStatus: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, h.SetRole, "/admin/users/{id:[0-9]+}/role", http.MethodPut, tt.target, admin, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"errors"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"net/http"
+)
+
+type BotService interface {
+	CreateBotLink(token string) (domain.BotLinkResponse, error)
+	GetBotStatus(token string) (domain.BotStatusResponse, error)
+	UnlinkBot(token string) error
+}
+
+type BotHandler struct {
+	Service BotService
+}
+
+func NewBotHandler(service BotService) BotHandler {
+	return BotHandler{
+		Service: service,
+	}
+}
+
+// CreateBotLink returns the one-time code that links a chat of the bot to the account.
+func (h BotHandler) CreateBotLink(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	link, err := h.Service.CreateBotLink(token)
+	if err != nil {
+		writeBotError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, link)
+}
+
+func (h BotHandler) BotStatus(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	status, err := h.Service.GetBotStatus(token)
+	if err != nil {
+		writeBotError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (h BotHandler) UnlinkBot(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	err = h.Service.UnlinkBot(token)
+	if err != nil {
+		writeBotError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
+
+func writeBotError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrNotExists):
+		http.Error(w, "need to login", http.StatusUnauthorized)
+	case errors.Is(err, domain.ErrNotFound):
+		http.Error(w, "no chat is linked", http.StatusNotFound)
+	case errors.Is(err, domain.ErrBotDisabled):
+		http.Error(w, domain.ErrBotDisabled.Error(), http.StatusNotImplemented)
+	default:
+		http.Error(w, "unexpected error", http.StatusInternalServerError)
+	}
+	log.Println(err)
+}
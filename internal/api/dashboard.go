@@ -0,0 +1,230 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/coder/websocket"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	wspolicy "github.com/krevetkou/test-rutube/internal/websocket"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// DashboardPingInterval is how often the dashboard connections and their
+	// tokens are checked.
+	DashboardPingInterval = 30 * time.Second
+	// DashboardPongWait is how long the answer to a ping may take before the
+	// connection is dropped.
+	DashboardPongWait = 10 * time.Second
+	// DashboardWriteTimeout is how long a message may take to be sent.
+	DashboardWriteTimeout = 10 * time.Second
+)
+
+// DashboardProtocol is the websocket subprotocol of the dashboards. Browsers
+// can't set headers on websockets, so they offer the token as one more
+// subprotocol next to it and it stays out of the URL and the access logs.
+const DashboardProtocol = "birthday-dashboard"
+
+// DashboardHandler serves the websocket of the office birthday dashboards.
+type DashboardHandler struct {
+	Users UsersHandler
+	// Location is the time zone of the office, the lists switch to the next
+	// day at its midnight.
+	Location *time.Location
+	// Origins are the pages of other hosts that may open the dashboard.
+	Origins []string
+	// PingInterval is how often the connection and the token are checked.
+	PingInterval time.Duration
+}
+
+func NewDashboardHandler(users UsersHandler, location *time.Location, origins []string) DashboardHandler {
+	return DashboardHandler{
+		Users:        users,
+		Location:     location,
+		Origins:      origins,
+		PingInterval: DashboardPingInterval,
+	}
+}
+
+// Connect sends the lists of birthdays and keeps them up to date: after users
+// change, at midnight, and with the new greetings of today's birthdays. The
+// token comes in the Authorization header or in the Sec-WebSocket-Protocol
+// header along with DashboardProtocol. The connection is closed with the
+// policy violation code once the token is revoked or expires.
+func (h DashboardHandler) Connect(w http.ResponseWriter, r *http.Request) {
+	if !wspolicy.CheckOrigin(r, h.Origins) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	token, ok := dashboardToken(r)
+	if !ok {
+		http.Error(w, "need a token", http.StatusUnauthorized)
+		return
+	}
+
+	events, cancel, err := h.Users.Service.OpenDashboard(token)
+	if err != nil {
+		writeDashboardError(w, err)
+		return
+	}
+	defer cancel()
+
+	dashboard, err := h.Users.Service.GetDashboard(token, time.Now())
+	if err != nil {
+		writeDashboardError(w, err)
+		return
+	}
+
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		Subprotocols: []string{DashboardProtocol},
+		// the origin is checked above, the library allows only the same host
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer conn.Close(websocket.StatusGoingAway, "")
+
+	// the dashboard sends nothing, reading only answers the control frames
+	ctx := conn.CloseRead(r.Context())
+
+	err = sendDashboard(ctx, conn, domain.DashboardSnapshot, dashboard)
+	if err != nil {
+		return
+	}
+
+	midnight := time.NewTimer(untilMidnight(time.Now(), h.Location))
+	defer midnight.Stop()
+	ping := time.NewTicker(h.PingInterval)
+	defer ping.Stop()
+
+	for {
+		refresh := false
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ping.C:
+			err = h.Users.Service.CheckDashboard(token)
+			if err != nil {
+				closeRevoked(conn, err)
+				return
+			}
+			err = pingDashboard(ctx, conn)
+		case <-midnight.C:
+			midnight.Reset(untilMidnight(time.Now(), h.Location))
+			refresh = true
+		case event, ok := <-events:
+			if !ok {
+				// the dashboard fell behind, it reconnects and gets the lists again
+				return
+			}
+			switch event.Type {
+			case domain.EventUsersChanged:
+				refresh = true
+			case domain.EventGreeting:
+				greeting, ok := event.Data.(domain.GreetingEvent)
+				if ok && slices.ContainsFunc(dashboard.Today, func(b domain.DashboardBirthday) bool {
+					return b.ID == greeting.Recipient.ID
+				}) {
+					err = sendDashboard(ctx, conn, domain.DashboardGreeting, greeting)
+				}
+			}
+		}
+
+		if refresh {
+			var next domain.DashboardResponse
+			next, err = h.Users.Service.GetDashboard(token, time.Now())
+			if err != nil {
+				closeRevoked(conn, err)
+				return
+			}
+			if changedDashboard(dashboard, next) {
+				dashboard = next
+				err = sendDashboard(ctx, conn, domain.DashboardSnapshot, dashboard)
+			}
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// dashboardToken takes the token from the Authorization header or from the
+// personal token offered as a subprotocol.
+func dashboardToken(r *http.Request) (string, bool) {
+	token, ok := bearerToken(r)
+	if ok {
+		return token, true
+	}
+
+	for _, protocol := range wspolicy.Protocols(r) {
+		if strings.HasPrefix(protocol, domain.PersonalTokenPrefix) {
+			return protocol, true
+		}
+	}
+
+	return "", false
+}
+
+func sendDashboard(ctx context.Context, conn *websocket.Conn, kind domain.DashboardMessageType, data any) error {
+	message, err := json.Marshal(domain.DashboardMessage{Type: kind, Data: data})
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, DashboardWriteTimeout)
+	defer cancel()
+
+	return conn.Write(ctx, websocket.MessageText, message)
+}
+
+// pingDashboard checks that the dashboard is still there, a dashboard that
+// doesn't answer in DashboardPongWait is dropped.
+func pingDashboard(ctx context.Context, conn *websocket.Conn) error {
+	ctx, cancel := context.WithTimeout(ctx, DashboardPongWait)
+	defer cancel()
+
+	return conn.Ping(ctx)
+}
+
+// closeRevoked tells the dashboard that its token was revoked or expired, so
+// it doesn't reconnect with the same token.
+func closeRevoked(conn *websocket.Conn, err error) {
+	_ = conn.Close(websocket.StatusPolicyViolation, err.Error())
+}
+
+func changedDashboard(old, next domain.DashboardResponse) bool {
+	return old.Date != next.Date || !slices.Equal(old.Today, next.Today) || !slices.Equal(old.Upcoming, next.Upcoming)
+}
+
+// untilMidnight returns the time left until the next day starts in the location.
+func untilMidnight(now time.Time, location *time.Location) time.Duration {
+	local := now.In(location)
+	next := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, location)
+
+	return next.Sub(now)
+}
+
+func writeDashboardError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidToken):
+		http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+	case errors.Is(err, domain.ErrReadOnlyToken):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, domain.ErrEventsDisabled):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	default:
+		http.Error(w, "failed to open the dashboard", http.StatusInternalServerError)
+	}
+	log.Println(err)
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/krevetkou/test-rutube/internal/config"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"github.com/krevetkou/test-rutube/internal/events"
+	"github.com/krevetkou/test-rutube/internal/storage"
+)
+
+// dashboardServer serves the dashboard pinging every ping and returns its
+// URL, the session of a new user and their read-only token.
+func dashboardServer(t *testing.T, ping time.Duration) (UsersHandler, string, string, domain.CreatedTokenResponse) {
+	t.Helper()
+
+	s := storage.NewStorage()
+	h := NewUsersHandler(newTestService(s, events.NewHub(10), config.Config{TimeZone: time.UTC, DashboardDays: 7}))
+	session := login(t, h, s, "anna@corp.ru")
+	created, err := h.Service.CreatePersonalToken(session, domain.CreateTokenRequest{Name: "screen", Scopes: []string{domain.ScopeReadBirthdays}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dashboard := NewDashboardHandler(h, time.UTC, []string{"https://intranet.corp.ru"})
+	dashboard.PingInterval = ping
+	server := httptest.NewServer(http.HandlerFunc(dashboard.Connect))
+	t.Cleanup(server.Close)
+
+	return h, "ws" + server.URL[len("http"):], session, created
+}
+
+func TestDashboardConnect(t *testing.T) {
+	_, url, _, created := dashboardServer(t, time.Hour)
+	token := created.Token
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{Subprotocols: []string{DashboardProtocol, token}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.CloseNow()
+	if conn.Subprotocol() != DashboardProtocol {
+		t.Errorf("subprotocol = %q, want %q", conn.Subprotocol(), DashboardProtocol)
+	}
+
+	_, data, err := conn.Read(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var message domain.DashboardMessage
+	if err = json.Unmarshal(data, &message); err != nil || message.Type != domain.DashboardSnapshot {
+		t.Errorf("the first message = %s, %v, want the snapshot", data, err)
+	}
+}
+
+func TestDashboardRejects(t *testing.T) {
+	_, url, _, created := dashboardServer(t, time.Hour)
+	token := created.Token
+
+	tests := []struct {
+		name       string
+		protocols  []string
+		origin     string
+		wantStatus int
+	}{
+		{name: "no token", protocols: []string{DashboardProtocol}, wantStatus: http.StatusUnauthorized},
+		{name: "unknown token", protocols: []string{DashboardProtocol, domain.PersonalTokenPrefix + "nope"}, wantStatus: http.StatusUnauthorized},
+		{name: "other site", protocols: []string{DashboardProtocol, token}, origin: "https://evil.example", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+			_, resp, err := websocket.Dial(ctx, url, &websocket.DialOptions{Subprotocols: tt.protocols, HTTPHeader: header})
+			if err == nil {
+				t.Fatal("the dashboard was opened")
+			}
+			if resp == nil || resp.StatusCode != tt.wantStatus {
+				t.Errorf("response = %+v, want status %d", resp, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestDashboardClosesRevoked(t *testing.T) {
+	h, url, session, created := dashboardServer(t, 10*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{Subprotocols: []string{DashboardProtocol, created.Token}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.CloseNow()
+	if _, _, err = conn.Read(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = h.Service.RevokePersonalToken(session, created.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = conn.Read(ctx)
+	if status := websocket.CloseStatus(err); status != websocket.StatusPolicyViolation {
+		t.Errorf("the dashboard was closed with %v, %v, want %v", status, err, websocket.StatusPolicyViolation)
+	}
+}
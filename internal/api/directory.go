@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"net/http"
+)
+
+type DirectorySyncer interface {
+	Run(ctx context.Context) (domain.SyncReport, error)
+	LastReport() (domain.SyncReport, error)
+}
+
+type DirectoryHandler struct {
+	Sync DirectorySyncer
+}
+
+func NewDirectoryHandler(sync DirectorySyncer) DirectoryHandler {
+	return DirectoryHandler{
+		Sync: sync,
+	}
+}
+
+func (h DirectoryHandler) LastReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.Sync.LastReport()
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			http.Error(w, "directory wasn't synced yet", http.StatusNotFound)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (h DirectoryHandler) Run(w http.ResponseWriter, r *http.Request) {
+	report, err := h.Sync.Run(r.Context())
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrEmptyDirectory):
+			http.Error(w, "directory returned no users, nothing is changed", http.StatusBadGateway)
+		default:
+			http.Error(w, "directory sync failed", http.StatusBadGateway)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"errors"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"net/http"
+)
+
+func (h UsersHandler) ConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	h.emailToken(w, r, h.Service.ConfirmEmail)
+}
+
+func (h UsersHandler) RevertEmail(w http.ResponseWriter, r *http.Request) {
+	h.emailToken(w, r, h.Service.RevertEmail)
+}
+
+func (h UsersHandler) emailToken(w http.ResponseWriter, r *http.Request, apply func(token string) (domain.UserResponse, error)) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := apply(token)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidToken):
+			http.Error(w, "invalid or expired token", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrExists):
+			http.Error(w, "email is already taken", http.StatusConflict)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"net/http"
+	"time"
+)
+
+// EventsHandler streams the events of the user as server-sent events.
+type EventsHandler struct {
+	Users UsersHandler
+	// Heartbeat is how often a comment is sent to keep idle connections
+	// open through proxies.
+	Heartbeat time.Duration
+}
+
+func NewEventsHandler(users UsersHandler, heartbeat time.Duration) EventsHandler {
+	return EventsHandler{
+		Users:     users,
+		Heartbeat: heartbeat,
+	}
+}
+
+// Stream sends the events until the client goes away or the session ends,
+// which is checked on every heartbeat. A reconnecting client sends the
+// Last-Event-ID header and gets the events it has missed.
+func (h EventsHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	events, cancel, err := h.Users.Service.SubscribeEvents(token, r.Header.Get("Last-Event-ID"))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "need to login", http.StatusUnauthorized)
+		case errors.Is(err, domain.ErrEventsDisabled):
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+		default:
+			http.Error(w, "failed to open the event stream", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	defer cancel()
+
+	rc := http.NewResponseController(w)
+	// the stream outlives the write timeout of the server
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	err = rc.Flush()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	heartbeat := time.NewTicker(h.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			// the client reconnects and learns that the session has ended
+			if h.Users.Service.CheckSession(token) != nil {
+				return
+			}
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		case event, ok := <-events:
+			if !ok {
+				// the stream fell behind, the client reconnects and resumes
+				return
+			}
+			err = writeEvent(w, event)
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			log.Println(err)
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event domain.Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+
+	return err
+}
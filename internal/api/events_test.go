@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/krevetkou/test-rutube/internal/config"
+	"github.com/krevetkou/test-rutube/internal/events"
+	"github.com/krevetkou/test-rutube/internal/storage"
+)
+
+func TestStreamErrors(t *testing.T) {
+	h, s := newTestHandler(t, config.Config{})
+	token := login(t, h, s, "anna@corp.ru")
+	stream := NewEventsHandler(h, time.Second).Stream
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{name: "no token", wantStatus: http.StatusUnauthorized},
+		{name: "unknown token", token: "nope", wantStatus: http.StatusUnauthorized},
+		{name: "no broker", token: token, wantStatus: http.StatusNotImplemented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, stream, "/events", http.MethodGet, "/events", tt.token, nil)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestStreamSendsEvents(t *testing.T) {
+	s := storage.NewStorage()
+	h := NewUsersHandler(newTestService(s, events.NewHub(10), config.Config{}))
+	anna := login(t, h, s, "anna@corp.ru")
+	login(t, h, s, "boris@corp.ru")
+
+	server := httptest.NewServer(http.HandlerFunc(NewEventsHandler(h, time.Hour).Stream))
+	defer server.Close()
+
+	r, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddCookie(&http.Cookie{Name: AuthCookieName, Value: anna})
+	// the timeout ends the stream if the event never comes
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || ct != "text/event-stream" {
+		t.Fatalf("status %d, content type %q, want an event stream", resp.StatusCode, ct)
+	}
+
+	if _, err := h.Service.Subscribe(anna, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		if lines.Text() == "event: subscription" {
+			if !lines.Scan() || !strings.Contains(lines.Text(), `"userId":2`) {
+				t.Errorf("the event data is %q, want the followed user", lines.Text())
+			}
+			return
+		}
+	}
+	t.Errorf("the stream ended without the subscription event: %v", lines.Err())
+}
+
+func TestStreamEndsWithSession(t *testing.T) {
+	s := storage.NewStorage()
+	h := NewUsersHandler(newTestService(s, events.NewHub(10), config.Config{}))
+	anna := login(t, h, s, "anna@corp.ru")
+
+	server := httptest.NewServer(http.HandlerFunc(NewEventsHandler(h, 10*time.Millisecond).Stream))
+	defer server.Close()
+
+	r, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddCookie(&http.Cookie{Name: AuthCookieName, Value: anna})
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := s.SetDisabled(1, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// the stream is closed by the server, not by the timeout of the client
+	_, err = io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		t.Errorf("the stream of a disabled user wasn't closed: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type FollowRequestsService interface {
+	GetFollowRequests(token string, incoming bool) ([]domain.FollowRequestResponse, error)
+	ApproveFollowRequest(token string, id int) error
+	RejectFollowRequest(token string, id int) error
+	CancelFollowRequest(token string, id int) error
+}
+
+type FollowRequestsHandler struct {
+	Service FollowRequestsService
+}
+
+func NewFollowRequestsHandler(service FollowRequestsService) FollowRequestsHandler {
+	return FollowRequestsHandler{
+		Service: service,
+	}
+}
+
+func (h FollowRequestsHandler) IncomingFollowRequests(w http.ResponseWriter, r *http.Request) {
+	h.followRequests(w, r, true)
+}
+
+func (h FollowRequestsHandler) OutgoingFollowRequests(w http.ResponseWriter, r *http.Request) {
+	h.followRequests(w, r, false)
+}
+
+func (h FollowRequestsHandler) followRequests(w http.ResponseWriter, r *http.Request, incoming bool) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	requests, err := h.Service.GetFollowRequests(token, incoming)
+	if err != nil {
+		writeFollowRequestError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, requests)
+}
+
+func (h FollowRequestsHandler) ApproveFollowRequest(w http.ResponseWriter, r *http.Request) {
+	h.changeFollowRequest(w, r, h.Service.ApproveFollowRequest)
+}
+
+func (h FollowRequestsHandler) RejectFollowRequest(w http.ResponseWriter, r *http.Request) {
+	h.changeFollowRequest(w, r, h.Service.RejectFollowRequest)
+}
+
+func (h FollowRequestsHandler) CancelFollowRequest(w http.ResponseWriter, r *http.Request) {
+	h.changeFollowRequest(w, r, h.Service.CancelFollowRequest)
+}
+
+func (h FollowRequestsHandler) changeFollowRequest(w http.ResponseWriter, r *http.Request, change func(token string, id int) error) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid request id", http.StatusBadRequest)
+		return
+	}
+
+	err = change(token, id)
+	if err != nil {
+		writeFollowRequestError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
+
+func writeFollowRequestError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrNotExists):
+		http.Error(w, "need to login", http.StatusUnauthorized)
+	case errors.Is(err, domain.ErrNotFound):
+		http.Error(w, "follow request not found", http.StatusNotFound)
+	case errors.Is(err, domain.ErrFollowLimit):
+		http.Error(w, "the follower reached the follow limit", http.StatusConflict)
+	default:
+		http.Error(w, "unexpected error", http.StatusInternalServerError)
+	}
+	log.Println(err)
+}
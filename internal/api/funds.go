@@ -0,0 +1,200 @@
+package api
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type FundsService interface {
+	CreateFund(token string, request domain.FundRequest) (domain.FundResponse, error)
+	GetFunds(token string) ([]domain.FundResponse, error)
+	GetFund(token string, id int) (domain.FundResponse, error)
+	InviteFollowers(token string, id int) (domain.FundResponse, error)
+	SetPledge(token string, id, participantId int, request domain.PledgeRequest) (domain.FundResponse, error)
+	DeleteFund(token string, id int) error
+}
+
+type FundsHandler struct {
+	Service FundsService
+}
+
+func NewFundsHandler(service FundsService) FundsHandler {
+	return FundsHandler{
+		Service: service,
+	}
+}
+
+func (h FundsHandler) CreateFund(w http.ResponseWriter, r *http.Request) {
+	var request domain.FundRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	fund, err := h.Service.CreateFund(token, request)
+	if err != nil {
+		writeFundError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, fund)
+}
+
+func (h FundsHandler) Funds(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	funds, err := h.Service.GetFunds(token)
+	if err != nil {
+		writeFundError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, funds)
+}
+
+func (h FundsHandler) Fund(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid fund id", http.StatusBadRequest)
+		return
+	}
+
+	fund, err := h.Service.GetFund(token, id)
+	if err != nil {
+		writeFundError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, fund)
+}
+
+func (h FundsHandler) InviteFollowers(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid fund id", http.StatusBadRequest)
+		return
+	}
+
+	fund, err := h.Service.InviteFollowers(token, id)
+	if err != nil {
+		writeFundError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, fund)
+}
+
+// SetPledge changes the pledge of the participant from the URL.
+func (h FundsHandler) SetPledge(w http.ResponseWriter, r *http.Request) {
+	var request domain.PledgeRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid fund id", http.StatusBadRequest)
+		return
+	}
+
+	userId, err := strconv.Atoi(chi.URLParam(r, "userId"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	fund, err := h.Service.SetPledge(token, id, userId, request)
+	if err != nil {
+		writeFundError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, fund)
+}
+
+func (h FundsHandler) DeleteFund(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid fund id", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Service.DeleteFund(token, id)
+	if err != nil {
+		writeFundError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
+
+func writeFundError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrNotExists):
+		http.Error(w, "need to login", http.StatusUnauthorized)
+	case errors.Is(err, domain.ErrNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, domain.ErrForbidden):
+		http.Error(w, "only the organizer can do it", http.StatusForbidden)
+	case errors.Is(err, domain.ErrFieldsRequired):
+		http.Error(w, "nothing to change", http.StatusBadRequest)
+	case errors.Is(err, domain.ErrInvalidFund):
+		http.Error(w, domain.ErrInvalidFund.Error(), http.StatusBadRequest)
+	case errors.Is(err, domain.ErrSelfFund):
+		http.Error(w, domain.ErrSelfFund.Error(), http.StatusConflict)
+	case errors.Is(err, domain.ErrExists):
+		http.Error(w, "a fund for this birthday is already open", http.StatusConflict)
+	case errors.Is(err, domain.ErrBlocked):
+		http.Error(w, "user blocked you", http.StatusForbidden)
+	case errors.Is(err, domain.ErrBirthdayHidden):
+		http.Error(w, domain.ErrBirthdayHidden.Error(), http.StatusForbidden)
+	case errors.Is(err, domain.ErrFundClosed):
+		http.Error(w, domain.ErrFundClosed.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "unexpected error", http.StatusInternalServerError)
+	}
+	log.Println(err)
+}
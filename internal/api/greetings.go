@@ -0,0 +1,173 @@
+package api
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type GreetingsService interface {
+	PostGreeting(token string, userId int, request domain.GreetingRequest) (domain.GreetingResponse, error)
+	GetBirthdayGreetings(token string, userId int) ([]domain.GreetingResponse, error)
+	GetReceivedGreetings(token string) ([]domain.GreetingResponse, error)
+	UpdateGreeting(token string, id int, request domain.GreetingUpdateRequest) (domain.GreetingResponse, error)
+	DeleteGreeting(token string, id int) error
+}
+
+type GreetingsHandler struct {
+	Service GreetingsService
+}
+
+func NewGreetingsHandler(service GreetingsService) GreetingsHandler {
+	return GreetingsHandler{
+		Service: service,
+	}
+}
+
+func (h GreetingsHandler) PostGreeting(w http.ResponseWriter, r *http.Request) {
+	var request domain.GreetingRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	greeting, err := h.Service.PostGreeting(token, id, request)
+	if err != nil {
+		writeGreetingError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, greeting)
+}
+
+func (h GreetingsHandler) BirthdayGreetings(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	greetings, err := h.Service.GetBirthdayGreetings(token, id)
+	if err != nil {
+		writeGreetingError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, greetings)
+}
+
+func (h GreetingsHandler) ReceivedGreetings(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	greetings, err := h.Service.GetReceivedGreetings(token)
+	if err != nil {
+		writeGreetingError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, greetings)
+}
+
+func (h GreetingsHandler) UpdateGreeting(w http.ResponseWriter, r *http.Request) {
+	var request domain.GreetingUpdateRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid greeting id", http.StatusBadRequest)
+		return
+	}
+
+	greeting, err := h.Service.UpdateGreeting(token, id, request)
+	if err != nil {
+		writeGreetingError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, greeting)
+}
+
+func (h GreetingsHandler) DeleteGreeting(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid greeting id", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Service.DeleteGreeting(token, id)
+	if err != nil {
+		writeGreetingError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
+
+func writeGreetingError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrNotExists):
+		http.Error(w, "need to login", http.StatusUnauthorized)
+	case errors.Is(err, domain.ErrNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, domain.ErrForbidden):
+		http.Error(w, "can change only own greetings", http.StatusForbidden)
+	case errors.Is(err, domain.ErrFieldsRequired):
+		http.Error(w, "nothing to change", http.StatusBadRequest)
+	case errors.Is(err, domain.ErrInvalidGreeting):
+		http.Error(w, domain.ErrInvalidGreeting.Error(), http.StatusBadRequest)
+	case errors.Is(err, domain.ErrSelfGreeting):
+		http.Error(w, domain.ErrSelfGreeting.Error(), http.StatusConflict)
+	case errors.Is(err, domain.ErrBlocked):
+		http.Error(w, "user blocked you", http.StatusForbidden)
+	case errors.Is(err, domain.ErrBirthdayHidden):
+		http.Error(w, domain.ErrBirthdayHidden.Error(), http.StatusForbidden)
+	case errors.Is(err, domain.ErrGreetingClosed):
+		http.Error(w, domain.ErrGreetingClosed.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "unexpected error", http.StatusInternalServerError)
+	}
+	log.Println(err)
+}
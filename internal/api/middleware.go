@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"net/http"
+	"strings"
+)
+
+type contextKey string
+
+const scopedTokenKey contextKey = "scoped-token"
+
+// authToken returns the credential of the request: the session cookie or
+// an "Authorization: Bearer" token. Personal tokens are returned only on
+// routes that checked their scope with RequireScope, and never from the
+// cookie, which only holds sessions.
+func authToken(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(AuthCookieName)
+	if err == nil {
+		if strings.HasPrefix(cookie.Value, domain.PersonalTokenPrefix) {
+			return "", domain.ErrForbidden
+		}
+		return cookie.Value, nil
+	}
+
+	token, ok := bearerToken(r)
+	if !ok {
+		return "", http.ErrNoCookie
+	}
+
+	if strings.HasPrefix(token, domain.PersonalTokenPrefix) && r.Context().Value(scopedTokenKey) != token {
+		return "", domain.ErrForbidden
+	}
+
+	return token, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+
+	return strings.TrimSpace(token), true
+}
+
+// RequireScope lets personal tokens with the scope use the route.
+// Session tokens have access to every route and pass as is.
+func (h UsersHandler) RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok || !strings.HasPrefix(token, domain.PersonalTokenPrefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			err := h.Service.AuthorizePersonalToken(token, scope)
+			if err != nil {
+				switch {
+				case errors.Is(err, domain.ErrForbidden):
+					http.Error(w, "token doesn't have the "+scope+" scope", http.StatusForbidden)
+				default:
+					http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+				}
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), scopedTokenKey, token)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequirePermission lets through only users whose role has the permission.
+func (h UsersHandler) RequirePermission(permission domain.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err := authToken(r)
+			if err != nil {
+				http.Error(w, "need to login", http.StatusUnauthorized)
+				return
+			}
+
+			err = h.Service.Authorize(token, permission)
+			if err != nil {
+				switch {
+				case errors.Is(err, domain.ErrForbidden):
+					http.Error(w, "forbidden", http.StatusForbidden)
+				default:
+					http.Error(w, "need to login", http.StatusUnauthorized)
+				}
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
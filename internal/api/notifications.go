@@ -0,0 +1,149 @@
+package api
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type NotificationsService interface {
+	GetNotifications(token string, query domain.NotificationQuery) (domain.NotificationListResponse, error)
+	CountUnread(token string) (domain.UnreadCountResponse, error)
+	MarkNotificationRead(token string, id int) (domain.NotificationResponse, error)
+	MarkAllNotificationsRead(token string) error
+}
+
+type NotificationsHandler struct {
+	Service NotificationsService
+}
+
+func NewNotificationsHandler(service NotificationsService) NotificationsHandler {
+	return NotificationsHandler{
+		Service: service,
+	}
+}
+
+// Notifications returns a page of the inbox, ?unread=true leaves out the read ones.
+func (h NotificationsHandler) Notifications(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	query, err := parseNotificationQuery(r)
+	if err != nil {
+		http.Error(w, "invalid query parameters", http.StatusBadRequest)
+		return
+	}
+
+	notifications, err := h.Service.GetNotifications(token, query)
+	if err != nil {
+		writeNotificationError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, notifications)
+}
+
+func (h NotificationsHandler) UnreadCount(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	count, err := h.Service.CountUnread(token)
+	if err != nil {
+		writeNotificationError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, count)
+}
+
+func (h NotificationsHandler) MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid notification id", http.StatusBadRequest)
+		return
+	}
+
+	notification, err := h.Service.MarkNotificationRead(token, id)
+	if err != nil {
+		writeNotificationError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, notification)
+}
+
+func (h NotificationsHandler) MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	err = h.Service.MarkAllNotificationsRead(token)
+	if err != nil {
+		writeNotificationError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
+
+func parseNotificationQuery(r *http.Request) (domain.NotificationQuery, error) {
+	values := r.URL.Query()
+	query := domain.NotificationQuery{
+		Cursor: values.Get("cursor"),
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil {
+			return domain.NotificationQuery{}, err
+		}
+		query.Limit = value
+	}
+
+	if unread := values.Get("unread"); unread != "" {
+		value, err := strconv.ParseBool(unread)
+		if err != nil {
+			return domain.NotificationQuery{}, err
+		}
+		query.Unread = value
+	}
+
+	return query, nil
+}
+
+func writeNotificationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrNotExists):
+		http.Error(w, "need to login", http.StatusUnauthorized)
+	case errors.Is(err, domain.ErrNotFound):
+		http.Error(w, "notification not found", http.StatusNotFound)
+	case errors.Is(err, domain.ErrInvalidCursor):
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+	case errors.Is(err, domain.ErrInvalidQuery):
+		http.Error(w, "invalid query parameters", http.StatusBadRequest)
+	default:
+		http.Error(w, "unexpected error", http.StatusInternalServerError)
+	}
+	log.Println(err)
+}
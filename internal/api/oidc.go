@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+const OIDCStateCookieName = "oidc_state"
+
+type OIDCProvider interface {
+	AuthCodeURL(ctx context.Context, loginHint string) (string, string, error)
+	Exchange(ctx context.Context, state, code string) (domain.ExternalIdentity, error)
+}
+
+// OIDCHandler logs users in through the corporate identity provider.
+type OIDCHandler struct {
+	Users    UsersHandler
+	Provider OIDCProvider
+	// RedirectURL is where the browser goes after a successful login. When
+	// the account has two-factor authentication the challenge token comes in
+	// the challengeToken parameter of the fragment.
+	RedirectURL string
+}
+
+func NewOIDCHandler(users UsersHandler, provider OIDCProvider, redirectURL string) OIDCHandler {
+	return OIDCHandler{
+		Users:       users,
+		Provider:    provider,
+		RedirectURL: redirectURL,
+	}
+}
+
+func (h OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	authURL, state, err := h.Provider.AuthCodeURL(r.Context(), r.URL.Query().Get("login_hint"))
+	if err != nil {
+		http.Error(w, "identity provider is unavailable", http.StatusBadGateway)
+		log.Println(err)
+		return
+	}
+
+	// the cookie ties the callback to the browser that started the login
+	http.SetCookie(w, &http.Cookie{
+		Name:     OIDCStateCookieName,
+		Value:    state,
+		Path:     "/user/oidc",
+		MaxAge:   600,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (h OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		http.Error(w, "login was rejected by the identity provider", http.StatusUnauthorized)
+		log.Printf("oidc callback: %s %s", providerErr, query.Get("error_description"))
+		return
+	}
+
+	state := query.Get("state")
+	cookie, err := r.Cookie(OIDCStateCookieName)
+	if err != nil || state == "" || cookie.Value != state {
+		http.Error(w, "invalid login state, start the login again", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: OIDCStateCookieName, Path: "/user/oidc", MaxAge: -1})
+
+	identity, err := h.Provider.Exchange(r.Context(), state, query.Get("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidToken):
+			http.Error(w, "invalid login state, start the login again", http.StatusBadRequest)
+		default:
+			http.Error(w, "identity provider is unavailable", http.StatusBadGateway)
+		}
+		log.Println(err)
+		return
+	}
+
+	user, err := h.Users.Service.LoginExternal(identity)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrAccountDisabled):
+			http.Error(w, "account is disabled", http.StatusForbidden)
+		case errors.Is(err, domain.ErrExists):
+			http.Error(w, "account with this email already exists, the identity provider must verify the email", http.StatusConflict)
+		case errors.Is(err, domain.ErrFieldsRequired), errors.Is(err, domain.ErrInvalidSettings), errors.Is(err, domain.ErrInvalidToken):
+			http.Error(w, "identity provider didn't share a valid email", http.StatusBadRequest)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	if user.TwoFactorRequired {
+		// the page finishes the login with the code, the fragment isn't sent
+		// to servers and stays out of their logs
+		fragment := url.Values{"challengeToken": {user.ChallengeToken}}
+		http.Redirect(w, r, h.RedirectURL+"#"+fragment.Encode(), http.StatusFound)
+		return
+	}
+
+	if !h.Users.setSessionCookie(w, user) {
+		return
+	}
+
+	http.Redirect(w, r, h.RedirectURL, http.StatusFound)
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"errors"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"net"
+	"net/http"
+)
+
+func (h UsersHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var request domain.ForgotPasswordRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	err := h.Service.ForgotPassword(request.Email, clientIP(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrTooManyRequests):
+			http.Error(w, "too many requests, try again later", http.StatusTooManyRequests)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
+
+func (h UsersHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var request domain.ResetPasswordRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	err := h.Service.ResetPassword(request)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidToken):
+			http.Error(w, "invalid or expired token", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrWeakPassword):
+			http.Error(w, "password is too weak", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrBreachedPassword):
+			http.Error(w, "password was found in a data breach, choose another one", http.StatusBadRequest)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
+
+func (h UsersHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var request domain.ChangePasswordRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	err = h.Service.ChangePassword(token, request)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "need to login", http.StatusUnauthorized)
+		case errors.Is(err, domain.ErrBadCredentials):
+			http.Error(w, "current password is incorrect", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrWeakPassword):
+			http.Error(w, "password is too weak", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrBreachedPassword):
+			http.Error(w, "password was found in a data breach, choose another one", http.StatusBadRequest)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "failed to create response data", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(data)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "content type not allowed", http.StatusUnsupportedMediaType)
+		return false
+	}
+
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		log.Println(err)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"errors"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"net/http"
+)
+
+func (h UsersHandler) Followers(w http.ResponseWriter, r *http.Request) {
+	h.followList(w, r, h.Service.GetFollowers)
+}
+
+func (h UsersHandler) Following(w http.ResponseWriter, r *http.Request) {
+	h.followList(w, r, h.Service.GetFollowing)
+}
+
+func (h UsersHandler) Blocked(w http.ResponseWriter, r *http.Request) {
+	h.followList(w, r, h.Service.GetBlocked)
+}
+
+func (h UsersHandler) RemoveFollower(w http.ResponseWriter, r *http.Request) {
+	var request domain.SubscribeRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	err = h.Service.RemoveFollower(token, request.UserId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "user or subscribe doesn't exist", http.StatusConflict)
+		case errors.Is(err, domain.ErrNotFound):
+			http.Error(w, "user not found", http.StatusNotFound)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
+
+func (h UsersHandler) Block(w http.ResponseWriter, r *http.Request) {
+	var request domain.SubscribeRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	err = h.Service.Block(token, request.UserId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrExists):
+			http.Error(w, "user already blocked", http.StatusConflict)
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "user doesn't exist", http.StatusConflict)
+		case errors.Is(err, domain.ErrNotFound):
+			http.Error(w, "user not found", http.StatusNotFound)
+		case errors.Is(err, domain.ErrSelfSubscribe):
+			http.Error(w, "can't block yourself", http.StatusBadRequest)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
+
+func (h UsersHandler) Unblock(w http.ResponseWriter, r *http.Request) {
+	var request domain.SubscribeRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	err = h.Service.Unblock(token, request.UserId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "user or block doesn't exist", http.StatusConflict)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
+
+func (h UsersHandler) followList(w http.ResponseWriter, r *http.Request, get func(token string) ([]domain.FollowResponse, error)) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	users, err := get(token)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "need to login", http.StatusUnauthorized)
+		default:
+			http.Error(w, "failed to get users", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, users)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/krevetkou/test-rutube/internal/config"
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func TestBlock(t *testing.T) {
+	h, s := newTestHandler(t, config.Config{})
+	anna := login(t, h, s, "anna@corp.ru")
+	boris := login(t, h, s, "boris@corp.ru")
+
+	// the steps share the storage and run in order
+	steps := []struct {
+		name       string
+		handler    http.HandlerFunc
+		token      string
+		userID     int
+		wantStatus int
+	}{
+		{name: "anna subscribes", handler: h.Subscribe, token: anna, userID: 2, wantStatus: http.StatusOK},
+		{name: "boris blocks himself", handler: h.Block, token: boris, userID: 2, wantStatus: http.StatusBadRequest},
+		{name: "boris blocks anna", handler: h.Block, token: boris, userID: 1, wantStatus: http.StatusOK},
+		{name: "boris blocks anna again", handler: h.Block, token: boris, userID: 1, wantStatus: http.StatusConflict},
+		{name: "anna can't subscribe", handler: h.Subscribe, token: anna, userID: 2, wantStatus: http.StatusForbidden},
+		{name: "boris unblocks anna", handler: h.Unblock, token: boris, userID: 1, wantStatus: http.StatusOK},
+		{name: "anna subscribes again", handler: h.Subscribe, token: anna, userID: 2, wantStatus: http.StatusOK},
+		{name: "boris removes anna", handler: h.RemoveFollower, token: boris, userID: 1, wantStatus: http.StatusOK},
+		{name: "anna isn't a follower", handler: h.RemoveFollower, token: boris, userID: 1, wantStatus: http.StatusConflict},
+	}
+	for _, step := range steps {
+		w := serve(t, step.handler, "/", http.MethodPost, "/", step.token, domain.SubscribeRequest{UserId: step.userID})
+		if w.Code != step.wantStatus {
+			t.Fatalf("%s: status %d, want %d: %s", step.name, w.Code, step.wantStatus, w.Body)
+		}
+	}
+}
+
+func TestFollowers(t *testing.T) {
+	h, s := newTestHandler(t, config.Config{})
+	anna := login(t, h, s, "anna@corp.ru")
+	boris := login(t, h, s, "boris@corp.ru")
+	for _, sub := range []struct {
+		token string
+		id    int
+	}{{anna, 2}, {boris, 1}} {
+		if _, err := h.Service.Subscribe(sub.token, sub.id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := serve(t, h.Followers, "/", http.MethodGet, "/", anna, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	var followers []domain.FollowResponse
+	if err := json.NewDecoder(w.Body).Decode(&followers); err != nil {
+		t.Fatal(err)
+	}
+	if len(followers) != 1 || followers[0].ID != 2 || !followers[0].IsMutual {
+		t.Errorf("followers %+v, want mutual boris", followers)
+	}
+}
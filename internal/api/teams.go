@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+func (h UsersHandler) Teams(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	teams, err := h.Service.GetTeams(token)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "need to login", http.StatusUnauthorized)
+		default:
+			http.Error(w, "failed to get teams", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, teams)
+}
+
+func (h UsersHandler) TeamMembers(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid team id", http.StatusBadRequest)
+		return
+	}
+
+	members, err := h.Service.GetTeamMembers(token, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "need to login", http.StatusUnauthorized)
+		case errors.Is(err, domain.ErrNotFound):
+			http.Error(w, "team not found", http.StatusNotFound)
+		default:
+			http.Error(w, "failed to get team members", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, members)
+}
+
+func (h UsersHandler) SubscribeTeam(w http.ResponseWriter, r *http.Request) {
+	var request domain.TeamSubscribeRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	err = h.Service.SubscribeTeam(token, request.TeamId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrExists):
+			http.Error(w, "subscribe exist", http.StatusConflict)
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "user doesn't exist", http.StatusConflict)
+		case errors.Is(err, domain.ErrNotFound):
+			http.Error(w, "team not found", http.StatusNotFound)
+		case errors.Is(err, domain.ErrFollowLimit):
+			http.Error(w, "follow limit reached", http.StatusConflict)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
+
+func (h UsersHandler) UnsubscribeTeam(w http.ResponseWriter, r *http.Request) {
+	var request domain.TeamSubscribeRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	err = h.Service.UnsubscribeTeam(token, request.TeamId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "subscription doesn't exist", http.StatusConflict)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
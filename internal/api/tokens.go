@@ -0,0 +1,98 @@
+package api
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+func (h UsersHandler) CreatePersonalToken(w http.ResponseWriter, r *http.Request) {
+	var request domain.CreateTokenRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	created, err := h.Service.CreatePersonalToken(token, request)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "need to login", http.StatusUnauthorized)
+		case errors.Is(err, domain.ErrFieldsRequired):
+			http.Error(w, "name and scopes are required", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrInvalidSettings):
+			http.Error(w, "unknown scope or invalid expiry", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrForbidden):
+			http.Error(w, "not enough permissions for the scope", http.StatusForbidden)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h UsersHandler) PersonalTokens(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := h.Service.GetPersonalTokens(token)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "need to login", http.StatusUnauthorized)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+func (h UsersHandler) RevokePersonalToken(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid token id", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Service.RevokePersonalToken(token, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "need to login", http.StatusUnauthorized)
+		case errors.Is(err, domain.ErrNotFound):
+			http.Error(w, "token not found", http.StatusNotFound)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/krevetkou/test-rutube/internal/config"
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func TestPersonalTokenScopes(t *testing.T) {
+	h, s := newTestHandler(t, config.Config{})
+	session := login(t, h, s, "anna@corp.ru")
+	login(t, h, s, "boris@corp.ru")
+
+	reader, err := h.Service.CreatePersonalToken(session, domain.CreateTokenRequest{Name: "script", Scopes: []string{domain.ScopeReadBirthdays}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := h.Service.CreatePersonalToken(session, domain.CreateTokenRequest{Name: "old", Scopes: []string{domain.ScopeReadBirthdays}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = h.Service.RevokePersonalToken(session, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/info", h.GetUserInfo)
+	router.Group(func(r chi.Router) {
+		r.Use(h.RequireScope(domain.ScopeReadBirthdays))
+		r.Get("/list", h.List)
+	})
+	router.Group(func(r chi.Router) {
+		r.Use(h.RequireScope(domain.ScopeWriteSubscriptions))
+		r.Post("/subscribe", h.Subscribe)
+	})
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		bearer     string
+		cookie     string
+		wantStatus int
+	}{
+		{name: "token with the scope", method: http.MethodGet, target: "/list", bearer: reader.Token, wantStatus: http.StatusOK},
+		{name: "token without the scope", method: http.MethodPost, target: "/subscribe", bearer: reader.Token, wantStatus: http.StatusForbidden},
+		{name: "route without scopes", method: http.MethodGet, target: "/info", bearer: reader.Token, wantStatus: http.StatusUnauthorized},
+		{name: "token in the cookie", method: http.MethodGet, target: "/list", cookie: reader.Token, wantStatus: http.StatusUnauthorized},
+		{name: "revoked token", method: http.MethodGet, target: "/list", bearer: revoked.Token, wantStatus: http.StatusUnauthorized},
+		{name: "session in the header", method: http.MethodGet, target: "/list", bearer: session, wantStatus: http.StatusOK},
+		{name: "session on any route", method: http.MethodGet, target: "/info", cookie: session, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.bearer != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: AuthCookieName, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
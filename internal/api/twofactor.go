@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"net/http"
+)
+
+func (h UsersHandler) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	enrollment, err := h.Service.EnrollTwoFactor(token)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "need to login", http.StatusUnauthorized)
+		case errors.Is(err, domain.ErrTwoFactorEnabled):
+			http.Error(w, "two-factor authentication is already enabled", http.StatusConflict)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, enrollment)
+}
+
+func (h UsersHandler) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var request domain.TwoFactorCodeRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	codes, err := h.Service.ConfirmTwoFactor(token, request.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "need to login", http.StatusUnauthorized)
+		case errors.Is(err, domain.ErrTwoFactorEnabled):
+			http.Error(w, "two-factor authentication is already enabled", http.StatusConflict)
+		case errors.Is(err, domain.ErrInvalidCode):
+			http.Error(w, "invalid code", http.StatusBadRequest)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, codes)
+}
+
+func (h UsersHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var request domain.TwoFactorDisableRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	err = h.Service.DisableTwoFactor(token, request)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "need to login", http.StatusUnauthorized)
+		case errors.Is(err, domain.ErrTwoFactorOff):
+			http.Error(w, "two-factor authentication is not enabled", http.StatusConflict)
+		case errors.Is(err, domain.ErrBadCredentials):
+			http.Error(w, "password is incorrect", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrInvalidCode):
+			http.Error(w, "invalid code", http.StatusBadRequest)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
+
+func (h UsersHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var request domain.TwoFactorLoginRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	user, err := h.Service.LoginTwoFactor(request, clientIP(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidToken):
+			http.Error(w, "login challenge is invalid or expired", http.StatusUnauthorized)
+		case errors.Is(err, domain.ErrInvalidCode):
+			http.Error(w, "invalid code", http.StatusUnauthorized)
+		case errors.Is(err, domain.ErrTooManyRequests), errors.Is(err, domain.ErrAccountLocked):
+			http.Error(w, "too many login attempts, try again later", http.StatusTooManyRequests)
+		case errors.Is(err, domain.ErrAccountDisabled):
+			http.Error(w, "account is disabled", http.StatusForbidden)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	h.startSession(w, user)
+}
@@ -3,24 +3,69 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"github.com/go-chi/chi/v5"
 	"github.com/krevetkou/test-rutube/internal/domain"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 )
 
 const CookieExpired = time.Hour * 24 * 365 * 10
 const AuthCookieName = "token"
+const DefaultUpcomingDays = 7
 
 type UsersService interface {
-	GetUsersByToken(token string) ([]domain.ProfileResponse, error)
+	Create(user domain.RegisterRequest) (domain.User, error)
+	GetUsersByToken(token string, query domain.ListQuery) (domain.ProfileListResponse, error)
 	GetAllUsers() ([]domain.UserInListResponse, error)
-	Login(actor domain.LoginRequest) (domain.UserResponse, error)
+	Login(actor domain.LoginRequest, ip string) (domain.UserResponse, error)
+	UnlockAccount(email string) error
 	CreateToken(email string) (string, error)
 	GetUserInfo(token string) (domain.UserResponse, error)
-	Subscribe(token string, userId int) error
+	GetProfile(token string, id int) (domain.ProfileResponse, error)
+	GetUpcomingBirthdays(token string, days int) ([]domain.UpcomingBirthdayResponse, error)
+	CreatePersonalToken(token string, request domain.CreateTokenRequest) (domain.CreatedTokenResponse, error)
+	GetPersonalTokens(token string) ([]domain.PersonalTokenResponse, error)
+	RevokePersonalToken(token string, id int) error
+	AuthorizePersonalToken(token, scope string) error
+	Authorize(token string, permission domain.Permission) error
+	GetUsers() ([]domain.AdminUserResponse, error)
+	SetRole(token string, id int, role domain.Role) (domain.AdminUserResponse, error)
+	SetDisabled(token string, id int, disabled bool) (domain.AdminUserResponse, error)
+	DeleteUser(token string, id int) error
+	UpdateUser(id int, settings domain.AdminSettingsRequest) (domain.AdminUserResponse, error)
+	LoginExternal(identity domain.ExternalIdentity) (domain.UserResponse, error)
+	ImportUsers(records []domain.ImportRecord, dryRun bool) (domain.ImportResult, error)
+	Subscribe(token string, userId int) (domain.SubscribeResponse, error)
+	SubscribeEvents(token string, lastEventID string) (<-chan domain.Event, func(), error)
+	CheckSession(token string) error
+	OpenDashboard(token string) (<-chan domain.Event, func(), error)
+	GetDashboard(token string, now time.Time) (domain.DashboardResponse, error)
+	CheckDashboard(token string) error
+	GetTeams(token string) ([]domain.TeamResponse, error)
+	GetTeamMembers(token string, teamID int) ([]domain.ProfileResponse, error)
+	SubscribeTeam(token string, teamID int) error
+	UnsubscribeTeam(token string, teamID int) error
 	Unsubscribe(token string, userId int) error
-	Settings(token string, daysToBirthday int, email string) error
+	Settings(token string, settings domain.SettingsRequest) (domain.UserResponse, error)
+	ConfirmEmail(token string) (domain.UserResponse, error)
+	RevertEmail(token string) (domain.UserResponse, error)
+	VerifyEmail(token string) error
+	ResendVerification(token string) error
+	ForgotPassword(email, ip string) error
+	ResetPassword(request domain.ResetPasswordRequest) error
+	ChangePassword(token string, request domain.ChangePasswordRequest) error
+	EnrollTwoFactor(token string) (domain.TwoFactorEnrollResponse, error)
+	ConfirmTwoFactor(token, code string) (domain.RecoveryCodesResponse, error)
+	DisableTwoFactor(token string, request domain.TwoFactorDisableRequest) error
+	LoginTwoFactor(request domain.TwoFactorLoginRequest, ip string) (domain.UserResponse, error)
+	GetFollowers(token string) ([]domain.FollowResponse, error)
+	GetFollowing(token string) ([]domain.FollowResponse, error)
+	GetBlocked(token string) ([]domain.FollowResponse, error)
+	RemoveFollower(token string, followerId int) error
+	Block(token string, userId int) error
+	Unblock(token string, userId int) error
 }
 
 type UsersHandler struct {
@@ -55,17 +100,32 @@ func (h UsersHandler) ListToday(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h UsersHandler) List(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie(AuthCookieName)
+	token, err := authToken(r)
 
 	if err != nil {
 		http.Error(w, "need to login", http.StatusUnauthorized)
 		return
 	}
 
-	users, err := h.Service.GetUsersByToken(cookie.Value)
+	query, err := parseListQuery(r)
 	if err != nil {
+		http.Error(w, "invalid query parameters", http.StatusBadRequest)
+		return
+	}
+
+	users, err := h.Service.GetUsersByToken(token, query)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidCursor):
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrInvalidQuery):
+			http.Error(w, "invalid query parameters", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "need to login", http.StatusUnauthorized)
+		default:
+			http.Error(w, "failed to get users", http.StatusInternalServerError)
+		}
 		log.Println(err)
-		http.Error(w, "failed to get users", http.StatusInternalServerError)
 		return
 	}
 	data, err := json.Marshal(users)
@@ -97,13 +157,15 @@ func (h UsersHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	createdUser, err := h.Service.Login(request)
+	createdUser, err := h.Service.Login(request, clientIP(r))
 	if err != nil {
 		switch {
-		case errors.Is(err, domain.ErrNotExists):
-			http.Error(w, "user doesn't exist", http.StatusConflict)
 		case errors.Is(err, domain.ErrBadCredentials):
-			http.Error(w, "incorrect password", http.StatusBadRequest)
+			http.Error(w, "email or password is incorrect", http.StatusUnauthorized)
+		case errors.Is(err, domain.ErrTooManyRequests), errors.Is(err, domain.ErrAccountLocked):
+			http.Error(w, "too many login attempts, try again later", http.StatusTooManyRequests)
+		case errors.Is(err, domain.ErrAccountDisabled):
+			http.Error(w, "account is disabled", http.StatusForbidden)
 		default:
 			http.Error(w, "unexpected error", http.StatusInternalServerError)
 		}
@@ -112,19 +174,33 @@ func (h UsersHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := json.Marshal(createdUser)
-	if err != nil {
-		http.Error(w, "failed to create response data", http.StatusInternalServerError)
-		log.Println(err)
+	if createdUser.TwoFactorRequired {
+		writeJSON(w, http.StatusOK, domain.TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    createdUser.ChallengeToken,
+		})
 		return
 	}
 
-	w.Header().Add("Content-Type", "application/json")
+	h.startSession(w, createdUser)
+}
+
+// startSession creates a session token for the user, sets it as the auth cookie
+// and writes the user to the response.
+func (h UsersHandler) startSession(w http.ResponseWriter, user domain.UserResponse) {
+	if !h.setSessionCookie(w, user) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
 
-	token, err := h.Service.CreateToken(request.Email)
+func (h UsersHandler) setSessionCookie(w http.ResponseWriter, user domain.UserResponse) bool {
+	token, err := h.Service.CreateToken(user.Email)
 	if err != nil {
+		http.Error(w, "failed to create session", http.StatusInternalServerError)
 		log.Println(err)
-		return
+		return false
 	}
 
 	authCookie := http.Cookie{
@@ -136,23 +212,19 @@ func (h UsersHandler) Login(w http.ResponseWriter, r *http.Request) {
 		SameSite: http.SameSiteNoneMode,
 	}
 	http.SetCookie(w, &authCookie)
-	w.WriteHeader(http.StatusOK)
-	_, err = w.Write(data)
-	if err != nil {
-		log.Println(err)
-		return
-	}
+
+	return true
 }
 
 func (h UsersHandler) GetUserInfo(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie(AuthCookieName)
+	token, err := authToken(r)
 
 	if err != nil {
 		http.Error(w, "need to login", http.StatusUnauthorized)
 		return
 	}
 
-	user, err := h.Service.GetUserInfo(cookie.Value)
+	user, err := h.Service.GetUserInfo(token)
 	if err != nil {
 		http.Error(w, "failed to get profile", http.StatusBadRequest)
 		log.Println(err)
@@ -175,43 +247,69 @@ func (h UsersHandler) GetUserInfo(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h UsersHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
-	if r.Header.Get("Content-Type") != "application/json" {
-		http.Error(w, "content type not allowed", http.StatusUnsupportedMediaType)
+func (h UsersHandler) Upcoming(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
 		return
 	}
 
-	var request domain.SubscribeRequest
-	err := json.NewDecoder(r.Body).Decode(&request)
+	days := DefaultUpcomingDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		days, err = strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "invalid query parameters", http.StatusBadRequest)
+			return
+		}
+	}
+
+	users, err := h.Service.GetUpcomingBirthdays(token, days)
 	if err != nil {
-		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		switch {
+		case errors.Is(err, domain.ErrInvalidQuery):
+			http.Error(w, "days must be between 0 and 365", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "need to login", http.StatusUnauthorized)
+		default:
+			http.Error(w, "failed to get users", http.StatusInternalServerError)
+		}
 		log.Println(err)
 		return
 	}
 
-	cookie, err := r.Cookie(AuthCookieName)
+	writeJSON(w, http.StatusOK, users)
+}
+
+func (h UsersHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
 
 	if err != nil {
 		http.Error(w, "need to login", http.StatusUnauthorized)
 		return
 	}
 
-	err = h.Service.Subscribe(cookie.Value, request.UserId)
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := h.Service.GetProfile(token, id)
 	if err != nil {
 		switch {
-		case errors.Is(err, domain.ErrExists):
-			http.Error(w, "subscribe exist", http.StatusConflict)
 		case errors.Is(err, domain.ErrNotExists):
-			http.Error(w, "user doesn't exist", http.StatusConflict)
+			http.Error(w, "need to login", http.StatusUnauthorized)
+		case errors.Is(err, domain.ErrNotFound):
+			http.Error(w, "user not found", http.StatusNotFound)
 		default:
-			http.Error(w, "unexpected error", http.StatusInternalServerError)
+			http.Error(w, "failed to get profile", http.StatusInternalServerError)
 		}
 		log.Println(err)
-
 		return
 	}
 
-	data, err := json.Marshal(domain.DefaultResponse{Success: true})
+	data, err := json.Marshal(profile)
 	if err != nil {
 		http.Error(w, "failed to create response data", http.StatusInternalServerError)
 		log.Println(err)
@@ -227,7 +325,7 @@ func (h UsersHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h UsersHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+func (h UsersHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
 	if r.Header.Get("Content-Type") != "application/json" {
 		http.Error(w, "content type not allowed", http.StatusUnsupportedMediaType)
 		return
@@ -241,18 +339,30 @@ func (h UsersHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cookie, err := r.Cookie(AuthCookieName)
+	token, err := authToken(r)
 
 	if err != nil {
 		http.Error(w, "need to login", http.StatusUnauthorized)
 		return
 	}
 
-	err = h.Service.Unsubscribe(cookie.Value, request.UserId)
+	response, err := h.Service.Subscribe(token, request.UserId)
 	if err != nil {
 		switch {
+		case errors.Is(err, domain.ErrExists):
+			http.Error(w, "subscribe exist", http.StatusConflict)
 		case errors.Is(err, domain.ErrNotExists):
-			http.Error(w, "user or subscribe doesn't exist", http.StatusConflict)
+			http.Error(w, "user doesn't exist", http.StatusConflict)
+		case errors.Is(err, domain.ErrNotFound):
+			http.Error(w, "user not found", http.StatusNotFound)
+		case errors.Is(err, domain.ErrSelfSubscribe):
+			http.Error(w, "can't subscribe to yourself", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrFollowLimit):
+			http.Error(w, "follow limit reached", http.StatusConflict)
+		case errors.Is(err, domain.ErrBlocked):
+			http.Error(w, "user blocked subscriptions from you", http.StatusForbidden)
+		case errors.Is(err, domain.ErrTeamOnly):
+			http.Error(w, domain.ErrTeamOnly.Error(), http.StatusForbidden)
 		default:
 			http.Error(w, "unexpected error", http.StatusInternalServerError)
 		}
@@ -261,7 +371,13 @@ func (h UsersHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := json.Marshal(domain.DefaultResponse{Success: true})
+	// a follow request waits for the approval of the user
+	status := http.StatusOK
+	if response.Pending {
+		status = http.StatusAccepted
+	}
+
+	data, err := json.Marshal(response)
 	if err != nil {
 		http.Error(w, "failed to create response data", http.StatusInternalServerError)
 		log.Println(err)
@@ -269,7 +385,7 @@ func (h UsersHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(status)
 	_, err = w.Write(data)
 	if err != nil {
 		log.Println(err)
@@ -277,13 +393,13 @@ func (h UsersHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h UsersHandler) Settings(w http.ResponseWriter, r *http.Request) {
+func (h UsersHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
 	if r.Header.Get("Content-Type") != "application/json" {
 		http.Error(w, "content type not allowed", http.StatusUnsupportedMediaType)
 		return
 	}
 
-	var request domain.SettingsRequest
+	var request domain.SubscribeRequest
 	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		http.Error(w, "failed to read request body", http.StatusBadRequest)
@@ -291,18 +407,18 @@ func (h UsersHandler) Settings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cookie, err := r.Cookie(AuthCookieName)
+	token, err := authToken(r)
 
 	if err != nil {
 		http.Error(w, "need to login", http.StatusUnauthorized)
 		return
 	}
 
-	err = h.Service.Settings(cookie.Value, request.DaysToNotification, request.Email)
+	err = h.Service.Unsubscribe(token, request.UserId)
 	if err != nil {
 		switch {
 		case errors.Is(err, domain.ErrNotExists):
-			http.Error(w, "user doesn't exist", http.StatusConflict)
+			http.Error(w, "user or subscribe doesn't exist", http.StatusConflict)
 		default:
 			http.Error(w, "unexpected error", http.StatusInternalServerError)
 		}
@@ -326,3 +442,65 @@ func (h UsersHandler) Settings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+func (h UsersHandler) Settings(w http.ResponseWriter, r *http.Request) {
+	var request domain.SettingsRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.Service.Settings(token, request)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "user doesn't exist", http.StatusConflict)
+		case errors.Is(err, domain.ErrExists):
+			http.Error(w, "email is already taken", http.StatusConflict)
+		case errors.Is(err, domain.ErrInvalidSettings):
+			http.Error(w, "invalid settings values", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrMailNotSent):
+			http.Error(w, "failed to send confirmation email", http.StatusInternalServerError)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+func parseListQuery(r *http.Request) (domain.ListQuery, error) {
+	values := r.URL.Query()
+	query := domain.ListQuery{
+		Cursor: values.Get("cursor"),
+		Search: values.Get("q"),
+		Sort:   domain.ListSort(values.Get("sort")),
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil {
+			return domain.ListQuery{}, err
+		}
+		query.Limit = value
+	}
+
+	if subscribed := values.Get("subscribed"); subscribed != "" {
+		value, err := strconv.ParseBool(subscribed)
+		if err != nil {
+			return domain.ListQuery{}, err
+		}
+		query.Subscribed = &value
+	}
+
+	return query, nil
+}
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/krevetkou/test-rutube/internal/auth"
+	"github.com/krevetkou/test-rutube/internal/config"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"github.com/krevetkou/test-rutube/internal/services"
+	"github.com/krevetkou/test-rutube/internal/storage"
+)
+
+type discardMailer struct{}
+
+func (discardMailer) Send(to, subject, body string) error {
+	return nil
+}
+
+// newTestHandler returns a handler on an empty storage.
+func newTestHandler(t *testing.T, cfg config.Config) (UsersHandler, *storage.Storage) {
+	t.Helper()
+
+	s := storage.NewStorage()
+
+	return NewUsersHandler(newTestService(s, nil, cfg)), s
+}
+
+func newTestService(s *storage.Storage, events services.EventBroker, cfg config.Config) services.UsersService {
+	notifications := services.NewNotificationsService(s, discardMailer{}, events, nil)
+	followRequests := services.NewFollowRequestsService(s, discardMailer{}, events, notifications, cfg)
+
+	return services.NewUserService(s, discardMailer{}, auth.NewSigner("secret"), services.PasswordPolicy{MinLength: 8}, events,
+		notifications, followRequests, cfg)
+}
+
+// login adds a verified user and returns their session token.
+func login(t *testing.T, h UsersHandler, s *storage.Storage, email string) string {
+	t.Helper()
+
+	user, err := s.InsertUser(domain.RegisterRequest{Email: email, Name: email, DateOfBirth: "1990-01-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.MarkVerified(user.ID, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := h.Service.CreateToken(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+// serve sends the request to handler mounted on pattern. A string body is
+// sent as is, other non-nil bodies are encoded to JSON.
+func serve(t *testing.T, handler http.HandlerFunc, pattern, method, target, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader bytes.Reader
+	switch body := body.(type) {
+	case nil:
+	case string:
+		reader.Reset([]byte(body))
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader.Reset(data)
+	}
+
+	r := httptest.NewRequest(method, target, &reader)
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		r.AddCookie(&http.Cookie{Name: AuthCookieName, Value: token})
+	}
+
+	router := chi.NewRouter()
+	router.Method(method, pattern, handler)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestList(t *testing.T) {
+	h, s := newTestHandler(t, config.Config{})
+	token := login(t, h, s, "anna@corp.ru")
+	login(t, h, s, "boris@corp.ru")
+	login(t, h, s, "vera@corp.ru")
+	login(t, h, s, "gleb@corp.ru")
+
+	tests := []struct {
+		name       string
+		query      string
+		token      string
+		wantStatus int
+		wantUsers  int
+	}{
+		{name: "first page", query: "?limit=2&sort=name", token: token, wantStatus: http.StatusOK, wantUsers: 2},
+		{name: "without yourself", token: token, wantStatus: http.StatusOK, wantUsers: 3},
+		{name: "search", query: "?q=boris", token: token, wantStatus: http.StatusOK, wantUsers: 1},
+		{name: "not logged in", wantStatus: http.StatusUnauthorized},
+		{name: "unknown token", token: "nobody", wantStatus: http.StatusUnauthorized},
+		{name: "limit isn't a number", query: "?limit=ten", token: token, wantStatus: http.StatusBadRequest},
+		{name: "negative limit", query: "?limit=-1", token: token, wantStatus: http.StatusBadRequest},
+		{name: "unknown sort", query: "?sort=age", token: token, wantStatus: http.StatusBadRequest},
+		{name: "invalid cursor", query: "?cursor=***", token: token, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, h.List, "/user/list", http.MethodGet, "/user/list"+tt.query, tt.token, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var page domain.ProfileListResponse
+			if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Users) != tt.wantUsers {
+				t.Errorf("%d users, want %d", len(page.Users), tt.wantUsers)
+			}
+		})
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	h, s := newTestHandler(t, config.Config{MaxFollows: 2})
+	anna := login(t, h, s, "anna@corp.ru")
+	login(t, h, s, "boris@corp.ru")
+	login(t, h, s, "vera@corp.ru")
+	login(t, h, s, "gleb@corp.ru")
+
+	// the steps share the storage and run in order
+	steps := []struct {
+		name       string
+		token      string
+		userID     int
+		wantStatus int
+	}{
+		{name: "not logged in", userID: 2, wantStatus: http.StatusUnauthorized},
+		{name: "yourself", token: anna, userID: 1, wantStatus: http.StatusBadRequest},
+		{name: "unknown user", token: anna, userID: 100, wantStatus: http.StatusNotFound},
+		{name: "subscribes", token: anna, userID: 2, wantStatus: http.StatusOK},
+		{name: "twice", token: anna, userID: 2, wantStatus: http.StatusConflict},
+		{name: "up to the follow limit", token: anna, userID: 3, wantStatus: http.StatusOK},
+		{name: "over the follow limit", token: anna, userID: 4, wantStatus: http.StatusConflict},
+	}
+	for _, step := range steps {
+		w := serve(t, h.Subscribe, "/user/subscribe", http.MethodPost, "/user/subscribe", step.token,
+			domain.SubscribeRequest{UserId: step.userID})
+		if w.Code != step.wantStatus {
+			t.Fatalf("%s: status %d, want %d: %s", step.name, w.Code, step.wantStatus, w.Body)
+		}
+	}
+}
+
+func TestGetProfile(t *testing.T) {
+	h, s := newTestHandler(t, config.Config{})
+	anna := login(t, h, s, "anna@corp.ru")
+	boris := login(t, h, s, "boris@corp.ru")
+	if _, err := h.Service.Subscribe(boris, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		target     string
+		token      string
+		wantStatus int
+	}{
+		{name: "profile", target: "/user/1", token: boris, wantStatus: http.StatusOK},
+		{name: "own profile", target: "/user/1", token: anna, wantStatus: http.StatusOK},
+		{name: "unknown user", target: "/user/100", token: anna, wantStatus: http.StatusNotFound},
+		{name: "not logged in", target: "/user/1", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, h.GetProfile, "/user/{id:[0-9]+}", http.MethodGet, tt.target, tt.token, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var profile domain.ProfileResponse
+			if err := json.NewDecoder(w.Body).Decode(&profile); err != nil {
+				t.Fatal(err)
+			}
+			if profile.ID != 1 || profile.FollowersCount != 1 || profile.FollowingCount != 0 {
+				t.Errorf("profile %+v, want user 1 with one follower", profile)
+			}
+		})
+	}
+}
+
+func TestSettings(t *testing.T) {
+	h, s := newTestHandler(t, config.Config{})
+	anna := login(t, h, s, "anna@corp.ru")
+	login(t, h, s, "boris@corp.ru")
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "changes the days", body: `{"daysToNotification":5}`, wantStatus: http.StatusOK},
+		{name: "invalid days", body: `{"daysToNotification":1000}`, wantStatus: http.StatusBadRequest},
+		{name: "taken email", body: `{"email":"boris@corp.ru"}`, wantStatus: http.StatusConflict},
+		{name: "not json", body: `days=5`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, h.Settings, "/", http.MethodPost, "/", anna, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"errors"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"net/http"
+)
+
+func (h UsersHandler) Register(w http.ResponseWriter, r *http.Request) {
+	var request domain.RegisterRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	user, err := h.Service.Create(request)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrFieldsRequired):
+			http.Error(w, "all required fields must have values", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrInvalidSettings):
+			http.Error(w, "invalid field values", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrWeakPassword):
+			http.Error(w, "password is too weak", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrBreachedPassword):
+			http.Error(w, "password was found in a data breach, choose another one", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrExists):
+			http.Error(w, "user already exists", http.StatusConflict)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, domain.UserResponse{
+		Email:              user.Email,
+		Name:               user.Name,
+		DateOfBirth:        user.DateOfBirth,
+		DaysToNotification: user.DaysToNotification,
+		Verified:           user.Verified,
+	})
+}
+
+func (h UsersHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	err := h.Service.VerifyEmail(token)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidToken):
+			http.Error(w, "invalid or expired token", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrVerified):
+			http.Error(w, "email is already verified", http.StatusConflict)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
+
+func (h UsersHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	err = h.Service.ResendVerification(token)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			http.Error(w, "need to login", http.StatusUnauthorized)
+		case errors.Is(err, domain.ErrVerified):
+			http.Error(w, "email is already verified", http.StatusConflict)
+		case errors.Is(err, domain.ErrTooManyRequests):
+			http.Error(w, "verification email was sent recently, try again later", http.StatusTooManyRequests)
+		case errors.Is(err, domain.ErrMailNotSent):
+			http.Error(w, "failed to send email", http.StatusInternalServerError)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
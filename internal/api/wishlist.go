@@ -0,0 +1,202 @@
+package api
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type WishlistService interface {
+	GetWishlist(token string) ([]domain.WishItemResponse, error)
+	GetUserWishlist(token string, userId int) ([]domain.WishItemResponse, error)
+	AddWishItem(token string, request domain.WishItemRequest) (domain.WishItemResponse, error)
+	UpdateWishItem(token string, id int, request domain.WishItemUpdateRequest) (domain.WishItemResponse, error)
+	DeleteWishItem(token string, id int) error
+	ReserveWishItem(token string, userId, id int) (domain.WishItemResponse, error)
+	ReleaseWishItem(token string, userId, id int) (domain.WishItemResponse, error)
+}
+
+type WishlistHandler struct {
+	Service WishlistService
+}
+
+func NewWishlistHandler(service WishlistService) WishlistHandler {
+	return WishlistHandler{
+		Service: service,
+	}
+}
+
+func (h WishlistHandler) Wishlist(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	items, err := h.Service.GetWishlist(token)
+	if err != nil {
+		writeWishlistError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (h WishlistHandler) UserWishlist(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	items, err := h.Service.GetUserWishlist(token, id)
+	if err != nil {
+		writeWishlistError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (h WishlistHandler) AddWishItem(w http.ResponseWriter, r *http.Request) {
+	var request domain.WishItemRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	item, err := h.Service.AddWishItem(token, request)
+	if err != nil {
+		writeWishlistError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, item)
+}
+
+func (h WishlistHandler) UpdateWishItem(w http.ResponseWriter, r *http.Request) {
+	var request domain.WishItemUpdateRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid item id", http.StatusBadRequest)
+		return
+	}
+
+	item, err := h.Service.UpdateWishItem(token, id, request)
+	if err != nil {
+		writeWishlistError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, item)
+}
+
+func (h WishlistHandler) DeleteWishItem(w http.ResponseWriter, r *http.Request) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid item id", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Service.DeleteWishItem(token, id)
+	if err != nil {
+		writeWishlistError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, domain.DefaultResponse{Success: true})
+}
+
+func (h WishlistHandler) ReserveWishItem(w http.ResponseWriter, r *http.Request) {
+	h.reservation(w, r, h.Service.ReserveWishItem)
+}
+
+func (h WishlistHandler) ReleaseWishItem(w http.ResponseWriter, r *http.Request) {
+	h.reservation(w, r, h.Service.ReleaseWishItem)
+}
+
+func (h WishlistHandler) reservation(w http.ResponseWriter, r *http.Request, change func(token string, userId, id int) (domain.WishItemResponse, error)) {
+	token, err := authToken(r)
+
+	if err != nil {
+		http.Error(w, "need to login", http.StatusUnauthorized)
+		return
+	}
+
+	userId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "itemId"))
+	if err != nil {
+		http.Error(w, "invalid item id", http.StatusBadRequest)
+		return
+	}
+
+	item, err := change(token, userId, id)
+	if err != nil {
+		writeWishlistError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, item)
+}
+
+func writeWishlistError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrNotExists):
+		http.Error(w, "need to login", http.StatusUnauthorized)
+	case errors.Is(err, domain.ErrNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, domain.ErrNotFollowing):
+		http.Error(w, domain.ErrNotFollowing.Error(), http.StatusForbidden)
+	case errors.Is(err, domain.ErrExists):
+		http.Error(w, "item is already reserved", http.StatusConflict)
+	case errors.Is(err, domain.ErrFieldsRequired):
+		http.Error(w, "title is required", http.StatusBadRequest)
+	case errors.Is(err, domain.ErrInvalidWishItem):
+		http.Error(w, domain.ErrInvalidWishItem.Error(), http.StatusBadRequest)
+	case errors.Is(err, domain.ErrWishlistFull):
+		http.Error(w, domain.ErrWishlistFull.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "unexpected error", http.StatusInternalServerError)
+	}
+	log.Println(err)
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// RandomToken returns a random url-safe secret and its hash,
+// only the hash should be stored.
+func RandomToken() (string, string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	return token, HashToken(token), nil
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+var (
+	dummyOnce sync.Once
+	dummyHash string
+)
+
+// CheckNoUser spends the same time as CheckPassword when the user doesn't exist,
+// so response timing doesn't reveal registered emails.
+func CheckNoUser(password string) {
+	dummyOnce.Do(func() {
+		dummyHash, _ = HashPassword("dummy-password")
+	})
+
+	CheckPassword(dummyHash, password)
+}
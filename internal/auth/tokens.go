@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+const (
+	PurposeEmailConfirm = "email-confirm"
+	PurposeEmailRevert  = "email-revert"
+	PurposeVerify       = "verify"
+	PurposeTwoFactor    = "2fa-challenge"
+	PurposeSession      = "session"
+)
+
+// Claims of the one-purpose tokens sent by email. Subject is the user ID,
+// so the tokens survive an email change.
+type Claims struct {
+	jwt.StandardClaims
+	Purpose string `json:"purpose"`
+	Email   string `json:"email,omitempty"`
+}
+
+func (c Claims) UserID() (int, error) {
+	return strconv.Atoi(c.Subject)
+}
+
+type Signer struct {
+	secret []byte
+}
+
+func NewSigner(secret string) Signer {
+	return Signer{
+		secret: []byte(secret),
+	}
+}
+
+func (s Signer) Sign(userID int, purpose, email string, ttl time.Duration) (string, error) {
+	// the ID keeps tokens issued in the same second different
+	id, _, err := RandomToken()
+	if err != nil {
+		return "", domain.ErrTokenNotCreated
+	}
+
+	now := time.Now()
+	claims := Claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        id,
+			Subject:   strconv.Itoa(userID),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+		},
+		Purpose: purpose,
+		Email:   email,
+	}
+
+	t, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+	if err != nil {
+		return "", domain.ErrTokenNotCreated
+	}
+
+	return t, nil
+}
+
+func (s Signer) Parse(token, purpose string) (Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, domain.ErrInvalidToken
+		}
+		return s.secret, nil
+	})
+	if err != nil || claims.Purpose != purpose {
+		return Claims{}, domain.ErrInvalidToken
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func TestSignerParse(t *testing.T) {
+	signer := NewSigner("secret")
+
+	valid, err := signer.Sign(7, PurposeEmailConfirm, "user@corp.ru", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := signer.Sign(7, PurposeEmailConfirm, "user@corp.ru", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSecret, err := NewSigner("other").Sign(7, PurposeEmailConfirm, "user@corp.ru", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, Claims{Purpose: PurposeEmailConfirm}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		purpose string
+		wantErr bool
+	}{
+		{name: "valid", token: valid, purpose: PurposeEmailConfirm},
+		{name: "other purpose", token: valid, purpose: PurposeEmailRevert, wantErr: true},
+		{name: "verification purpose", token: valid, purpose: PurposeVerify, wantErr: true},
+		{name: "expired", token: expired, purpose: PurposeEmailConfirm, wantErr: true},
+		{name: "other secret", token: otherSecret, purpose: PurposeEmailConfirm, wantErr: true},
+		{name: "unsigned", token: none, purpose: PurposeEmailConfirm, wantErr: true},
+		{name: "garbage", token: "not.a.token", purpose: PurposeEmailConfirm, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := signer.Parse(tt.token, tt.purpose)
+			if tt.wantErr {
+				if !errors.Is(err, domain.ErrInvalidToken) {
+					t.Errorf("Parse() error = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			id, err := claims.UserID()
+			if err != nil || id != 7 || claims.Email != "user@corp.ru" {
+				t.Errorf("Parse() = user %d (%v), email %q, want 7, user@corp.ru", id, err, claims.Email)
+			}
+		})
+	}
+}
+
+func TestSignerSignUnique(t *testing.T) {
+	signer := NewSigner("secret")
+
+	first, err := signer.Sign(1, PurposeSession, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := signer.Sign(1, PurposeSession, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Error("two sessions issued in the same second are equal")
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := HashPassword("Correct-horse-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{password: "Correct-horse-1", want: true},
+		{password: "correct-horse-1", want: false},
+		{password: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := CheckPassword(hash, tt.password); got != tt.want {
+			t.Errorf("CheckPassword(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateTOTP(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		now      int64
+		wantStep int64
+		wantOK   bool
+	}{
+		// the codes are the last six digits of the RFC 6238 vectors
+		{name: "rfc vector 59", secret: rfcSecret, code: "287082", now: 59, wantStep: 1, wantOK: true},
+		{name: "rfc vector 1111111109", secret: rfcSecret, code: "081804", now: 1111111109, wantStep: 37037036, wantOK: true},
+		{name: "rfc vector 1234567890", secret: rfcSecret, code: "005924", now: 1234567890, wantStep: 41152263, wantOK: true},
+		{name: "rfc vector 2000000000", secret: rfcSecret, code: "279037", now: 2000000000, wantStep: 66666666, wantOK: true},
+		{name: "lower case secret", secret: strings.ToLower(rfcSecret), code: "287082", now: 59, wantStep: 1, wantOK: true},
+		{name: "spaces in the code", secret: rfcSecret, code: "287 082", now: 59, wantStep: 1, wantOK: true},
+		{name: "previous period", secret: rfcSecret, code: "287082", now: 89, wantStep: 1, wantOK: true},
+		{name: "next period", secret: rfcSecret, code: "081804", now: 1111111109 - TOTPPeriod, wantStep: 37037036, wantOK: true},
+		{name: "two periods late", secret: rfcSecret, code: "287082", now: 59 + 2*TOTPPeriod},
+		{name: "two periods early", secret: rfcSecret, code: "081804", now: 1111111109 - 2*TOTPPeriod},
+		{name: "wrong code", secret: rfcSecret, code: "287083", now: 59},
+		{name: "short code", secret: rfcSecret, code: "28708", now: 59},
+		{name: "long code", secret: rfcSecret, code: "2870820", now: 59},
+		{name: "empty code", secret: rfcSecret, code: "", now: 59},
+		{name: "invalid secret", secret: "not base32!", code: "287082", now: 59},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(tt.secret, tt.code, time.Unix(tt.now, 0))
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("ValidateTOTP() = %d, %v, want %d, %v", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q isn't base32: %v", secret, err)
+	}
+	code := totpCode(key, now.Unix()/TOTPPeriod)
+	if _, ok := ValidateTOTP(secret, code, now); !ok {
+		t.Errorf("the current code %s of a new secret isn't valid", code)
+	}
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("got %d codes, want 10", len(codes))
+	}
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' || code != NormalizeRecoveryCode(code) {
+			t.Errorf("code %q isn't in the xxxxx-xxxxx format", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q is repeated", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{code: "abcde-fghij", want: "abcde-fghij"},
+		{code: "ABCDE-FGHIJ", want: "abcde-fghij"},
+		{code: "  abcde-fghij\n", want: "abcde-fghij"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeRecoveryCode(tt.code); got != tt.want {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
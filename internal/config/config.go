@@ -0,0 +1,210 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+type Config struct {
+	Addr        string
+	BaseURL     string
+	FrontendURL string
+	MaxFollows  int
+	TokenSecret string
+
+	VerificationResendInterval time.Duration
+	PasswordResetTTL           time.Duration
+	// PasswordForgotPerIP is how many reset letters one IP may request per hour.
+	PasswordForgotPerIP int
+	PasswordMinLength   int
+	// BreachedPasswordsFile is a list of leaked passwords that can't be used.
+	BreachedPasswordsFile string
+
+	// LoginPerIP and LoginPerAccount are login attempts allowed per minute.
+	LoginPerIP       int
+	LoginPerAccount  int
+	LoginMaxFailures int
+	LoginLockout     time.Duration
+	LoginMaxLockout  time.Duration
+
+	TwoFactorIssuer       string
+	TwoFactorChallengeTTL time.Duration
+
+	// AdminEmail is the account that gets the admin role on start, it is created
+	// with AdminPassword when it doesn't exist.
+	AdminEmail    string
+	AdminPassword string
+
+	// OIDCIssuer enables single sign-on through the OpenID Connect provider.
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCScopes       string
+	// OIDCBirthdateClaim is the ID token claim with the date of birth, empty to not read it.
+	OIDCBirthdateClaim string
+
+	// DirectoryURL is an ldap:// server with StartTLS or an ldaps:// one,
+	// DirectoryLDIF an export file used instead of it. The sync is off when
+	// both are empty.
+	DirectoryURL          string
+	DirectoryBindDN       string
+	DirectoryPassword     string
+	DirectoryBaseDN       string
+	DirectoryFilter       string
+	DirectoryLDIF         string
+	DirectorySyncInterval time.Duration
+	// Directory attributes of the user fields.
+	DirectoryEmailAttr      string
+	DirectoryNameAttr       string
+	DirectoryBirthdateAttr  string
+	DirectoryDepartmentAttr string
+
+	// GreetingWindowDays is how many days before and after the birthday
+	// colleagues can leave greetings.
+	GreetingWindowDays int
+	// ReminderInterval is how often due birthday reminders are looked for.
+	ReminderInterval time.Duration
+	// EventsHeartbeat is how often idle event streams get a heartbeat.
+	EventsHeartbeat time.Duration
+	// EventsHistory is how many recent events of every user are kept for
+	// resuming the streams.
+	EventsHistory int
+
+	// TimeZone is where the office is, the dashboards switch to the next
+	// day at its midnight.
+	TimeZone *time.Location
+	// DashboardDays is how many days ahead the dashboards show birthdays.
+	DashboardDays int
+	// DashboardOrigins are the space separated origins of other hosts whose
+	// pages may open the dashboard websocket.
+	DashboardOrigins string
+
+	// BotToken turns the chat bot on. BotAPIURL is the Telegram Bot API
+	// or a compatible server.
+	BotToken  string
+	BotAPIURL string
+
+	SMTPAddr     string
+	SMTPFrom     string
+	SMTPUsername string
+	SMTPPassword string
+}
+
+func Load() Config {
+	baseURL := getString("APP_BASE_URL", "http://localhost:8080")
+
+	return Config{
+		Addr:        getString("APP_ADDR", ":8080"),
+		BaseURL:     baseURL,
+		FrontendURL: getString("APP_FRONTEND_URL", "http://localhost:3000"),
+		MaxFollows:  getInt("APP_MAX_FOLLOWS", 100),
+		TokenSecret: getString("APP_TOKEN_SECRET", ""),
+
+		VerificationResendInterval: getDuration("APP_VERIFICATION_RESEND_INTERVAL", time.Minute),
+		PasswordResetTTL:           getDuration("APP_PASSWORD_RESET_TTL", time.Hour),
+		PasswordForgotPerIP:        getInt("APP_PASSWORD_FORGOT_PER_IP", 5),
+		PasswordMinLength:          getInt("APP_PASSWORD_MIN_LENGTH", 8),
+		BreachedPasswordsFile:      getString("APP_BREACHED_PASSWORDS_FILE", ""),
+
+		LoginPerIP:       getInt("APP_LOGIN_PER_IP", 30),
+		LoginPerAccount:  getInt("APP_LOGIN_PER_ACCOUNT", 10),
+		LoginMaxFailures: getInt("APP_LOGIN_MAX_FAILURES", 5),
+		LoginLockout:     getDuration("APP_LOGIN_LOCKOUT", time.Minute),
+		LoginMaxLockout:  getDuration("APP_LOGIN_MAX_LOCKOUT", time.Hour*24),
+
+		TwoFactorIssuer:       getString("APP_2FA_ISSUER", "Birthdays"),
+		TwoFactorChallengeTTL: getDuration("APP_2FA_CHALLENGE_TTL", time.Minute*5),
+
+		AdminEmail:    getString("APP_ADMIN_EMAIL", ""),
+		AdminPassword: getString("APP_ADMIN_PASSWORD", ""),
+
+		OIDCIssuer:         getString("APP_OIDC_ISSUER", ""),
+		OIDCClientID:       getString("APP_OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:   getString("APP_OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:    getString("APP_OIDC_REDIRECT_URL", baseURL+"/user/oidc/callback"),
+		OIDCScopes:         getString("APP_OIDC_SCOPES", "openid email profile"),
+		OIDCBirthdateClaim: getString("APP_OIDC_BIRTHDATE_CLAIM", "birthdate"),
+
+		DirectoryURL:            getString("APP_DIRECTORY_URL", ""),
+		DirectoryBindDN:         getString("APP_DIRECTORY_BIND_DN", ""),
+		DirectoryPassword:       getString("APP_DIRECTORY_PASSWORD", ""),
+		DirectoryBaseDN:         getString("APP_DIRECTORY_BASE_DN", ""),
+		DirectoryFilter:         getString("APP_DIRECTORY_FILTER", "(objectClass=person)"),
+		DirectoryLDIF:           getString("APP_DIRECTORY_LDIF", ""),
+		DirectorySyncInterval:   getInterval("APP_DIRECTORY_SYNC_INTERVAL", time.Hour),
+		DirectoryEmailAttr:      getString("APP_DIRECTORY_EMAIL_ATTR", "mail"),
+		DirectoryNameAttr:       getString("APP_DIRECTORY_NAME_ATTR", "displayName"),
+		DirectoryBirthdateAttr:  getString("APP_DIRECTORY_BIRTHDATE_ATTR", "birthDate"),
+		DirectoryDepartmentAttr: getString("APP_DIRECTORY_DEPARTMENT_ATTR", "department"),
+
+		GreetingWindowDays: getInt("APP_GREETING_WINDOW_DAYS", 3),
+		ReminderInterval:   getInterval("APP_REMINDER_INTERVAL", time.Hour),
+		EventsHeartbeat:    getInterval("APP_EVENTS_HEARTBEAT", 15*time.Second),
+		EventsHistory:      getInt("APP_EVENTS_HISTORY", 100),
+
+		TimeZone:         getLocation("APP_TIMEZONE", time.UTC),
+		DashboardDays:    getInt("APP_DASHBOARD_DAYS", 14),
+		DashboardOrigins: getString("APP_DASHBOARD_ORIGINS", ""),
+
+		BotToken:  getString("APP_BOT_TOKEN", ""),
+		BotAPIURL: getString("APP_BOT_API_URL", "https://api.telegram.org"),
+
+		SMTPAddr:     getString("SMTP_ADDR", ""),
+		SMTPFrom:     getString("SMTP_FROM", "birthdays@localhost"),
+		SMTPUsername: getString("SMTP_USERNAME", ""),
+		SMTPPassword: getString("SMTP_PASSWORD", ""),
+	}
+}
+
+func getString(key, fallback string) string {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	return value
+}
+
+func getInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+
+	return value
+}
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+
+	return value
+}
+
+// getInterval reads the period of a ticker, which must be positive.
+func getInterval(key string, fallback time.Duration) time.Duration {
+	value := getDuration(key, fallback)
+	if value <= 0 {
+		return fallback
+	}
+
+	return value
+}
+
+func getLocation(key string, fallback *time.Location) *time.Location {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	location, err := time.LoadLocation(value)
+	if err != nil {
+		return fallback
+	}
+
+	return location
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetInterval(t *testing.T) {
+	const fallback = time.Minute
+
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{name: "unset", value: "", want: fallback},
+		{name: "set", value: "15s", want: 15 * time.Second},
+		{name: "zero", value: "0s", want: fallback},
+		{name: "negative", value: "-5m", want: fallback},
+		{name: "invalid", value: "soon", want: fallback},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("APP_TEST_INTERVAL", tt.value)
+			if got := getInterval("APP_TEST_INTERVAL", fallback); got != tt.want {
+				t.Errorf("getInterval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
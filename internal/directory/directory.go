@@ -0,0 +1,75 @@
+// Package directory reads employees from LDAP or from an LDIF export.
+package directory
+
+import (
+	"context"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"strings"
+	"time"
+)
+
+// Entry is a directory object, attribute names are lower case.
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+func (e Entry) Get(attribute string) string {
+	values := e.Attributes[strings.ToLower(attribute)]
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+type Source interface {
+	Entries(ctx context.Context) ([]Entry, error)
+}
+
+// Mapping names the attributes that hold the user fields.
+type Mapping struct {
+	Email       string
+	Name        string
+	DateOfBirth string
+	Department  string
+}
+
+// Attributes returns the attributes to ask the server for.
+func (m Mapping) Attributes() []string {
+	attributes := make([]string, 0, 4)
+	for _, a := range []string{m.Email, m.Name, m.DateOfBirth, m.Department} {
+		if a != "" {
+			attributes = append(attributes, a)
+		}
+	}
+
+	return attributes
+}
+
+func (m Mapping) Record(entry Entry) domain.DirectoryRecord {
+	record := domain.DirectoryRecord{DN: entry.DN}
+	record.Email = entry.Get(m.Email)
+	record.Name = entry.Get(m.Name)
+	record.Department = entry.Get(m.Department)
+	record.DateOfBirth = normalizeDate(entry.Get(m.DateOfBirth))
+
+	return record
+}
+
+// dateLayouts are the formats of birthdays in directories: ISO dates,
+// plain digits and the LDAP generalized time.
+var dateLayouts = []string{domain.DateLayout, "20060102", "20060102150405Z0700", "20060102150405Z"}
+
+func normalizeDate(value string) string {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		date, err := time.Parse(layout, value)
+		if err == nil {
+			return date.Format(domain.DateLayout)
+		}
+	}
+
+	// left as is to be reported as invalid
+	return value
+}
@@ -0,0 +1,118 @@
+package directory
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/go-ldap/ldap/v3"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ldapTimeout = time.Minute
+	// ldapPageSize keeps the pages under the limit of servers like Active
+	// Directory, which return at most 1000 entries for a search.
+	ldapPageSize = 500
+)
+
+// LDAPSource searches the server with a simple bind. The password is never
+// sent in plain text: ldaps:// URLs use TLS and ldap:// ones are upgraded
+// with StartTLS.
+type LDAPSource struct {
+	URL      string
+	BindDN   string
+	Password string
+	BaseDN   string
+	Filter   string
+	// Attributes to read, all user attributes when empty.
+	Attributes []string
+	// TLSConfig is for servers with a private CA, nil uses the system roots.
+	TLSConfig *tls.Config
+}
+
+func (s LDAPSource) Entries(ctx context.Context) ([]Entry, error) {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid url: %w", err)
+	}
+	if u.Scheme != "ldap" && u.Scheme != "ldaps" {
+		return nil, fmt.Errorf("ldap: unsupported scheme %q", u.Scheme)
+	}
+
+	conn, err := ldap.DialURL(s.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: time.Second * 10}),
+		ldap.DialWithTLSConfig(s.tlsConfig()))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// the requests of the client don't take a context
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(ldapTimeout)
+	}
+	conn.SetTimeout(time.Until(deadline))
+
+	if u.Scheme == "ldap" {
+		err = conn.StartTLS(s.tlsConfig())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = conn.Bind(s.BindDN, s.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := conn.SearchWithPaging(ldap.NewSearchRequest(s.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		ldapFilter(s.Filter), s.Attributes, nil), ldapPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(result.Entries))
+	for _, e := range result.Entries {
+		entry := Entry{DN: e.DN, Attributes: make(map[string][]string, len(e.Attributes))}
+		for _, attribute := range e.Attributes {
+			name := strings.ToLower(attribute.Name)
+			entry.Attributes[name] = append(entry.Attributes[name], attribute.Values...)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (s LDAPSource) tlsConfig() *tls.Config {
+	config := &tls.Config{}
+	if s.TLSConfig != nil {
+		config = s.TLSConfig.Clone()
+	}
+	if config.ServerName == "" {
+		if u, err := url.Parse(s.URL); err == nil {
+			config.ServerName = u.Hostname()
+		}
+	}
+
+	return config
+}
+
+// ldapFilter accepts a filter without the outer parentheses, as people
+// often write them in the settings.
+func ldapFilter(filter string) string {
+	filter = strings.TrimSpace(filter)
+	if !strings.HasPrefix(filter, "(") {
+		filter = "(" + filter + ")"
+	}
+
+	return filter
+}
@@ -0,0 +1,239 @@
+package directory
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// fakeLDAP serves StartTLS, a simple bind and a search of total entries in
+// pages of at most pageSize, like Active Directory does.
+type fakeLDAP struct {
+	listener net.Listener
+	tls      *tls.Config
+	total    int
+	pageSize int
+	// startTLS is false for a server that refuses to upgrade.
+	startTLS bool
+	// bound reports whether the bind came over TLS.
+	bound chan bool
+}
+
+func newFakeLDAP(t *testing.T, total, pageSize int, startTLS bool) (*fakeLDAP, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ldap.test"},
+		DNSNames:     []string{"ldap.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	server := &fakeLDAP{
+		listener: listener,
+		tls:      &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}},
+		total:    total,
+		pageSize: pageSize,
+		startTLS: startTLS,
+		bound:    make(chan bool, 1),
+	}
+	go server.serve()
+
+	return server, roots
+}
+
+func (f *fakeLDAP) url() string {
+	return "ldap://" + f.listener.Addr().String()
+}
+
+func (f *fakeLDAP) serve() {
+	conn, err := f.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	secure := false
+	for {
+		message, err := ber.ReadPacket(conn)
+		if err != nil || len(message.Children) < 2 {
+			return
+		}
+		id, op := message.Children[0].Value.(int64), message.Children[1]
+		reply := func(op *ber.Packet, controls ...ldap.Control) {
+			envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+			envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+			envelope.AppendChild(op)
+			if len(controls) > 0 {
+				packet := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "")
+				for _, control := range controls {
+					packet.AppendChild(control.Encode())
+				}
+				envelope.AppendChild(packet)
+			}
+			_, _ = conn.Write(envelope.Bytes())
+		}
+
+		switch op.Tag {
+		case ldap.ApplicationExtendedRequest:
+			if !f.startTLS {
+				reply(fakeResult(ldap.ApplicationExtendedResponse, ldap.LDAPResultProtocolError))
+				continue
+			}
+			reply(fakeResult(ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess))
+			tlsConn := tls.Server(conn, f.tls)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn, secure = tlsConn, true
+		case ldap.ApplicationBindRequest:
+			f.bound <- secure
+			reply(fakeResult(ldap.ApplicationBindResponse, ldap.LDAPResultSuccess))
+		case ldap.ApplicationSearchRequest:
+			f.searchPage(message, reply)
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func (f *fakeLDAP) searchPage(message *ber.Packet, reply func(*ber.Packet, ...ldap.Control)) {
+	offset := 0
+	if len(message.Children) > 2 {
+		for _, packet := range message.Children[2].Children {
+			control, err := ldap.DecodeControl(packet)
+			if paging, ok := control.(*ldap.ControlPaging); err == nil && ok {
+				offset, _ = strconv.Atoi(string(paging.Cookie))
+			}
+		}
+	}
+
+	end := min(offset+f.pageSize, f.total)
+	for n := offset; n < end; n++ {
+		values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+		values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "user"+strconv.Itoa(n)+"@corp.ru", ""))
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "mail", ""))
+		attribute.AppendChild(values)
+		attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+		attributes.AppendChild(attribute)
+
+		entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+		entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "cn=user"+strconv.Itoa(n), ""))
+		entry.AppendChild(attributes)
+		reply(entry)
+	}
+
+	paging := ldap.NewControlPaging(0)
+	if end < f.total {
+		paging.SetCookie([]byte(strconv.Itoa(end)))
+	}
+	reply(fakeResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess), paging)
+}
+
+func fakeResult(tag ber.Tag, code uint16) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), ""))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+
+	return result
+}
+
+func TestLDAPSourceEntries(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    int
+		pageSize int
+		startTLS bool
+		wantErr  bool
+	}{
+		{name: "single page", total: 3, pageSize: 1000, startTLS: true},
+		{name: "more than the page size", total: 2500, pageSize: 1000, startTLS: true},
+		{name: "exact pages", total: 1000, pageSize: 500, startTLS: true},
+		{name: "empty", total: 0, pageSize: 1000, startTLS: true},
+		{name: "starttls refused", total: 3, pageSize: 1000, startTLS: false, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, roots := newFakeLDAP(t, tt.total, tt.pageSize, tt.startTLS)
+			source := LDAPSource{
+				URL:       server.url(),
+				BindDN:    "cn=admin",
+				Password:  "secret",
+				BaseDN:    "dc=corp",
+				Filter:    "(objectClass=person)",
+				TLSConfig: &tls.Config{RootCAs: roots, ServerName: "ldap.test"},
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			entries, err := source.Entries(ctx)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Entries() succeeded, want an error")
+				}
+				select {
+				case <-server.bound:
+					t.Fatal("the password was sent without TLS")
+				default:
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Entries() error = %v", err)
+			}
+
+			if secure := <-server.bound; !secure {
+				t.Error("the bind wasn't made over TLS")
+			}
+			if len(entries) != tt.total {
+				t.Fatalf("got %d entries, want %d", len(entries), tt.total)
+			}
+			for n, entry := range entries {
+				want := "user" + strconv.Itoa(n) + "@corp.ru"
+				if got := entry.Attributes["mail"]; len(got) != 1 || got[0] != want {
+					t.Fatalf("entry %d mail = %v, want %s", n, got, want)
+				}
+			}
+		})
+	}
+}
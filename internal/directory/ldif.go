@@ -0,0 +1,118 @@
+package directory
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// LDIFSource reads the entries from an LDIF export, it is used when the
+// server isn't reachable from the app.
+type LDIFSource struct {
+	Path string
+}
+
+func (s LDIFSource) Entries(ctx context.Context) ([]Entry, error) {
+	file, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseLDIF(file)
+}
+
+// ParseLDIF reads content records of RFC 2849: folded lines, comments and
+// base64 values are supported, URL values and change records are not.
+func ParseLDIF(r io.Reader) ([]Entry, error) {
+	entries := make([]Entry, 0)
+	lines := make([]string, 0)
+
+	flush := func() error {
+		if len(lines) == 0 {
+			return nil
+		}
+		entry, err := parseLDIFRecord(lines)
+		lines = lines[:0]
+		if err != nil {
+			return err
+		}
+		if entry.DN != "" {
+			entries = append(entries, entry)
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		switch {
+		case line == "":
+			err := flush()
+			if err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, " "):
+			// a folded line continues the previous one
+			if len(lines) > 0 {
+				lines[len(lines)-1] += line[1:]
+			}
+		default:
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	err := flush()
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func parseLDIFRecord(lines []string) (Entry, error) {
+	entry := Entry{Attributes: make(map[string][]string)}
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return Entry{}, fmt.Errorf("ldif: invalid line %q", line)
+		}
+
+		switch {
+		case strings.HasPrefix(value, ":"):
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[1:]))
+			if err != nil {
+				return Entry{}, fmt.Errorf("ldif: invalid base64 value of %s", name)
+			}
+			value = string(decoded)
+		case strings.HasPrefix(value, "<"):
+			return Entry{}, fmt.Errorf("ldif: URL values aren't supported, %s", name)
+		default:
+			value = strings.TrimLeft(value, " ")
+		}
+
+		name = strings.ToLower(name)
+		switch name {
+		case "version":
+		case "dn":
+			entry.DN = value
+		case "changetype":
+			if value != "add" {
+				return Entry{}, fmt.Errorf("ldif: change records aren't supported, %s", entry.DN)
+			}
+		default:
+			entry.Attributes[name] = append(entry.Attributes[name], value)
+		}
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,70 @@
+package directory
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLDIF(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Entry
+		wantErr bool
+	}{
+		{
+			name: "entries",
+			input: "version: 1\n" +
+				"\n" +
+				"# anna\n" +
+				"dn: cn=anna,dc=corp\n" +
+				"mail: anna@corp.ru\n" +
+				"Department: Sales\n" +
+				"\n" +
+				"dn: cn=boris,dc=corp\n" +
+				"mail: boris@corp.ru\n",
+			want: []Entry{
+				{DN: "cn=anna,dc=corp", Attributes: map[string][]string{"mail": {"anna@corp.ru"}, "department": {"Sales"}}},
+				{DN: "cn=boris,dc=corp", Attributes: map[string][]string{"mail": {"boris@corp.ru"}}},
+			},
+		},
+		{
+			name:  "folded lines and crlf",
+			input: "dn: cn=anna,\r\n dc=corp\r\ndescription: first\r\n  second\r\n",
+			want:  []Entry{{DN: "cn=anna,dc=corp", Attributes: map[string][]string{"description": {"first second"}}}},
+		},
+		{
+			name:  "base64 values",
+			input: "dn:: Y249YW5uYSxkYz1jb3Jw\ncn:: 0JDQvdC90LA=\n",
+			want:  []Entry{{DN: "cn=anna,dc=corp", Attributes: map[string][]string{"cn": {"Анна"}}}},
+		},
+		{
+			name:  "values of an attribute",
+			input: "dn: cn=anna,dc=corp\nobjectClass: top\nobjectClass: person\n",
+			want:  []Entry{{DN: "cn=anna,dc=corp", Attributes: map[string][]string{"objectclass": {"top", "person"}}}},
+		},
+		{
+			name:  "add change record",
+			input: "dn: cn=anna,dc=corp\nchangetype: add\nmail: anna@corp.ru\n",
+			want:  []Entry{{DN: "cn=anna,dc=corp", Attributes: map[string][]string{"mail": {"anna@corp.ru"}}}},
+		},
+		{name: "empty", input: "", want: []Entry{}},
+		{name: "only comments", input: "# nothing\n\n# here\n", want: []Entry{}},
+		{name: "modify change record", input: "dn: cn=anna,dc=corp\nchangetype: modify\n", wantErr: true},
+		{name: "URL value", input: "dn: cn=anna,dc=corp\njpegPhoto:< file:///anna.jpg\n", wantErr: true},
+		{name: "invalid base64", input: "dn: cn=anna,dc=corp\ncn:: !!!\n", wantErr: true},
+		{name: "line without a colon", input: "dn: cn=anna,dc=corp\nmail anna@corp.ru\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLDIF(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLDIF() error = %v, want an error: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLDIF() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
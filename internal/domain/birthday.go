@@ -0,0 +1,53 @@
+package domain
+
+import "time"
+
+const DateLayout = "2006-01-02"
+
+// DaysUntilBirthday returns how many days are left until the next birthday,
+// 0 means the birthday is today. People born on February 29 celebrate on
+// March 1 in non-leap years.
+func DaysUntilBirthday(dateOfBirth string, now time.Time) (int, error) {
+	birth, err := time.Parse(DateLayout, dateOfBirth)
+	if err != nil {
+		return 0, err
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	next := time.Date(today.Year(), birth.Month(), birth.Day(), 0, 0, 0, 0, time.UTC)
+	if next.Before(today) {
+		next = time.Date(today.Year()+1, birth.Month(), birth.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	return int(next.Sub(today).Hours() / 24), nil
+}
+
+// NearestBirthday returns the closest birthday to now, past or upcoming,
+// and how many days it is from today: negative for a birthday that passed.
+func NearestBirthday(dateOfBirth string, now time.Time) (time.Time, int, error) {
+	birth, err := time.Parse(DateLayout, dateOfBirth)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	nearest := today
+	days := 0
+	for i, year := range []int{today.Year() - 1, today.Year(), today.Year() + 1} {
+		// time.Date moves February 29 to March 1 in non-leap years
+		date := time.Date(year, birth.Month(), birth.Day(), 0, 0, 0, 0, time.UTC)
+		diff := int(date.Sub(today).Hours() / 24)
+		if i == 0 || abs(diff) < abs(days) {
+			nearest, days = date, diff
+		}
+	}
+
+	return nearest, days, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
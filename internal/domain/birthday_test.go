@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func date(value string) time.Time {
+	t, err := time.Parse(DateLayout, value)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+func TestDaysUntilBirthday(t *testing.T) {
+	tests := []struct {
+		name        string
+		dateOfBirth string
+		now         time.Time
+		want        int
+		wantErr     bool
+	}{
+		{name: "today", dateOfBirth: "1990-05-10", now: date("2024-05-10"), want: 0},
+		{name: "tomorrow", dateOfBirth: "1990-05-11", now: date("2024-05-10"), want: 1},
+		{name: "passed yesterday", dateOfBirth: "1990-05-09", now: date("2023-05-10"), want: 365},
+		{name: "over the new year", dateOfBirth: "1990-01-01", now: date("2023-12-31"), want: 1},
+		{name: "time of day is ignored", dateOfBirth: "1990-05-11", now: time.Date(2024, 5, 10, 23, 59, 0, 0, time.UTC), want: 1},
+		{name: "feb 29 in a leap year", dateOfBirth: "2000-02-29", now: date("2024-02-28"), want: 1},
+		{name: "feb 29 on the day", dateOfBirth: "2000-02-29", now: date("2024-02-29"), want: 0},
+		{name: "feb 29 on march 1 of a non-leap year", dateOfBirth: "2000-02-29", now: date("2023-03-01"), want: 0},
+		{name: "feb 29 before march 1 of a non-leap year", dateOfBirth: "2000-02-29", now: date("2023-02-28"), want: 1},
+		{name: "feb 29 passed in a leap year", dateOfBirth: "2000-02-29", now: date("2024-03-01"), want: 365},
+		{name: "feb 29 passed before a leap year", dateOfBirth: "2000-02-29", now: date("2023-03-02"), want: 364},
+		{name: "invalid date", dateOfBirth: "1990-02-30", now: date("2024-05-10"), wantErr: true},
+		{name: "empty date", dateOfBirth: "", now: date("2024-05-10"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DaysUntilBirthday(tt.dateOfBirth, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DaysUntilBirthday() error = %v, want an error: %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DaysUntilBirthday() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNearestBirthday(t *testing.T) {
+	tests := []struct {
+		name        string
+		dateOfBirth string
+		now         time.Time
+		wantDate    string
+		wantDays    int
+		wantErr     bool
+	}{
+		{name: "today", dateOfBirth: "1990-05-10", now: date("2024-05-10"), wantDate: "2024-05-10", wantDays: 0},
+		{name: "in a few days", dateOfBirth: "1990-05-13", now: date("2024-05-10"), wantDate: "2024-05-13", wantDays: 3},
+		{name: "a few days ago", dateOfBirth: "1990-05-07", now: date("2024-05-10"), wantDate: "2024-05-07", wantDays: -3},
+		{name: "next year", dateOfBirth: "1990-01-02", now: date("2023-12-30"), wantDate: "2024-01-02", wantDays: 3},
+		{name: "last year", dateOfBirth: "1990-12-30", now: date("2024-01-02"), wantDate: "2023-12-30", wantDays: -3},
+		{name: "feb 29 on march 1 of a non-leap year", dateOfBirth: "2000-02-29", now: date("2023-03-01"), wantDate: "2023-03-01", wantDays: 0},
+		{name: "feb 29 after the day of a leap year", dateOfBirth: "2000-02-29", now: date("2024-03-01"), wantDate: "2024-02-29", wantDays: -1},
+		{name: "feb 29 before the day of a leap year", dateOfBirth: "2000-02-29", now: date("2024-02-27"), wantDate: "2024-02-29", wantDays: 2},
+		{name: "feb 29 in a non-leap year", dateOfBirth: "2000-02-29", now: date("2023-02-27"), wantDate: "2023-03-01", wantDays: 2},
+		{name: "invalid date", dateOfBirth: "not a date", now: date("2024-05-10"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, days, err := NearestBirthday(tt.dateOfBirth, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NearestBirthday() error = %v, want an error: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Format(DateLayout) != tt.wantDate || days != tt.wantDays {
+				t.Errorf("NearestBirthday() = %s, %d, want %s, %d", got.Format(DateLayout), days, tt.wantDate, tt.wantDays)
+			}
+		})
+	}
+}
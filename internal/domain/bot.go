@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// BotUpdate is a message that a user wrote to the chat bot.
+type BotUpdate struct {
+	ID     int64
+	ChatID int64
+	// Private is false for group chats, the bot only talks in private ones.
+	Private bool
+	Text    string
+}
+
+type BotLinkResponse struct {
+	Code string `json:"code"`
+	// Command is what to send to the bot to link the chat.
+	Command   string    `json:"command"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type BotStatusResponse struct {
+	Enabled  bool       `json:"enabled"`
+	Linked   bool       `json:"linked"`
+	LinkedAt *time.Time `json:"linkedAt,omitempty"`
+}
@@ -0,0 +1,51 @@
+package domain
+
+import "time"
+
+// PublicStream is the event stream that isn't tied to a user, the office
+// dashboards listen to it. User IDs start with 1.
+const PublicStream = 0
+
+const (
+	// EventUsersChanged tells the dashboards to build the lists again.
+	EventUsersChanged EventType = "users.changed"
+	EventGreeting     EventType = "greeting.new"
+)
+
+type DashboardMessageType string
+
+const (
+	DashboardSnapshot DashboardMessageType = "snapshot"
+	DashboardGreeting DashboardMessageType = "greeting"
+)
+
+// DashboardMessage is a message of the dashboard websocket.
+type DashboardMessage struct {
+	Type DashboardMessageType `json:"type"`
+	Data any                  `json:"data"`
+}
+
+type DashboardBirthday struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	DateOfBirth string `json:"dateOfBirth"`
+	Date        string `json:"date"`
+	DaysLeft    int    `json:"daysLeft"`
+}
+
+type DashboardResponse struct {
+	Date     string              `json:"date"`
+	TimeZone string              `json:"timeZone"`
+	Today    []DashboardBirthday `json:"today"`
+	Upcoming []DashboardBirthday `json:"upcoming"`
+}
+
+// GreetingEvent is a new greeting as the dashboards show it.
+type GreetingEvent struct {
+	ID        int         `json:"id"`
+	Recipient UserSummary `json:"recipient"`
+	Author    UserSummary `json:"author"`
+	Text      string      `json:"text"`
+	Reactions []string    `json:"reactions"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
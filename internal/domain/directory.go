@@ -0,0 +1,29 @@
+package domain
+
+import "time"
+
+// DirectoryRecord is an employee from the corporate directory.
+type DirectoryRecord struct {
+	DN string
+	ImportRecord
+}
+
+type DirectoryEntryError struct {
+	DN    string `json:"dn"`
+	Email string `json:"email,omitempty"`
+	Error string `json:"error"`
+}
+
+// SyncReport lists the emails of the changed users.
+type SyncReport struct {
+	StartedAt   time.Time `json:"startedAt"`
+	FinishedAt  time.Time `json:"finishedAt"`
+	Created     []string  `json:"created"`
+	Updated     []string  `json:"updated"`
+	Deactivated []string  `json:"deactivated"`
+	Reactivated []string  `json:"reactivated"`
+	Unchanged   int       `json:"unchanged"`
+	// RemovedSubscriptions counts the subscriptions from and to deactivated users.
+	RemovedSubscriptions int                   `json:"removedSubscriptions"`
+	Errors               []DirectoryEntryError `json:"errors"`
+}
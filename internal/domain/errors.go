@@ -3,10 +3,44 @@ package domain
 import "errors"
 
 var (
-	ErrFieldsRequired  = errors.New("all required fields must have values")
-	ErrExists          = errors.New("already exists")
-	ErrBadCredentials  = errors.New("email or password is incorrect")
-	ErrNotFound        = errors.New("not found")
-	ErrNotExists       = errors.New("user doesn't exist")
-	ErrTokenNotCreated = errors.New("token didn't created")
+	ErrFieldsRequired   = errors.New("all required fields must have values")
+	ErrExists           = errors.New("already exists")
+	ErrBadCredentials   = errors.New("email or password is incorrect")
+	ErrNotFound         = errors.New("not found")
+	ErrNotExists        = errors.New("user doesn't exist")
+	ErrTokenNotCreated  = errors.New("token didn't created")
+	ErrInvalidCursor    = errors.New("invalid cursor")
+	ErrInvalidQuery     = errors.New("invalid query parameters")
+	ErrSelfSubscribe    = errors.New("can't subscribe to yourself")
+	ErrFollowLimit      = errors.New("follow limit reached")
+	ErrBlocked          = errors.New("user blocked subscriptions from you")
+	ErrInvalidSettings  = errors.New("invalid settings values")
+	ErrInvalidToken     = errors.New("invalid or expired token")
+	ErrMailNotSent      = errors.New("email wasn't sent")
+	ErrTooManyRequests  = errors.New("too many requests")
+	ErrVerified         = errors.New("email is already verified")
+	ErrWeakPassword     = errors.New("password doesn't match the policy")
+	ErrBreachedPassword = errors.New("password was found in a data breach")
+	ErrAccountLocked    = errors.New("account is temporarily locked")
+	ErrInvalidCode      = errors.New("invalid two-factor code")
+	ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorOff     = errors.New("two-factor authentication is not enabled")
+	ErrForbidden        = errors.New("not enough permissions")
+	ErrAccountDisabled  = errors.New("account is disabled")
+	ErrSelfManage       = errors.New("can't change own role or access")
+	ErrEmptyDirectory   = errors.New("directory returned no users")
+	ErrSelfGreeting     = errors.New("can't greet yourself")
+	ErrGreetingClosed   = errors.New("greetings are open only around the birthday")
+	ErrInvalidGreeting  = errors.New("greeting needs a text or reactions within the limits")
+	ErrInvalidFund      = errors.New("invalid fund values")
+	ErrSelfFund         = errors.New("can't open a fund for yourself")
+	ErrFundClosed       = errors.New("the fund deadline has passed")
+	ErrInvalidWishItem  = errors.New("invalid wishlist item values")
+	ErrWishlistFull     = errors.New("wishlist item limit reached")
+	ErrNotFollowing     = errors.New("follow the user to see the wishlist")
+	ErrBirthdayHidden   = errors.New("user hides the birthday")
+	ErrTeamOnly         = errors.New("user accepts followers only from their team")
+	ErrEventsDisabled   = errors.New("event streams are disabled")
+	ErrReadOnlyToken    = errors.New("a token with only the read:birthdays scope is required")
+	ErrBotDisabled      = errors.New("chat bot is not configured")
 )
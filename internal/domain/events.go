@@ -0,0 +1,37 @@
+package domain
+
+import "time"
+
+type EventType string
+
+const (
+	EventNotification  EventType = "notification"
+	EventSubscription  EventType = "subscription"
+	EventBirthdayToday EventType = "birthday.today"
+)
+
+// Event is pushed to the open streams of the user. The ID is opaque for the
+// clients, they only send the last one back to resume the stream.
+type Event struct {
+	ID        string
+	UserID    int
+	Type      EventType
+	Data      any
+	CreatedAt time.Time
+}
+
+type SubscriptionAction string
+
+const (
+	SubscriptionAdded   SubscriptionAction = "subscribed"
+	SubscriptionRemoved SubscriptionAction = "unsubscribed"
+	FollowerRemoved     SubscriptionAction = "follower.removed"
+)
+
+// SubscriptionEvent tells the other sessions of the user that the list of
+// followed users or teams or the list of the followers has changed.
+type SubscriptionEvent struct {
+	Action SubscriptionAction `json:"action"`
+	UserID int                `json:"userId,omitempty"`
+	TeamID int                `json:"teamId,omitempty"`
+}
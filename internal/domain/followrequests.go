@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// FollowRequest waits for the approval of a user with the FollowApproved
+// policy. Until then the follower isn't subscribed and gets no reminders.
+type FollowRequest struct {
+	ID         int
+	FollowerID int
+	TargetID   int
+	CreatedAt  time.Time
+}
+
+// FollowRequestResponse shows the other side of the request: the follower
+// in incoming requests and the target in outgoing ones.
+type FollowRequestResponse struct {
+	ID        int         `json:"id"`
+	User      UserSummary `json:"user"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// SubscribeResponse has Pending set when a follow request was sent instead of subscribing.
+type SubscribeResponse struct {
+	Success bool `json:"success"`
+	Pending bool `json:"pending,omitempty"`
+}
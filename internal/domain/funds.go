@@ -0,0 +1,64 @@
+package domain
+
+import "time"
+
+// Fund collects money for a birthday gift. It is only bookkeeping of pledges,
+// the money itself is passed to the organizer outside the service. Amounts
+// are in whole units of the office currency.
+type Fund struct {
+	ID          int
+	RecipientID int
+	OrganizerID int
+	Title       string
+	// Birthday and Deadline are dates in DateLayout.
+	Birthday string
+	Deadline string
+	Target   int
+	// Participants are the invited users, they can see the fund and pledge.
+	Participants []int
+	Pledges      []Pledge
+	CreatedAt    time.Time
+}
+
+type Pledge struct {
+	UserID    int
+	Amount    int
+	Paid      bool
+	PaidAt    time.Time
+	UpdatedAt time.Time
+}
+
+type FundRequest struct {
+	RecipientId int    `json:"recipientId"`
+	Title       string `json:"title"`
+	Target      int    `json:"target"`
+	Deadline    string `json:"deadline"`
+}
+
+// PledgeRequest changes only the fields that were sent.
+type PledgeRequest struct {
+	Amount *int  `json:"amount"`
+	Paid   *bool `json:"paid"`
+}
+
+type FundResponse struct {
+	ID           int                       `json:"id"`
+	Recipient    UserSummary               `json:"recipient"`
+	Organizer    UserSummary               `json:"organizer"`
+	Title        string                    `json:"title"`
+	Birthday     string                    `json:"birthday"`
+	Deadline     string                    `json:"deadline"`
+	Target       int                       `json:"target"`
+	Pledged      int                       `json:"pledged"`
+	Paid         int                       `json:"paid"`
+	Remaining    int                       `json:"remaining"`
+	Participants []FundParticipantResponse `json:"participants"`
+	CreatedAt    time.Time                 `json:"createdAt"`
+}
+
+type FundParticipantResponse struct {
+	User   UserSummary `json:"user"`
+	Amount int         `json:"amount"`
+	Paid   bool        `json:"paid"`
+	PaidAt *time.Time  `json:"paidAt,omitempty"`
+}
@@ -0,0 +1,38 @@
+package domain
+
+import "time"
+
+// Greeting is a congratulation on the birthday page of the recipient.
+type Greeting struct {
+	ID          int
+	RecipientID int
+	AuthorID    int
+	// Birthday is the date of the birthday the greeting is for, in DateLayout.
+	Birthday  string
+	Text      string
+	Reactions []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type GreetingRequest struct {
+	Text      string   `json:"text"`
+	Reactions []string `json:"reactions"`
+}
+
+// GreetingUpdateRequest changes only the fields that were sent.
+type GreetingUpdateRequest struct {
+	Text      *string   `json:"text"`
+	Reactions *[]string `json:"reactions"`
+}
+
+type GreetingResponse struct {
+	ID          int         `json:"id"`
+	RecipientID int         `json:"recipientId"`
+	Author      UserSummary `json:"author"`
+	Birthday    string      `json:"birthday"`
+	Text        string      `json:"text"`
+	Reactions   []string    `json:"reactions"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	UpdatedAt   *time.Time  `json:"updatedAt,omitempty"`
+}
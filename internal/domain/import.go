@@ -0,0 +1,28 @@
+package domain
+
+// ImportRecord is one employee of an HR upload, the email is the key.
+type ImportRecord struct {
+	Email       string `json:"email"`
+	Name        string `json:"name"`
+	DateOfBirth string `json:"dateOfBirth"`
+	Department  string `json:"department"`
+}
+
+type ImportRowError struct {
+	// Row starts from 1, the CSV header isn't counted.
+	Row   int    `json:"row"`
+	Email string `json:"email,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportResult counts rows with errors and rows without changes as skipped.
+// Failed rows were valid but couldn't be saved, the other rows are imported
+// anyway and the failed ones can be uploaded again.
+type ImportResult struct {
+	DryRun  bool             `json:"dryRun"`
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Skipped int              `json:"skipped"`
+	Failed  int              `json:"failed"`
+	Errors  []ImportRowError `json:"errors"`
+}
@@ -0,0 +1,62 @@
+package domain
+
+import "time"
+
+type NotificationType string
+
+const (
+	NotificationBirthday       NotificationType = "birthday.upcoming"
+	NotificationBirthdayToday  NotificationType = "birthday.today"
+	NotificationGreeting       NotificationType = "greeting.received"
+	NotificationFollower       NotificationType = "follow.new"
+	NotificationFollowRequest  NotificationType = "follow.requested"
+	NotificationFollowApproved NotificationType = "follow.approved"
+)
+
+// Notification is an entry of the in-app inbox of the user.
+type Notification struct {
+	ID      int
+	UserID  int
+	Type    NotificationType
+	Payload map[string]any
+	// Key makes the event land in the inbox only once, like the reminder
+	// about one birthday. Empty keys aren't checked.
+	Key       string
+	CreatedAt time.Time
+	ReadAt    time.Time
+}
+
+type NotificationQuery struct {
+	Unread bool
+	Limit  int
+	Cursor string
+}
+
+type NotificationResponse struct {
+	ID        int              `json:"id"`
+	Type      NotificationType `json:"type"`
+	Payload   map[string]any   `json:"payload"`
+	CreatedAt time.Time        `json:"createdAt"`
+	ReadAt    *time.Time       `json:"readAt,omitempty"`
+}
+
+type NotificationListResponse struct {
+	Notifications []NotificationResponse `json:"notifications"`
+	NextCursor    string                 `json:"nextCursor,omitempty"`
+}
+
+type UnreadCountResponse struct {
+	Count int `json:"count"`
+}
+
+// Reminder tells the user about a birthday of someone they follow.
+type Reminder struct {
+	UserID   int
+	Email    string
+	Name     string
+	Birthday UpcomingBirthdayResponse
+	// Date is the date of the birthday in DateLayout.
+	Date string
+	// ChatID is the linked chat of the bot, reminders go there instead of the email.
+	ChatID int64
+}
@@ -0,0 +1,84 @@
+package domain
+
+// FollowPolicy sets who can subscribe to the user.
+type FollowPolicy string
+
+const (
+	FollowEveryone FollowPolicy = "everyone"
+	// FollowTeam lets only the users of the same department subscribe.
+	FollowTeam FollowPolicy = "team"
+	// FollowApproved needs the user to approve every new follower.
+	FollowApproved FollowPolicy = "approved"
+)
+
+func (p FollowPolicy) IsValid() bool {
+	switch p {
+	case FollowEveryone, FollowTeam, FollowApproved:
+		return true
+	}
+	return false
+}
+
+// Privacy is what the user shows to the others. The zero value hides
+// nothing and lets everyone subscribe.
+type Privacy struct {
+	HideBirthYear bool         `json:"hideBirthYear"`
+	HideBirthday  bool         `json:"hideBirthday"`
+	HideEmail     bool         `json:"hideEmail"`
+	FollowPolicy  FollowPolicy `json:"followPolicy"`
+}
+
+// PrivacyRequest changes only the fields that were sent.
+type PrivacyRequest struct {
+	HideBirthYear *bool         `json:"hideBirthYear,omitempty"`
+	HideBirthday  *bool         `json:"hideBirthday,omitempty"`
+	HideEmail     *bool         `json:"hideEmail,omitempty"`
+	FollowPolicy  *FollowPolicy `json:"followPolicy,omitempty"`
+}
+
+func (p Privacy) Policy() FollowPolicy {
+	if p.FollowPolicy == "" {
+		return FollowEveryone
+	}
+	return p.FollowPolicy
+}
+
+// VisibleEmail returns the email as the viewer may see it, empty when it is hidden.
+func (u User) VisibleEmail(viewerID int) string {
+	if u.Privacy.HideEmail && u.ID != viewerID {
+		return ""
+	}
+	return u.Email
+}
+
+// BirthdayVisible reports whether the viewer may see the birthday, hidden
+// birthdays don't appear in lists, feeds and notifications.
+func (u User) BirthdayVisible(viewerID int) bool {
+	return !u.Privacy.HideBirthday || u.ID == viewerID
+}
+
+// VisibleDateOfBirth returns the date of birth as the viewer may see it:
+// empty when the birthday is hidden and "--MM-DD" without the year.
+func (u User) VisibleDateOfBirth(viewerID int) string {
+	if u.ID == viewerID {
+		return u.DateOfBirth
+	}
+	if u.Privacy.HideBirthday {
+		return ""
+	}
+	if u.Privacy.HideBirthYear && len(u.DateOfBirth) == len(DateLayout) {
+		return "--" + u.DateOfBirth[5:]
+	}
+	return u.DateOfBirth
+}
+
+// AcceptsFollower reports whether the follower can subscribe without an approval.
+func (u User) AcceptsFollower(follower User) bool {
+	switch u.Privacy.Policy() {
+	case FollowTeam:
+		return u.Department != "" && u.Department == follower.Department
+	case FollowApproved:
+		return false
+	}
+	return true
+}
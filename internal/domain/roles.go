@@ -0,0 +1,68 @@
+package domain
+
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleHR    Role = "hr"
+	RoleAdmin Role = "admin"
+)
+
+var Roles = []Role{RoleUser, RoleHR, RoleAdmin}
+
+type Permission string
+
+const (
+	// PermViewUsers allows to see every account with its private fields.
+	PermViewUsers Permission = "users:view"
+	// PermEditUsers allows to change the birthday and the notification settings of anyone.
+	PermEditUsers Permission = "users:edit"
+	// PermManageUsers allows to disable, delete and unlock accounts and to change roles.
+	PermManageUsers Permission = "users:manage"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleUser:  {},
+	RoleHR:    {PermViewUsers, PermEditUsers},
+	RoleAdmin: {PermViewUsers, PermEditUsers, PermManageUsers},
+}
+
+// Can reports whether the role has the permission.
+func (r Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Role) IsValid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+type RoleRequest struct {
+	Role Role `json:"role"`
+}
+
+// AdminSettingsRequest is what HR and admins may change in someone else's account.
+type AdminSettingsRequest struct {
+	DaysToNotification *int    `json:"daysToNotification"`
+	DateOfBirth        *string `json:"dateOfBirth"`
+	// Department moves the user to its team, an empty string removes them from teams.
+	Department *string `json:"department"`
+}
+
+type AdminUserResponse struct {
+	ID                 int    `json:"id"`
+	Email              string `json:"email"`
+	Name               string `json:"name"`
+	DateOfBirth        string `json:"dateOfBirth"`
+	DaysToNotification int    `json:"daysToNotification"`
+	Department         string `json:"department,omitempty"`
+	Role               Role   `json:"role"`
+	Verified           bool   `json:"verified"`
+	Disabled           bool   `json:"disabled"`
+	TwoFactorEnabled   bool   `json:"twoFactorEnabled"`
+}
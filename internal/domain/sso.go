@@ -0,0 +1,18 @@
+package domain
+
+// ExternalIdentity is the user as the identity provider sees them.
+type ExternalIdentity struct {
+	// Issuer is the identity provider, the subject is unique only within it.
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	// DateOfBirth is empty when the provider doesn't share it.
+	DateOfBirth string
+}
+
+// Key identifies the user among the users of all identity providers.
+func (i ExternalIdentity) Key() string {
+	return i.Issuer + "|" + i.Subject
+}
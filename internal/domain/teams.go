@@ -0,0 +1,18 @@
+package domain
+
+// Team groups the users with the same department.
+type Team struct {
+	ID   int
+	Name string
+}
+
+type TeamSubscribeRequest struct {
+	TeamId int `json:"teamId"`
+}
+
+type TeamResponse struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	MembersCount int    `json:"membersCount"`
+	IsSubscribed bool   `json:"isSubscribed"`
+}
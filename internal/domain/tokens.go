@@ -0,0 +1,54 @@
+package domain
+
+import "time"
+
+const PersonalTokenPrefix = "pat_"
+
+const (
+	ScopeReadBirthdays      = "read:birthdays"
+	ScopeWriteSubscriptions = "write:subscriptions"
+	ScopeAdmin              = "admin"
+)
+
+var Scopes = []string{ScopeReadBirthdays, ScopeWriteSubscriptions, ScopeAdmin}
+
+// PersonalToken lets scripts call the API without a password or a browser session.
+// Only the hash of the token is kept.
+type PersonalToken struct {
+	ID         int
+	UserID     int
+	Name       string
+	Hash       string
+	Hint       string
+	Scopes     []string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	LastUsedAt time.Time
+}
+
+func (t PersonalToken) IsExpired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+type CreateTokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// ExpiresInDays is optional, a token without it never expires.
+	ExpiresInDays int `json:"expiresInDays,omitempty"`
+}
+
+type PersonalTokenResponse struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Hint       string     `json:"hint"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+type CreatedTokenResponse struct {
+	PersonalTokenResponse
+	// Token is shown only once, right after creation.
+	Token string `json:"token"`
+}
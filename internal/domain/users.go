@@ -2,17 +2,73 @@ package domain
 
 import (
 	_ "github.com/sirupsen/logrus"
+	"time"
 )
 
 type User struct {
-	ID                 int
-	Email              string
-	Password           string
-	Name               string
-	DateOfBirth        string
-	Token              string
+	ID           int
+	Email        string
+	PendingEmail string
+	// PreviousEmails are the addresses the email was changed from, a revert
+	// link can only bring one of them back.
+	PreviousEmails []string
+	Password       string
+	Name           string
+	DateOfBirth    string
+	Department     string
+	// Sessions are the active logins of the user.
+	Sessions           []Session
 	DaysToNotification int
 	SubscribeUsers     []int
+	BlockedUsers       []int
+	// SubscribeTeams makes the user follow every current and future member
+	// of the teams. SubscribedByTeam are the subscriptions that came from
+	// the teams and TeamExceptions the members unsubscribed one by one.
+	SubscribeTeams   []int
+	SubscribedByTeam []int
+	TeamExceptions   []int
+	// Verified is false until the user confirms the email from the registration letter,
+	// unverified users don't appear in lists and don't get notifications.
+	Verified           bool
+	VerificationSentAt time.Time
+	// PasswordResetHash is the hash of the last issued reset token.
+	PasswordResetHash    string
+	PasswordResetExpires time.Time
+	// TOTPSecret is set when two-factor authentication is enabled,
+	// TOTPPendingSecret waits for the first code after enrollment.
+	TOTPSecret        string
+	TOTPPendingSecret string
+	TOTPLastStep      int64
+	RecoveryCodes     []string
+
+	// OIDCSubject links the account to the user of the identity provider,
+	// it is ExternalIdentity.Key.
+	OIDCSubject string
+	// FromDirectory marks accounts managed by the directory sync,
+	// LeftDirectory is set when the sync deactivated the account.
+	FromDirectory bool
+	LeftDirectory bool
+
+	// Wishlist is visible to the followers of the user.
+	Wishlist []WishItem
+	Privacy  Privacy
+
+	// BotChatID is the chat of the bot linked to the account. BotLinkHash
+	// is the hash of the one-time code that links a chat.
+	BotChatID      int64
+	BotLinkedAt    time.Time
+	BotLinkHash    string
+	BotLinkExpires time.Time
+
+	Role Role
+	// Disabled accounts can't log in and are hidden from the lists.
+	Disabled bool
+}
+
+// Session is a login, the token stops working when it expires.
+type Session struct {
+	Token     string
+	ExpiresAt time.Time
 }
 
 type RegisterRequest struct {
@@ -22,36 +78,145 @@ type RegisterRequest struct {
 	DateOfBirth string `json:"dateOfBirth"`
 }
 
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword     string `json:"currentPassword"`
+	NewPassword         string `json:"newPassword"`
+	LogoutOtherSessions bool   `json:"logoutOtherSessions"`
+}
+
+type UnlockRequest struct {
+	Email string `json:"email"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauthUri"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type TwoFactorDisableRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken"`
+	// Code is a code from the authenticator app or one of the recovery codes.
+	Code string `json:"code"`
+}
+
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 }
 
 type UserResponse struct {
-	Email              string `json:"email"`
-	Name               string `json:"name"`
-	DaysToNotification int    `json:"daysToNotification"`
+	Email              string  `json:"email"`
+	PendingEmail       string  `json:"pendingEmail,omitempty"`
+	Name               string  `json:"name"`
+	DateOfBirth        string  `json:"dateOfBirth"`
+	DaysToNotification int     `json:"daysToNotification"`
+	Verified           bool    `json:"verified"`
+	TwoFactorEnabled   bool    `json:"twoFactorEnabled"`
+	Role               Role    `json:"role"`
+	Privacy            Privacy `json:"privacy"`
+	// TwoFactorRequired is set by login instead of starting a session when
+	// the user has 2FA enabled, ChallengeToken is exchanged by /user/login/2fa.
+	TwoFactorRequired bool   `json:"-"`
+	ChallengeToken    string `json:"-"`
 }
 
-type UserInListResponse struct {
-	Email string `json:"email"`
-	Name  string `json:"name"`
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	ChallengeToken    string `json:"challengeToken"`
 }
 
-type ProfileResponse struct {
+// UpcomingBirthdayResponse has DateOfBirth as "--MM-DD" when the user hides the birth year.
+type UpcomingBirthdayResponse struct {
 	ID           int    `json:"id"`
-	Email        string `json:"email"`
+	Email        string `json:"email,omitempty"`
 	Name         string `json:"name"`
+	DateOfBirth  string `json:"dateOfBirth"`
+	DaysLeft     int    `json:"daysLeft"`
 	IsSubscribed bool   `json:"isSubscribed"`
 }
 
+type UserInListResponse struct {
+	Email string `json:"email,omitempty"`
+	Name  string `json:"name"`
+}
+
+type ProfileResponse struct {
+	ID             int    `json:"id"`
+	Email          string `json:"email,omitempty"`
+	Name           string `json:"name"`
+	Department     string `json:"department,omitempty"`
+	IsSubscribed   bool   `json:"isSubscribed"`
+	FollowersCount int    `json:"followersCount"`
+	FollowingCount int    `json:"followingCount"`
+}
+
+type ProfileListResponse struct {
+	Users      []ProfileResponse `json:"users"`
+	NextCursor string            `json:"nextCursor,omitempty"`
+}
+
+type ListSort string
+
+const (
+	SortByName     ListSort = "name"
+	SortByBirthday ListSort = "birthday"
+)
+
+type ListQuery struct {
+	Cursor     string
+	Limit      int
+	Search     string
+	Subscribed *bool
+	Sort       ListSort
+}
+
+// UserSummary names the user behind a greeting or a gift fund.
+type UserSummary struct {
+	ID    int    `json:"id"`
+	Email string `json:"email,omitempty"`
+	Name  string `json:"name"`
+}
+
+type FollowResponse struct {
+	ID       int    `json:"id"`
+	Email    string `json:"email,omitempty"`
+	Name     string `json:"name"`
+	IsMutual bool   `json:"isMutual"`
+}
+
 type SubscribeRequest struct {
 	UserId int `json:"userId"`
 }
 
+// SettingsRequest is a partial update: only non-nil fields are changed.
 type SettingsRequest struct {
-	DaysToNotification int    `json:"daysToNotification"`
-	Email              string `json:"email,omitempty"`
+	DaysToNotification *int            `json:"daysToNotification,omitempty"`
+	Email              *string         `json:"email,omitempty"`
+	Name               *string         `json:"name,omitempty"`
+	DateOfBirth        *string         `json:"dateOfBirth,omitempty"`
+	Privacy            *PrivacyRequest `json:"privacy,omitempty"`
 }
 
 type DefaultResponse struct {
@@ -0,0 +1,78 @@
+package domain
+
+import "time"
+
+type WishPriority string
+
+const (
+	PriorityLow    WishPriority = "low"
+	PriorityMedium WishPriority = "medium"
+	PriorityHigh   WishPriority = "high"
+)
+
+func (p WishPriority) IsValid() bool {
+	switch p {
+	case PriorityLow, PriorityMedium, PriorityHigh:
+		return true
+	}
+	return false
+}
+
+// Rank orders the wishlist, the most wanted items first.
+func (p WishPriority) Rank() int {
+	switch p {
+	case PriorityHigh:
+		return 0
+	case PriorityMedium:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// WishItem is a gift the user would like to get. Prices are in whole units
+// of the office currency, zero means the bound is not set.
+type WishItem struct {
+	ID       int
+	Title    string
+	Link     string
+	PriceMin int
+	PriceMax int
+	Priority WishPriority
+	// ReservedBy is the follower who is going to buy the item,
+	// it is never shown to anyone, including the owner.
+	ReservedBy int
+	ReservedAt time.Time
+	CreatedAt  time.Time
+}
+
+type WishItemRequest struct {
+	Title    string       `json:"title"`
+	Link     string       `json:"link"`
+	PriceMin int          `json:"priceMin"`
+	PriceMax int          `json:"priceMax"`
+	Priority WishPriority `json:"priority"`
+}
+
+// WishItemUpdateRequest changes only the fields that were sent.
+type WishItemUpdateRequest struct {
+	Title    *string       `json:"title"`
+	Link     *string       `json:"link"`
+	PriceMin *int          `json:"priceMin"`
+	PriceMax *int          `json:"priceMax"`
+	Priority *WishPriority `json:"priority"`
+}
+
+// WishItemResponse shows reservations only to followers: the owner gets
+// neither field, so the gift stays a surprise.
+type WishItemResponse struct {
+	ID           int          `json:"id"`
+	Title        string       `json:"title"`
+	Link         string       `json:"link,omitempty"`
+	PriceMin     int          `json:"priceMin,omitempty"`
+	PriceMax     int          `json:"priceMax,omitempty"`
+	Priority     WishPriority `json:"priority"`
+	Reserved     bool         `json:"reserved,omitempty"`
+	ReservedByMe bool         `json:"reservedByMe,omitempty"`
+	CreatedAt    time.Time    `json:"createdAt"`
+}
@@ -0,0 +1,122 @@
+package events
+
+import (
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is how many events can wait for a slow stream.
+const subscriberBuffer = 32
+
+// Hub is the in-process broker of the events. It keeps the last events of
+// every user, so a stream that was cut off can be resumed from the ID of the
+// last event it got. The events are lost on restart, the streams then start
+// over from the current state.
+type Hub struct {
+	mu          sync.Mutex
+	lastID      int64
+	historySize int
+	history     map[int][]domain.Event
+	subscribers map[int]map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	ch chan domain.Event
+}
+
+// NewHub creates a hub that keeps up to historySize events of every user for
+// resuming the streams.
+func NewHub(historySize int) *Hub {
+	return &Hub{
+		historySize: historySize,
+		history:     make(map[int][]domain.Event),
+		subscribers: make(map[int]map[*subscriber]struct{}),
+	}
+}
+
+// Publish delivers the event to the open streams of the user. A stream that
+// doesn't keep up is closed instead of blocking the publisher, the client
+// reconnects and gets the missed events from the history.
+func (h *Hub) Publish(userID int, eventType domain.EventType, data any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	event := domain.Event{
+		ID:        strconv.FormatInt(h.lastID, 10),
+		UserID:    userID,
+		Type:      eventType,
+		Data:      data,
+		CreatedAt: time.Now(),
+	}
+
+	if h.historySize > 0 {
+		history := append(h.history[userID], event)
+		if len(history) > h.historySize {
+			history = history[len(history)-h.historySize:]
+		}
+		h.history[userID] = history
+	}
+
+	for sub := range h.subscribers[userID] {
+		select {
+		case sub.ch <- event:
+		default:
+			h.remove(userID, sub)
+		}
+	}
+}
+
+// Subscribe opens a stream of the events of the user. The events after
+// lastEventID that are still in the history come first, an empty or unknown
+// ID starts with the new events only. The stream is closed by cancel or when
+// the subscriber falls behind.
+func (h *Hub) Subscribe(userID int, lastEventID string) (<-chan domain.Event, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var replay []domain.Event
+	if last, err := strconv.ParseInt(lastEventID, 10, 64); err == nil {
+		for _, event := range h.history[userID] {
+			id, _ := strconv.ParseInt(event.ID, 10, 64)
+			if id > last {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	sub := &subscriber{ch: make(chan domain.Event, len(replay)+subscriberBuffer)}
+	for _, event := range replay {
+		sub.ch <- event
+	}
+
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[*subscriber]struct{})
+	}
+	h.subscribers[userID][sub] = struct{}{}
+
+	cancel := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		h.remove(userID, sub)
+	}
+
+	return sub.ch, cancel
+}
+
+// remove closes the stream once, the lock must be held.
+func (h *Hub) remove(userID int, sub *subscriber) {
+	subs := h.subscribers[userID]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+
+	delete(subs, sub)
+	close(sub.ch)
+	if len(subs) == 0 {
+		delete(h.subscribers, userID)
+	}
+}
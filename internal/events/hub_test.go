@@ -0,0 +1,135 @@
+package events
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+// received returns the IDs of the events waiting in the stream and whether
+// the stream is still open.
+func received(ch <-chan domain.Event) ([]string, bool) {
+	ids := make([]string, 0)
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return ids, false
+			}
+			ids = append(ids, event.ID)
+		default:
+			return ids, true
+		}
+	}
+}
+
+func TestHubSubscribeResumes(t *testing.T) {
+	hub := NewHub(3)
+	// the IDs are shared by the users: 1, 2 and 4-6 are of user 1
+	hub.Publish(1, domain.EventNotification, nil)
+	hub.Publish(1, domain.EventNotification, nil)
+	hub.Publish(2, domain.EventNotification, nil)
+	hub.Publish(1, domain.EventNotification, nil)
+	hub.Publish(1, domain.EventSubscription, nil)
+	hub.Publish(1, domain.EventBirthdayToday, nil)
+
+	tests := []struct {
+		name        string
+		userID      int
+		lastEventID string
+		want        []string
+	}{
+		{name: "new stream", userID: 1, lastEventID: "", want: []string{}},
+		{name: "unknown ID", userID: 1, lastEventID: "abc", want: []string{}},
+		{name: "resumes after the ID", userID: 1, lastEventID: "4", want: []string{"5", "6"}},
+		{name: "lost events are skipped", userID: 1, lastEventID: "1", want: []string{"4", "5", "6"}},
+		{name: "up to date", userID: 1, lastEventID: "6", want: []string{}},
+		{name: "other user", userID: 2, lastEventID: "0", want: []string{"3"}},
+		{name: "user without events", userID: 3, lastEventID: "0", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch, cancel := hub.Subscribe(tt.userID, tt.lastEventID)
+			defer cancel()
+
+			got, open := received(ch)
+			if !open || !slices.Equal(got, tt.want) {
+				t.Errorf("Subscribe() replayed %v, open %v, want %v", got, open, tt.want)
+			}
+		})
+	}
+}
+
+func TestHubPublish(t *testing.T) {
+	hub := NewHub(0)
+	anna, cancelAnna := hub.Subscribe(1, "")
+	defer cancelAnna()
+	annaOther, cancelAnnaOther := hub.Subscribe(1, "")
+	defer cancelAnnaOther()
+	boris, cancelBoris := hub.Subscribe(2, "")
+	defer cancelBoris()
+
+	hub.Publish(1, domain.EventNotification, domain.SubscriptionEvent{UserID: 2})
+
+	for name, ch := range map[string]<-chan domain.Event{"first stream": anna, "second stream": annaOther} {
+		select {
+		case event := <-ch:
+			if event.UserID != 1 || event.Type != domain.EventNotification || event.Data != (domain.SubscriptionEvent{UserID: 2}) {
+				t.Errorf("%s got %+v", name, event)
+			}
+		default:
+			t.Errorf("%s got no event", name)
+		}
+	}
+	if got, _ := received(boris); len(got) != 0 {
+		t.Errorf("the stream of another user got %v", got)
+	}
+
+	// without a history nothing is replayed
+	late, cancelLate := hub.Subscribe(1, "0")
+	defer cancelLate()
+	if got, _ := received(late); len(got) != 0 {
+		t.Errorf("Subscribe() without a history replayed %v", got)
+	}
+}
+
+func TestHubClosesSlowStream(t *testing.T) {
+	hub := NewHub(subscriberBuffer * 2)
+	slow, cancelSlow := hub.Subscribe(1, "")
+	defer cancelSlow()
+
+	for n := 0; n <= subscriberBuffer; n++ {
+		hub.Publish(1, domain.EventNotification, nil)
+	}
+
+	got, open := received(slow)
+	if open || len(got) != subscriberBuffer {
+		t.Fatalf("the slow stream got %d events, open %v, want %d and closed", len(got), open, subscriberBuffer)
+	}
+
+	// the client reconnects with the last ID it got and catches up
+	resumed, cancel := hub.Subscribe(1, got[len(got)-1])
+	defer cancel()
+	missed, open := received(resumed)
+	if !open || len(missed) != 1 {
+		t.Errorf("the resumed stream got %v, open %v, want the missed event", missed, open)
+	}
+}
+
+func TestHubCancel(t *testing.T) {
+	hub := NewHub(10)
+	ch, cancel := hub.Subscribe(1, "")
+
+	cancel()
+	if _, open := received(ch); open {
+		t.Fatal("the stream is open after cancel")
+	}
+	// cancelling again and publishing to nobody are fine
+	cancel()
+	hub.Publish(1, domain.EventNotification, nil)
+
+	if len(hub.subscribers) != 0 {
+		t.Errorf("the hub keeps %d users without streams", len(hub.subscribers))
+	}
+}
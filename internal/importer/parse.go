@@ -0,0 +1,104 @@
+// Package importer reads HR uploads of employees.
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"io"
+	"strings"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+var ErrUnknownFormat = errors.New("unknown import format")
+
+// columns maps the accepted CSV header names to the record fields.
+var columns = map[string]func(record *domain.ImportRecord, value string){
+	"email":         func(r *domain.ImportRecord, v string) { r.Email = v },
+	"name":          func(r *domain.ImportRecord, v string) { r.Name = v },
+	"dob":           func(r *domain.ImportRecord, v string) { r.DateOfBirth = v },
+	"date_of_birth": func(r *domain.ImportRecord, v string) { r.DateOfBirth = v },
+	"dateofbirth":   func(r *domain.ImportRecord, v string) { r.DateOfBirth = v },
+	"birthday":      func(r *domain.ImportRecord, v string) { r.DateOfBirth = v },
+	"department":    func(r *domain.ImportRecord, v string) { r.Department = v },
+}
+
+// Parse reads the records in the format: a JSON array of records or a CSV
+// file with a header row. CSV columns may go in any order, unknown ones are ignored.
+func Parse(r io.Reader, format string) ([]domain.ImportRecord, error) {
+	switch format {
+	case FormatCSV:
+		return parseCSV(r)
+	case FormatJSON:
+		records := make([]domain.ImportRecord, 0)
+		err := json.NewDecoder(r).Decode(&records)
+		if err != nil {
+			return nil, fmt.Errorf("read json: %w", err)
+		}
+		return records, nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// FormatOf guesses the format by a content type or a file name.
+func FormatOf(value string) string {
+	value = strings.ToLower(value)
+	switch {
+	case strings.Contains(value, "csv"):
+		return FormatCSV
+	case strings.Contains(value, "json"):
+		return FormatJSON
+	default:
+		return ""
+	}
+}
+
+func parseCSV(r io.Reader) ([]domain.ImportRecord, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read csv header: %w", err)
+	}
+
+	setters := make([]func(record *domain.ImportRecord, value string), len(header))
+	hasEmail := false
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\uFEFF")))
+		setters[i] = columns[name]
+		hasEmail = hasEmail || name == "email"
+	}
+	if !hasEmail {
+		return nil, errors.New("read csv header: no email column")
+	}
+
+	records := make([]domain.ImportRecord, 0)
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read csv: %w", err)
+		}
+
+		var record domain.ImportRecord
+		for i, value := range row {
+			if i < len(setters) && setters[i] != nil {
+				setters[i](&record, strings.TrimSpace(value))
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
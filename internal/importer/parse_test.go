@@ -0,0 +1,92 @@
+package importer
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		input   string
+		want    []domain.ImportRecord
+		wantErr bool
+	}{
+		{
+			name:   "csv",
+			format: FormatCSV,
+			input:  "email,name,dob,department\nanna@corp.ru,Anna,1990-05-10,Sales\n",
+			want:   []domain.ImportRecord{{Email: "anna@corp.ru", Name: "Anna", DateOfBirth: "1990-05-10", Department: "Sales"}},
+		},
+		{
+			name:   "csv columns in any order with aliases",
+			format: FormatCSV,
+			input:  "\uFEFF Department , Date_Of_Birth,EMAIL,extra\nSales, 1990-05-10 ,anna@corp.ru,ignored\n",
+			want:   []domain.ImportRecord{{Email: "anna@corp.ru", DateOfBirth: "1990-05-10", Department: "Sales"}},
+		},
+		{
+			name:   "csv rows of any length",
+			format: FormatCSV,
+			input:  "email,name\nanna@corp.ru\nboris@corp.ru,Boris,extra\n",
+			want:   []domain.ImportRecord{{Email: "anna@corp.ru"}, {Email: "boris@corp.ru", Name: "Boris"}},
+		},
+		{
+			name:   "csv quoted values",
+			format: FormatCSV,
+			input:  "email,name\nanna@corp.ru,\"Smith, Anna\"\n",
+			want:   []domain.ImportRecord{{Email: "anna@corp.ru", Name: "Smith, Anna"}},
+		},
+		{name: "csv header only", format: FormatCSV, input: "email,name\n", want: []domain.ImportRecord{}},
+		{name: "csv without an email column", format: FormatCSV, input: "name,dob\nAnna,1990-05-10\n", wantErr: true},
+		{name: "csv empty", format: FormatCSV, input: "", wantErr: true},
+		{name: "csv broken quote", format: FormatCSV, input: "email,name\nanna@corp.ru,\"Anna\n", wantErr: true},
+		{
+			name:   "json",
+			format: FormatJSON,
+			input:  `[{"email":"anna@corp.ru","name":"Anna","dateOfBirth":"1990-05-10","department":"Sales"}]`,
+			want:   []domain.ImportRecord{{Email: "anna@corp.ru", Name: "Anna", DateOfBirth: "1990-05-10", Department: "Sales"}},
+		},
+		{name: "json not an array", format: FormatJSON, input: `{"email":"anna@corp.ru"}`, wantErr: true},
+		{name: "json broken", format: FormatJSON, input: `[{"email":`, wantErr: true},
+		{name: "unknown format", format: "xml", input: "<users/>", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.input), tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, want an error: %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Parse(strings.NewReader(""), "xml"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Parse() error = %v, want ErrUnknownFormat", err)
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "text/csv", want: FormatCSV},
+		{value: "employees.CSV", want: FormatCSV},
+		{value: "application/json; charset=utf-8", want: FormatJSON},
+		{value: "employees.json", want: FormatJSON},
+		{value: "employees.xlsx", want: ""},
+		{value: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := FormatOf(tt.value); got != tt.want {
+			t.Errorf("FormatOf(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
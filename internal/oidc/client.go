@@ -0,0 +1,236 @@
+// Package oidc implements the OpenID Connect authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/krevetkou/test-rutube/internal/auth"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoginTTL is how long the user has to finish the login at the identity provider.
+const LoginTTL = time.Minute * 10
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// BirthdateClaim is the ID token claim with the date of birth in the 2006-01-02 format.
+	BirthdateClaim string
+}
+
+type Client struct {
+	config     Config
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	provider *providerMetadata
+	keys     keySet
+	pending  map[string]pendingLogin
+}
+
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// pendingLogin is what is needed to finish a login started by AuthCodeURL.
+type pendingLogin struct {
+	verifier string
+	nonce    string
+	expires  time.Time
+}
+
+func NewClient(config Config) *Client {
+	return &Client{
+		config:     config,
+		httpClient: &http.Client{Timeout: time.Second * 10},
+		pending:    make(map[string]pendingLogin),
+	}
+}
+
+// AuthCodeURL starts a login and returns the URL of the identity provider
+// and the state that comes back to the callback.
+func (c *Client) AuthCodeURL(ctx context.Context, loginHint string) (string, string, error) {
+	provider, err := c.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, _, err := auth.RandomToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, _, err := auth.RandomToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, _, err := auth.RandomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	for key, login := range c.pending {
+		if now.After(login.expires) {
+			delete(c.pending, key)
+		}
+	}
+	c.pending[state] = pendingLogin{verifier: verifier, nonce: nonce, expires: now.Add(LoginTTL)}
+	c.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.config.ClientID},
+		"redirect_uri":          {c.config.RedirectURL},
+		"scope":                 {strings.Join(c.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	if loginHint != "" {
+		query.Set("login_hint", loginHint)
+	}
+
+	return provider.AuthorizationEndpoint + "?" + query.Encode(), state, nil
+}
+
+// Exchange finishes the login: it redeems the code and verifies the ID token.
+func (c *Client) Exchange(ctx context.Context, state, code string) (domain.ExternalIdentity, error) {
+	c.mu.Lock()
+	login, ok := c.pending[state]
+	delete(c.pending, state)
+	c.mu.Unlock()
+
+	if !ok || time.Now().After(login.expires) || code == "" {
+		return domain.ExternalIdentity{}, domain.ErrInvalidToken
+	}
+
+	provider, err := c.discover(ctx)
+	if err != nil {
+		return domain.ExternalIdentity{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.config.RedirectURL},
+		"client_id":     {c.config.ClientID},
+		"code_verifier": {login.verifier},
+	}
+	if c.config.ClientSecret != "" {
+		form.Set("client_secret", c.config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return domain.ExternalIdentity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	err = c.do(req, &tokens)
+	if err != nil {
+		return domain.ExternalIdentity{}, fmt.Errorf("redeem code: %w", err)
+	}
+
+	claims, err := c.verify(ctx, provider, tokens.IDToken, login.nonce)
+	if err != nil {
+		return domain.ExternalIdentity{}, err
+	}
+
+	return c.identity(claims), nil
+}
+
+func (c *Client) identity(claims map[string]interface{}) domain.ExternalIdentity {
+	identity := domain.ExternalIdentity{
+		Issuer:  stringClaim(claims, "iss"),
+		Subject: stringClaim(claims, "sub"),
+		Email:   strings.TrimSpace(stringClaim(claims, "email")),
+		Name:    strings.TrimSpace(stringClaim(claims, "name")),
+	}
+
+	// some providers send email_verified as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+
+	if c.config.BirthdateClaim != "" {
+		identity.DateOfBirth = stringClaim(claims, c.config.BirthdateClaim)
+	}
+
+	return identity
+}
+
+// discover loads the provider metadata once, so the app starts
+// even when the identity provider is down.
+func (c *Client) discover(ctx context.Context) (*providerMetadata, error) {
+	c.mu.Lock()
+	provider := c.provider
+	c.mu.Unlock()
+	if provider != nil {
+		return provider, nil
+	}
+
+	issuer := strings.TrimSuffix(c.config.Issuer, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	provider = &providerMetadata{}
+	err = c.do(req, provider)
+	if err != nil {
+		return nil, fmt.Errorf("discover provider: %w", err)
+	}
+
+	if strings.TrimSuffix(provider.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("discover provider: issuer %q doesn't match %q", provider.Issuer, c.config.Issuer)
+	}
+
+	c.mu.Lock()
+	c.provider = provider
+	c.mu.Unlock()
+
+	return provider, nil
+}
+
+func (c *Client) do(req *http.Request, v interface{}) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL.Path, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func stringClaim(claims map[string]interface{}, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
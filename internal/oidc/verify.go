@@ -0,0 +1,162 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"math/big"
+	"net/http"
+)
+
+// keySet holds the RSA signing keys of the provider by key ID.
+type keySet map[string]*rsa.PublicKey
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// verify checks the signature, issuer, audience, expiry and nonce of the ID token
+// and returns its claims.
+func (c *Client) verify(ctx context.Context, provider *providerMetadata, idToken, nonce string) (map[string]interface{}, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		return c.key(ctx, provider, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", domain.ErrInvalidToken, err)
+	}
+
+	if stringClaim(claims, "iss") != provider.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer", domain.ErrInvalidToken)
+	}
+	if !hasAudience(claims["aud"], c.config.ClientID) {
+		return nil, fmt.Errorf("%w: unexpected audience", domain.ErrInvalidToken)
+	}
+	if stringClaim(claims, "nonce") != nonce {
+		return nil, fmt.Errorf("%w: nonce doesn't match", domain.ErrInvalidToken)
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, fmt.Errorf("%w: no expiry", domain.ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// key returns the key by its ID, the key set is loaded again once
+// when the ID is unknown because providers rotate keys.
+func (c *Client) key(ctx context.Context, provider *providerMetadata, kid string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	keys := c.keys
+	c.mu.Unlock()
+
+	if keys != nil {
+		if key, ok := lookup(keys, kid); ok {
+			return key, nil
+		}
+	}
+
+	keys, err := c.fetchKeys(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.mu.Unlock()
+
+	key, ok := lookup(keys, kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	return key, nil
+}
+
+func (c *Client) fetchKeys(ctx context.Context, provider *providerMetadata) (keySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, provider.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	err = c.do(req, &jwks)
+	if err != nil {
+		return nil, fmt.Errorf("load keys: %w", err)
+	}
+
+	keys := keySet{}
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		key, err := rsaKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("load key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+
+	return keys, nil
+}
+
+// lookup finds the key by ID, a token without an ID may use the only key of the set.
+func lookup(keys keySet, kid string) (*rsa.PublicKey, bool) {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+
+	key, ok := keys[kid]
+	return key, ok
+}
+
+func rsaKey(k jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+		return nil, errors.New("invalid modulus or exponent")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+func hasAudience(aud interface{}, clientID string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, a := range aud {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
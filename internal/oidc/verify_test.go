@@ -0,0 +1,165 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+const (
+	testIssuer   = "https://idp.example"
+	testClientID = "birthdays"
+	testNonce    = "nonce-1"
+)
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
+
+func TestVerify(t *testing.T) {
+	current := generateKey(t)
+	rotated := generateKey(t)
+	unknown := generateKey(t)
+
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := []jsonWebKey{
+			{Kty: "RSA", Kid: "current", Use: "sig", N: encodeInt(current.N), E: encodeInt(big.NewInt(int64(current.E)))},
+			{Kty: "RSA", Kid: "rotated", N: encodeInt(rotated.N), E: encodeInt(big.NewInt(int64(rotated.E)))},
+			{Kty: "RSA", Kid: "encryption", Use: "enc", N: encodeInt(unknown.N), E: encodeInt(big.NewInt(int64(unknown.E)))},
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	defer jwks.Close()
+
+	provider := &providerMetadata{Issuer: testIssuer, JWKSURI: jwks.URL}
+	claims := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss":   testIssuer,
+			"aud":   testClientID,
+			"sub":   "42",
+			"nonce": testNonce,
+			"exp":   time.Now().Add(time.Minute).Unix(),
+		}
+		if change != nil {
+			change(c)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "valid", token: signToken(t, jwt.SigningMethodRS256, current, "current", claims(nil))},
+		{
+			name:  "audience list",
+			token: signToken(t, jwt.SigningMethodRS256, current, "current", claims(func(c jwt.MapClaims) { c["aud"] = []string{"other", testClientID} })),
+		},
+		{name: "rotated key", token: signToken(t, jwt.SigningMethodRS256, rotated, "rotated", claims(nil))},
+		{name: "no key id", token: signToken(t, jwt.SigningMethodRS256, current, "", claims(nil))},
+		{name: "unknown key", token: signToken(t, jwt.SigningMethodRS256, unknown, "unknown", claims(nil)), wantErr: true},
+		{name: "encryption key", token: signToken(t, jwt.SigningMethodRS256, unknown, "encryption", claims(nil)), wantErr: true},
+		{name: "wrong signature", token: signToken(t, jwt.SigningMethodRS256, unknown, "current", claims(nil)), wantErr: true},
+		{name: "hmac", token: signToken(t, jwt.SigningMethodHS256, []byte("secret"), "current", claims(nil)), wantErr: true},
+		{
+			name:    "other issuer",
+			token:   signToken(t, jwt.SigningMethodRS256, current, "current", claims(func(c jwt.MapClaims) { c["iss"] = "https://evil.example" })),
+			wantErr: true,
+		},
+		{
+			name:    "other audience",
+			token:   signToken(t, jwt.SigningMethodRS256, current, "current", claims(func(c jwt.MapClaims) { c["aud"] = "other" })),
+			wantErr: true,
+		},
+		{
+			name:    "other nonce",
+			token:   signToken(t, jwt.SigningMethodRS256, current, "current", claims(func(c jwt.MapClaims) { c["nonce"] = "nonce-2" })),
+			wantErr: true,
+		},
+		{
+			name:    "expired",
+			token:   signToken(t, jwt.SigningMethodRS256, current, "current", claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() })),
+			wantErr: true,
+		},
+		{
+			name:    "no expiry",
+			token:   signToken(t, jwt.SigningMethodRS256, current, "current", claims(func(c jwt.MapClaims) { delete(c, "exp") })),
+			wantErr: true,
+		},
+		{name: "garbage", token: "not.a.token", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(Config{Issuer: testIssuer, ClientID: testClientID})
+			c.keys = keySet{"current": &current.PublicKey}
+
+			got, err := c.verify(context.Background(), provider, tt.token, testNonce)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verify() error = %v, want an error: %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, domain.ErrInvalidToken) {
+				t.Errorf("verify() error = %v, want ErrInvalidToken", err)
+			}
+			if err == nil && stringClaim(got, "sub") != "42" {
+				t.Errorf("verify() claims = %v, want the subject", got)
+			}
+		})
+	}
+}
+
+func TestRSAKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     jsonWebKey
+		wantErr bool
+	}{
+		{name: "valid", key: jsonWebKey{N: "AQAB", E: "AQAB"}},
+		{name: "bad modulus", key: jsonWebKey{N: "!!", E: "AQAB"}, wantErr: true},
+		{name: "empty modulus", key: jsonWebKey{N: "", E: "AQAB"}, wantErr: true},
+		{name: "long exponent", key: jsonWebKey{N: "AQAB", E: "AQABAQAB"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := rsaKey(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("rsaKey() error = %v, want an error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func encodeInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
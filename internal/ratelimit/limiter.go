@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter keeps a token bucket per key: every key may spend up to burst
+// requests at once, and the bucket refills with rate tokens per second.
+type Limiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewLimiter(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Every converts "one request per interval" to a rate for NewLimiter.
+func Every(interval time.Duration) float64 {
+	return 1 / interval.Seconds()
+}
+
+func (l *Limiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b := l.refill(key, now)
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+
+	return true
+}
+
+func (l *Limiter) refill(key string, now time.Time) *bucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		l.cleanup(now)
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+		return b
+	}
+
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	return b
+}
+
+// cleanup drops buckets that are full again, they are equal to new ones.
+func (l *Limiter) cleanup(now time.Time) {
+	if len(l.buckets) < 1024 {
+		return
+	}
+
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
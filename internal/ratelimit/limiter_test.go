@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// clock is a time source the tests move by hand.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestLimiterAllow(t *testing.T) {
+	type step struct {
+		after time.Duration
+		key   string
+		want  bool
+	}
+
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		steps []step
+	}{
+		{
+			name:  "burst then empty",
+			rate:  Every(time.Minute),
+			burst: 3,
+			steps: []step{
+				{key: "a", want: true},
+				{key: "a", want: true},
+				{key: "a", want: true},
+				{key: "a", want: false},
+			},
+		},
+		{
+			name:  "refills with time",
+			rate:  Every(time.Minute),
+			burst: 1,
+			steps: []step{
+				{key: "a", want: true},
+				{after: 30 * time.Second, key: "a", want: false},
+				{after: 30 * time.Second, key: "a", want: true},
+				{key: "a", want: false},
+			},
+		},
+		{
+			name:  "doesn't refill above the burst",
+			rate:  Every(time.Second),
+			burst: 2,
+			steps: []step{
+				{key: "a", want: true},
+				{after: time.Hour, key: "a", want: true},
+				{key: "a", want: true},
+				{key: "a", want: false},
+			},
+		},
+		{
+			name:  "keys are separate",
+			rate:  Every(time.Minute),
+			burst: 1,
+			steps: []step{
+				{key: "a", want: true},
+				{key: "a", want: false},
+				{key: "b", want: true},
+				{key: "b", want: false},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &clock{now: time.Unix(1_700_000_000, 0)}
+			limiter := NewLimiter(tt.rate, tt.burst)
+			limiter.now = c.Now
+
+			for i, s := range tt.steps {
+				c.Advance(s.after)
+				if got := limiter.Allow(s.key); got != s.want {
+					t.Fatalf("step %d: Allow(%q) = %v, want %v", i, s.key, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterCleanup(t *testing.T) {
+	c := &clock{now: time.Unix(1_700_000_000, 0)}
+	limiter := NewLimiter(Every(time.Second), 1)
+	limiter.now = c.Now
+
+	for i := 0; i < 1024; i++ {
+		limiter.Allow(strconv.Itoa(i))
+	}
+	c.Advance(time.Minute)
+	limiter.Allow("new")
+
+	if len(limiter.buckets) != 1 {
+		t.Errorf("got %d buckets after the cleanup, want 1", len(limiter.buckets))
+	}
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Lockout counts failed attempts per key. After threshold failures in a row
+// the key is locked, and every next failure doubles the lock time up to max.
+type Lockout struct {
+	mu        sync.Mutex
+	threshold int
+	base      time.Duration
+	max       time.Duration
+	states    map[string]*lockState
+	now       func() time.Time
+}
+
+type lockState struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+func NewLockout(threshold int, base, max time.Duration) *Lockout {
+	return &Lockout{
+		threshold: threshold,
+		base:      base,
+		max:       max,
+		states:    make(map[string]*lockState),
+		now:       time.Now,
+	}
+}
+
+// LockedUntil returns the end of the current lock, ok is false if the key isn't locked.
+func (l *Lockout) LockedUntil(key string) (time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	state, ok := l.states[key]
+	if !ok || !l.now().Before(state.lockedUntil) {
+		return time.Time{}, false
+	}
+
+	return state.lockedUntil, true
+}
+
+func (l *Lockout) Fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	state, ok := l.states[key]
+	if !ok {
+		l.cleanup(now)
+		state = &lockState{}
+		l.states[key] = state
+	}
+
+	state.failures++
+	state.lastFailure = now
+
+	if state.failures < l.threshold {
+		return
+	}
+
+	lock := l.base
+	for i := l.threshold; i < state.failures && lock < l.max; i++ {
+		lock *= 2
+	}
+	state.lockedUntil = now.Add(min(lock, l.max))
+}
+
+func (l *Lockout) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.states, key)
+}
+
+// cleanup forgets keys that haven't failed for longer than the max lock.
+func (l *Lockout) cleanup(now time.Time) {
+	if len(l.states) < 1024 {
+		return
+	}
+
+	for key, state := range l.states {
+		if now.Sub(state.lastFailure) > l.max && !now.Before(state.lockedUntil) {
+			delete(l.states, key)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockout(t *testing.T) {
+	type step struct {
+		after time.Duration
+		fail  bool
+		reset bool
+		// wantLock is the lock left after the step, zero when unlocked.
+		wantLock time.Duration
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "locks at the threshold",
+			steps: []step{
+				{fail: true},
+				{fail: true},
+				{fail: true, wantLock: time.Minute},
+			},
+		},
+		{
+			name: "doubles every next failure",
+			steps: []step{
+				{fail: true},
+				{fail: true},
+				{fail: true, wantLock: time.Minute},
+				{after: time.Minute, fail: true, wantLock: 2 * time.Minute},
+				{after: 2 * time.Minute, fail: true, wantLock: 4 * time.Minute},
+			},
+		},
+		{
+			name: "stops at the max",
+			steps: []step{
+				{fail: true},
+				{fail: true},
+				{fail: true, wantLock: time.Minute},
+				{fail: true, wantLock: 2 * time.Minute},
+				{fail: true, wantLock: 4 * time.Minute},
+				{fail: true, wantLock: 8 * time.Minute},
+				{fail: true, wantLock: 10 * time.Minute},
+				{fail: true, wantLock: 10 * time.Minute},
+			},
+		},
+		{
+			name: "unlocks after the lock",
+			steps: []step{
+				{fail: true},
+				{fail: true},
+				{fail: true, wantLock: time.Minute},
+				{after: 30 * time.Second, wantLock: 30 * time.Second},
+				{after: 30 * time.Second},
+			},
+		},
+		{
+			name: "reset forgets the failures",
+			steps: []step{
+				{fail: true},
+				{fail: true},
+				{reset: true},
+				{fail: true},
+				{fail: true},
+				{fail: true, wantLock: time.Minute},
+				{reset: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &clock{now: time.Unix(1_700_000_000, 0)}
+			lockout := NewLockout(3, time.Minute, 10*time.Minute)
+			lockout.now = c.Now
+
+			for i, s := range tt.steps {
+				c.Advance(s.after)
+				if s.fail {
+					lockout.Fail("user@corp.ru")
+				}
+				if s.reset {
+					lockout.Reset("user@corp.ru")
+				}
+
+				until, locked := lockout.LockedUntil("user@corp.ru")
+				if locked != (s.wantLock > 0) || locked && until.Sub(c.now) != s.wantLock {
+					t.Fatalf("step %d: LockedUntil() = %v, %v, want a lock of %v", i, until.Sub(c.now), locked, s.wantLock)
+				}
+
+				if _, locked := lockout.LockedUntil("other@corp.ru"); locked {
+					t.Fatalf("step %d: another key is locked", i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"errors"
+	"github.com/krevetkou/test-rutube/internal/auth"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"strings"
+)
+
+const BootstrapAdminName = "Administrator"
+
+// Authorize checks that the owner of the token has the permission.
+func (s UsersService) Authorize(token string, permission domain.Permission) error {
+	user, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.ErrNotExists
+	}
+
+	if !user.Role.Can(permission) {
+		return domain.ErrForbidden
+	}
+
+	return nil
+}
+
+// BootstrapAdmin gives the admin role to the account with the email. The account
+// is created with the password when it doesn't exist yet.
+func (s UsersService) BootstrapAdmin(email, password string) error {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return nil
+	}
+
+	user, err := s.Storage.GetUserByEmail(email)
+	if errors.Is(err, domain.ErrNotFound) {
+		user, err = s.createAdmin(email, password)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Storage.SetRole(user.ID, domain.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("user %s has the admin role", user.Email)
+
+	return nil
+}
+
+func (s UsersService) createAdmin(email, password string) (domain.User, error) {
+	if password == "" {
+		return domain.User{}, domain.ErrFieldsRequired
+	}
+
+	err := s.Passwords.Validate(password)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	user, err := s.Storage.InsertUser(domain.RegisterRequest{
+		Email:    email,
+		Password: hash,
+		Name:     BootstrapAdminName,
+	})
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	err = s.Storage.MarkVerified(user.ID, user.Email)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	return user, nil
+}
+
+func (s UsersService) GetUsers() ([]domain.AdminUserResponse, error) {
+	return s.Storage.GetUsers()
+}
+
+func (s UsersService) SetRole(token string, id int, role domain.Role) (domain.AdminUserResponse, error) {
+	if !role.IsValid() {
+		return domain.AdminUserResponse{}, domain.ErrInvalidSettings
+	}
+
+	err := s.checkNotSelf(token, id)
+	if err != nil {
+		return domain.AdminUserResponse{}, err
+	}
+
+	user, err := s.Storage.SetRole(id, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.AdminUserResponse{}, domain.ErrNotFound
+		}
+		return domain.AdminUserResponse{}, err
+	}
+
+	return user, nil
+}
+
+func (s UsersService) SetDisabled(token string, id int, disabled bool) (domain.AdminUserResponse, error) {
+	err := s.checkNotSelf(token, id)
+	if err != nil {
+		return domain.AdminUserResponse{}, err
+	}
+
+	user, err := s.Storage.SetDisabled(id, disabled)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.AdminUserResponse{}, domain.ErrNotFound
+		}
+		return domain.AdminUserResponse{}, err
+	}
+
+	s.usersChanged()
+
+	return user, nil
+}
+
+func (s UsersService) DeleteUser(token string, id int) error {
+	err := s.checkNotSelf(token, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.Storage.DeleteUser(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.ErrNotFound
+		}
+		return err
+	}
+
+	s.usersChanged()
+
+	return nil
+}
+
+func (s UsersService) UpdateUser(id int, settings domain.AdminSettingsRequest) (domain.AdminUserResponse, error) {
+	_, err := validateSettings(domain.SettingsRequest{
+		DaysToNotification: settings.DaysToNotification,
+		DateOfBirth:        settings.DateOfBirth,
+	})
+	if err != nil {
+		return domain.AdminUserResponse{}, err
+	}
+
+	if settings.Department != nil {
+		department := strings.TrimSpace(*settings.Department)
+		settings.Department = &department
+	}
+
+	user, err := s.Storage.UpdateUser(id, settings)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.AdminUserResponse{}, domain.ErrNotFound
+		}
+		return domain.AdminUserResponse{}, err
+	}
+
+	s.usersChanged()
+
+	return user, nil
+}
+
+// checkNotSelf keeps admins from locking themselves out, so there is always
+// at least one admin left.
+func (s UsersService) checkNotSelf(token string, id int) error {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.ErrNotExists
+	}
+
+	if currentUser.ID == id {
+		return domain.ErrSelfManage
+	}
+
+	return nil
+}
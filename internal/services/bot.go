@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/krevetkou/test-rutube/internal/auth"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"github.com/krevetkou/test-rutube/internal/ratelimit"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// BotLinkTTL is how long the one-time code for linking a chat is valid.
+	BotLinkTTL = time.Minute * 15
+	// BotPollTimeout is how long a request for new messages waits on the server.
+	BotPollTimeout = time.Second * 30
+	// BotRetryInterval is the pause after the bot API failed.
+	BotRetryInterval = time.Second * 5
+	BotSendTimeout   = time.Second * 10
+	BotUpcomingDays  = 7
+	// BotMaxBirthdays keeps the answers within the message size limit.
+	BotMaxBirthdays = 50
+)
+
+const botHelp = `Commands:
+/today - birthdays today
+/upcoming [days] - birthdays in the next days, 7 by default
+/subscribe <email> - follow a colleague
+/unlink - stop sending reminders to this chat`
+
+// BotAPI talks to a chat bot server, the Telegram Bot API or a compatible one.
+type BotAPI interface {
+	GetUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]domain.BotUpdate, error)
+	SendMessage(ctx context.Context, chatID int64, text string) error
+}
+
+type BotRepository interface {
+	UserLookup
+	GetUserByEmail(email string) (domain.User, error)
+	GetUpcomingBirthdays(userID int, days int) ([]domain.UpcomingBirthdayResponse, error)
+	SetBotLinkCode(id int, hash string, expires time.Time) error
+	LinkBotChat(hash string, chatID int64, now time.Time) (domain.User, error)
+	UnlinkBotChat(id int) error
+	GetUserByBotChat(chatID int64) (domain.User, error)
+}
+
+// BotService answers the commands of the chat bot and links the chats to
+// the accounts. Users follow colleagues from the chat as from the app.
+type BotService struct {
+	Storage BotRepository
+	Users   UsersService
+	// Bot is nil when the chat bot isn't configured.
+	Bot BotAPI
+
+	linkByChat *ratelimit.Limiter
+}
+
+func NewBotService(storage BotRepository, users UsersService, bot BotAPI) BotService {
+	return BotService{
+		Storage: storage,
+		Users:   users,
+		Bot:     bot,
+
+		linkByChat: ratelimit.NewLimiter(ratelimit.Every(time.Minute), 5),
+	}
+}
+
+// CreateBotLink issues a one-time code, the chat that sends it to the bot
+// gets linked to the account. A new code replaces the previous one.
+func (s BotService) CreateBotLink(token string) (domain.BotLinkResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.BotLinkResponse{}, domain.ErrNotExists
+	}
+
+	if s.Bot == nil {
+		return domain.BotLinkResponse{}, domain.ErrBotDisabled
+	}
+
+	codes, err := auth.NewRecoveryCodes(1)
+	if err != nil {
+		return domain.BotLinkResponse{}, err
+	}
+
+	expires := time.Now().Add(BotLinkTTL)
+	err = s.Storage.SetBotLinkCode(currentUser.ID, auth.HashToken(codes[0]), expires)
+	if err != nil {
+		return domain.BotLinkResponse{}, err
+	}
+
+	return domain.BotLinkResponse{
+		Code:      codes[0],
+		Command:   "/link " + codes[0],
+		ExpiresAt: expires,
+	}, nil
+}
+
+func (s BotService) GetBotStatus(token string) (domain.BotStatusResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.BotStatusResponse{}, domain.ErrNotExists
+	}
+
+	status := domain.BotStatusResponse{
+		Enabled: s.Bot != nil,
+		Linked:  currentUser.BotChatID != 0,
+	}
+	if status.Linked {
+		status.LinkedAt = &currentUser.BotLinkedAt
+	}
+
+	return status, nil
+}
+
+func (s BotService) UnlinkBot(token string) error {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.ErrNotExists
+	}
+
+	err = s.Storage.UnlinkBotChat(currentUser.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.ErrNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// StartBot answers the messages of the bot until the context is done.
+func (s BotService) StartBot(ctx context.Context) {
+	var offset int64
+	for {
+		updates, err := s.Bot.GetUpdates(ctx, offset, BotPollTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("chat bot: %s", err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(BotRetryInterval):
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			// the next request confirms the update, so it isn't answered twice
+			offset = update.ID + 1
+
+			reply := s.botReply(update)
+			if reply == "" {
+				continue
+			}
+
+			err = s.Bot.SendMessage(ctx, update.ChatID, reply)
+			if err != nil {
+				log.Printf("chat bot: %s", err)
+			}
+		}
+	}
+}
+
+func (s BotService) botReply(update domain.BotUpdate) string {
+	command, arg := parseBotCommand(update.Text)
+	if command == "" {
+		return ""
+	}
+
+	if !update.Private {
+		return "Write to me in a private chat."
+	}
+
+	switch command {
+	case "/start":
+		if arg == "" {
+			return "Hi! Get a code in the settings of your account and send /link <code>.\n\n" + botHelp
+		}
+		return s.linkBotChat(update.ChatID, arg)
+	case "/link":
+		return s.linkBotChat(update.ChatID, arg)
+	case "/help":
+		return botHelp
+	}
+
+	user, err := s.Storage.GetUserByBotChat(update.ChatID)
+	if err != nil {
+		return "This chat isn't linked to an account. Get a code in the settings of your account and send /link <code>."
+	}
+
+	switch command {
+	case "/today":
+		return s.botBirthdays(user, 0)
+	case "/upcoming":
+		days := BotUpcomingDays
+		if arg != "" {
+			days, err = strconv.Atoi(arg)
+			if err != nil || days < 1 || days > MaxDaysToNotification {
+				return fmt.Sprintf("The number of days should be from 1 to %d.", MaxDaysToNotification)
+			}
+		}
+		return s.botBirthdays(user, days)
+	case "/subscribe":
+		return s.botSubscribe(user, arg)
+	case "/unlink":
+		err = s.Storage.UnlinkBotChat(user.ID)
+		if err != nil {
+			log.Println(err)
+			return "Failed to unlink the chat, try again later."
+		}
+		return "The chat is unlinked, reminders will come by email."
+	default:
+		return "Unknown command.\n\n" + botHelp
+	}
+}
+
+// parseBotCommand splits "/command@bot argument" into the command and the argument.
+func parseBotCommand(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", ""
+	}
+
+	command, arg, _ := strings.Cut(text, " ")
+	command, _, _ = strings.Cut(command, "@")
+
+	return strings.ToLower(command), strings.TrimSpace(arg)
+}
+
+func (s BotService) linkBotChat(chatID int64, code string) string {
+	if code == "" {
+		return "Send the code from the settings of your account: /link <code>."
+	}
+
+	if !s.linkByChat.Allow(strconv.FormatInt(chatID, 10)) {
+		return "Too many attempts, try again later."
+	}
+
+	user, err := s.Storage.LinkBotChat(auth.HashToken(auth.NormalizeRecoveryCode(code)), chatID, time.Now())
+	if err != nil {
+		return "The code is invalid or expired, get a new one in the settings of your account."
+	}
+
+	return fmt.Sprintf("Hi, %s! The chat is linked, birthday reminders will come here.\n\n%s", user.Name, botHelp)
+}
+
+func (s BotService) botBirthdays(user domain.User, days int) string {
+	birthdays, err := s.Storage.GetUpcomingBirthdays(user.ID, days)
+	if err != nil {
+		log.Println(err)
+		return "Failed to get the birthdays, try again later."
+	}
+
+	if len(birthdays) == 0 {
+		if days == 0 {
+			return "No birthdays today."
+		}
+		return fmt.Sprintf("No birthdays in the next %d days.", days)
+	}
+
+	title := "Birthdays today:"
+	if days > 0 {
+		title = fmt.Sprintf("Birthdays in the next %d days:", days)
+	}
+
+	lines := []string{title}
+	now := time.Now()
+	for i, birthday := range birthdays {
+		if i == BotMaxBirthdays {
+			lines = append(lines, fmt.Sprintf("and %d more", len(birthdays)-i))
+			break
+		}
+
+		line := "• " + birthday.Name
+		switch birthday.DaysLeft {
+		case 0:
+			line += " - today"
+		case 1:
+			line += " - tomorrow"
+		default:
+			line += fmt.Sprintf(" - %s, in %d days", now.AddDate(0, 0, birthday.DaysLeft).Format("2 January"), birthday.DaysLeft)
+		}
+		if birthday.IsSubscribed {
+			line += " (following)"
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (s BotService) botSubscribe(user domain.User, email string) string {
+	if email == "" {
+		return "Send the email of the colleague: /subscribe <email>."
+	}
+
+	// users that hide their email can't be found by it
+	target, err := s.Storage.GetUserByEmail(strings.TrimSpace(email))
+	if err != nil || !target.Verified || target.Disabled || target.VisibleEmail(user.ID) == "" {
+		return "No colleague with this email."
+	}
+
+	response, err := s.Users.subscribe(user, target.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrExists):
+			return "You already follow " + target.Name + "."
+		case errors.Is(err, domain.ErrSelfSubscribe):
+			return "You can't follow yourself."
+		case errors.Is(err, domain.ErrBlocked):
+			return "You can't follow " + target.Name + "."
+		case errors.Is(err, domain.ErrFollowLimit):
+			return "You follow too many colleagues, unsubscribe from someone first."
+		case errors.Is(err, domain.ErrTeamOnly):
+			return target.Name + " accepts followers only from their team."
+		}
+		log.Println(err)
+		return "Failed to subscribe, try again later."
+	}
+
+	if response.Pending {
+		return target.Name + " approves their followers, the request is sent."
+	}
+
+	return "You now follow " + target.Name + "."
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"github.com/krevetkou/test-rutube/internal/auth"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"slices"
+	"strings"
+	"time"
+)
+
+// OpenDashboard checks the token of an office dashboard and subscribes it to
+// the public events. Dashboards run on shared screens, so only personal
+// tokens that can't change anything are accepted.
+func (s UsersService) OpenDashboard(token string) (<-chan domain.Event, func(), error) {
+	_, err := s.dashboardViewer(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if s.Events == nil {
+		return nil, nil, domain.ErrEventsDisabled
+	}
+
+	events, cancel := s.Events.Subscribe(domain.PublicStream, "")
+
+	return events, cancel, nil
+}
+
+// GetDashboard returns today's and upcoming birthdays for the date in the
+// time zone of the office. The screen is seen by anyone in the office, so
+// the lists are built for an anonymous viewer, not the owner of the token.
+func (s UsersService) GetDashboard(token string, now time.Time) (domain.DashboardResponse, error) {
+	_, err := s.dashboardViewer(token)
+	if err != nil {
+		return domain.DashboardResponse{}, err
+	}
+
+	return s.Storage.GetDashboard(0, now.In(s.Config.TimeZone), s.Config.DashboardDays)
+}
+
+// CheckDashboard reports whether the token still opens the dashboard, the
+// open dashboards are closed when it was revoked or has expired.
+func (s UsersService) CheckDashboard(token string) error {
+	_, err := s.dashboardViewer(token)
+
+	return err
+}
+
+func (s UsersService) dashboardViewer(token string) (domain.User, error) {
+	if !strings.HasPrefix(token, domain.PersonalTokenPrefix) {
+		return domain.User{}, domain.ErrReadOnlyToken
+	}
+
+	personalToken, err := s.Storage.GetPersonalToken(auth.HashToken(token), time.Now())
+	if err != nil {
+		return domain.User{}, domain.ErrInvalidToken
+	}
+
+	if !slices.Equal(personalToken.Scopes, []string{domain.ScopeReadBirthdays}) {
+		return domain.User{}, domain.ErrReadOnlyToken
+	}
+
+	user, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.User{}, domain.ErrInvalidToken
+	}
+
+	return user, nil
+}
+
+// usersChanged makes the dashboards build the lists again after users were
+// added, changed or removed.
+func (s UsersService) usersChanged() {
+	publish(s.Events, domain.PublicStream, domain.EventUsersChanged, nil)
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/krevetkou/test-rutube/internal/directory"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyncDirectory makes the users match the directory: new employees are
+// created, changed ones updated, and the ones that are gone deactivated.
+// Only accounts that came from the directory are deactivated, so local
+// accounts are kept. Running it again with the same records changes nothing.
+func (s UsersService) SyncDirectory(records []domain.DirectoryRecord) (domain.SyncReport, error) {
+	report := domain.SyncReport{
+		StartedAt:   time.Now(),
+		Created:     make([]string, 0),
+		Updated:     make([]string, 0),
+		Deactivated: make([]string, 0),
+		Reactivated: make([]string, 0),
+		Errors:      make([]domain.DirectoryEntryError, 0),
+	}
+
+	// an empty result is more likely a broken filter than everyone leaving
+	if len(records) == 0 {
+		return report, domain.ErrEmptyDirectory
+	}
+
+	present := make(map[string]bool)
+	for _, entry := range records {
+		key := strings.ToLower(strings.TrimSpace(entry.Email))
+
+		record, err := validateImportRecord(entry.ImportRecord)
+		if err == nil && present[key] {
+			err = errors.New("duplicate email")
+		}
+		if key != "" {
+			// users with broken entries aren't deactivated
+			present[key] = true
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, domain.DirectoryEntryError{DN: entry.DN, Email: entry.Email, Error: err.Error()})
+			continue
+		}
+
+		err = s.syncDirectoryUser(record, &report)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	users, err := s.Storage.GetDirectoryUsers()
+	if err != nil {
+		return report, err
+	}
+
+	for _, user := range users {
+		if present[strings.ToLower(user.Email)] || user.LeftDirectory {
+			continue
+		}
+
+		removed, err := s.Storage.DeactivateUser(user.ID)
+		if err != nil {
+			return report, err
+		}
+		report.Deactivated = append(report.Deactivated, user.Email)
+		report.RemovedSubscriptions += removed
+	}
+
+	report.FinishedAt = time.Now()
+
+	if len(report.Created)+len(report.Updated)+len(report.Deactivated)+len(report.Reactivated) > 0 {
+		s.usersChanged()
+	}
+
+	return report, nil
+}
+
+func (s UsersService) syncDirectoryUser(record domain.ImportRecord, report *domain.SyncReport) error {
+	user, err := s.Storage.GetUserByEmail(record.Email)
+	if errors.Is(err, domain.ErrNotFound) {
+		err = s.createImported(record)
+		if err != nil {
+			return err
+		}
+
+		user, err = s.Storage.GetUserByEmail(record.Email)
+		if err != nil {
+			return err
+		}
+
+		report.Created = append(report.Created, user.Email)
+		return s.Storage.SetFromDirectory(user.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	changed := user.Name != record.Name || user.DateOfBirth != record.DateOfBirth || user.Department != record.Department
+	if changed {
+		err = s.Storage.UpdateImported(user.ID, record)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case user.LeftDirectory:
+		report.Reactivated = append(report.Reactivated, user.Email)
+	case changed || !user.FromDirectory:
+		report.Updated = append(report.Updated, user.Email)
+	default:
+		report.Unchanged++
+		return nil
+	}
+
+	return s.Storage.SetFromDirectory(user.ID)
+}
+
+// DirectorySync runs SyncDirectory with the entries of the source on a schedule
+// and keeps the last report.
+type DirectorySync struct {
+	Users   UsersService
+	Source  directory.Source
+	Mapping directory.Mapping
+
+	running sync.Mutex
+	mu      sync.Mutex
+	last    *domain.SyncReport
+}
+
+func NewDirectorySync(users UsersService, source directory.Source, mapping directory.Mapping) *DirectorySync {
+	return &DirectorySync{
+		Users:   users,
+		Source:  source,
+		Mapping: mapping,
+	}
+}
+
+// Run syncs the directory now, runs don't overlap.
+func (d *DirectorySync) Run(ctx context.Context) (domain.SyncReport, error) {
+	d.running.Lock()
+	defer d.running.Unlock()
+
+	entries, err := d.Source.Entries(ctx)
+	if err != nil {
+		return domain.SyncReport{}, err
+	}
+
+	records := make([]domain.DirectoryRecord, 0, len(entries))
+	for _, entry := range entries {
+		// units, groups and other entries without an email aren't people
+		if entry.Get(d.Mapping.Email) == "" {
+			continue
+		}
+		records = append(records, d.Mapping.Record(entry))
+	}
+
+	report, err := d.Users.SyncDirectory(records)
+	if err != nil {
+		return report, err
+	}
+
+	d.mu.Lock()
+	d.last = &report
+	d.mu.Unlock()
+
+	return report, nil
+}
+
+func (d *DirectorySync) LastReport() (domain.SyncReport, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.last == nil {
+		return domain.SyncReport{}, domain.ErrNotFound
+	}
+
+	return *d.last, nil
+}
+
+// Start syncs right away and then every interval until the context is done.
+func (d *DirectorySync) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := d.Run(ctx)
+		if err != nil {
+			log.Printf("directory sync: %s", err)
+		} else {
+			log.Printf("directory sync: created %d, updated %d, deactivated %d, reactivated %d, unchanged %d, errors %d",
+				len(report.Created), len(report.Updated), len(report.Deactivated), len(report.Reactivated), report.Unchanged, len(report.Errors))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/krevetkou/test-rutube/internal/auth"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"net/url"
+	"time"
+)
+
+const (
+	EmailConfirmTTL = time.Hour * 24
+	EmailRevertTTL  = time.Hour * 24 * 7
+)
+
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+func (s UsersService) ConfirmEmail(token string) (domain.UserResponse, error) {
+	claims, err := s.Tokens.Parse(token, auth.PurposeEmailConfirm)
+	if err != nil {
+		return domain.UserResponse{}, domain.ErrInvalidToken
+	}
+
+	id, err := claims.UserID()
+	if err != nil {
+		return domain.UserResponse{}, domain.ErrInvalidToken
+	}
+
+	user, err := s.Storage.ConfirmEmail(id, claims.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists), errors.Is(err, domain.ErrInvalidToken):
+			return domain.UserResponse{}, domain.ErrInvalidToken
+		case errors.Is(err, domain.ErrExists):
+			return domain.UserResponse{}, domain.ErrExists
+		}
+		return domain.UserResponse{}, err
+	}
+
+	return user, nil
+}
+
+func (s UsersService) RevertEmail(token string) (domain.UserResponse, error) {
+	claims, err := s.Tokens.Parse(token, auth.PurposeEmailRevert)
+	if err != nil {
+		return domain.UserResponse{}, domain.ErrInvalidToken
+	}
+
+	id, err := claims.UserID()
+	if err != nil {
+		return domain.UserResponse{}, domain.ErrInvalidToken
+	}
+
+	user, err := s.Storage.RevertEmail(id, claims.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists), errors.Is(err, domain.ErrInvalidToken):
+			return domain.UserResponse{}, domain.ErrInvalidToken
+		case errors.Is(err, domain.ErrExists):
+			return domain.UserResponse{}, domain.ErrExists
+		}
+		return domain.UserResponse{}, err
+	}
+
+	return user, nil
+}
+
+// requestEmailChange sends a confirmation link to the new address, keeps it
+// pending and sends a notice with a revert link to the current address.
+// The link is sent first, so a change that can't be confirmed isn't saved.
+func (s UsersService) requestEmailChange(user domain.User, email string) error {
+	confirmToken, err := s.Tokens.Sign(user.ID, auth.PurposeEmailConfirm, email, EmailConfirmTTL)
+	if err != nil {
+		return err
+	}
+
+	revertToken, err := s.Tokens.Sign(user.ID, auth.PurposeEmailRevert, user.Email, EmailRevertTTL)
+	if err != nil {
+		return err
+	}
+
+	err = s.Mailer.Send(email, "Confirm your new email",
+		fmt.Sprintf("To confirm your new email open the link: %s", s.link("/user/email/confirm", confirmToken)))
+	if err != nil {
+		log.Println(err)
+		return domain.ErrMailNotSent
+	}
+
+	_, err = s.Storage.SetPendingEmail(user.ID, email)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrExists):
+			return domain.ErrExists
+		case errors.Is(err, domain.ErrNotExists):
+			return domain.ErrNotExists
+		}
+		return err
+	}
+
+	err = s.Mailer.Send(user.Email, "Your email is being changed",
+		fmt.Sprintf("The email of your account is being changed to %s.\n"+
+			"If it wasn't you, open the link to keep the current email: %s", email, s.link("/user/email/revert", revertToken)))
+	if err != nil {
+		log.Println(err)
+	}
+
+	return nil
+}
+
+func (s UsersService) link(path, token string) string {
+	return s.Config.BaseURL + path + "?token=" + url.QueryEscape(token)
+}
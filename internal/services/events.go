@@ -0,0 +1,55 @@
+package services
+
+import (
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+// EventBroker delivers the events to the open streams of the users. The
+// in-process hub serves a single instance, a broker behind the same interface
+// is needed when several instances run.
+type EventBroker interface {
+	Publish(userID int, eventType domain.EventType, data any)
+	Subscribe(userID int, lastEventID string) (<-chan domain.Event, func())
+}
+
+// SubscribeEvents opens the event stream of the user, lastEventID resumes it
+// after a reconnect.
+func (s UsersService) SubscribeEvents(token string, lastEventID string) (<-chan domain.Event, func(), error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return nil, nil, domain.ErrNotExists
+	}
+
+	if s.Events == nil {
+		return nil, nil, domain.ErrEventsDisabled
+	}
+
+	events, cancel := s.Events.Subscribe(currentUser.ID, lastEventID)
+
+	return events, cancel, nil
+}
+
+// CheckSession reports whether the token still opens a session. Open streams
+// check it, as the session can end by a logout, a password reset, or the
+// account being disabled or gone from the directory.
+func (s UsersService) CheckSession(token string) error {
+	_, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.ErrNotExists
+	}
+
+	return nil
+}
+
+// publish pushes the event if the broker is set, the streams are optional.
+func publish(events EventBroker, userID int, eventType domain.EventType, data any) {
+	if events == nil {
+		return
+	}
+
+	events.Publish(userID, eventType, data)
+}
+
+func subscriptionChanged(events EventBroker, userID int, event domain.SubscriptionEvent) {
+	publish(events, userID, domain.EventSubscription, event)
+}
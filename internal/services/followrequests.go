@@ -0,0 +1,131 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/krevetkou/test-rutube/internal/config"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"time"
+)
+
+type FollowRequestsRepository interface {
+	UserLookup
+	InsertFollowRequest(followerID, targetID int, maxFollows int, now time.Time) (domain.FollowRequestResponse, error)
+	GetFollowRequests(userID int, incoming bool) ([]domain.FollowRequestResponse, error)
+	ApproveFollowRequest(targetID, id int, maxFollows int) (domain.FollowRequest, error)
+	DeleteFollowRequest(userID, id int, incoming bool) error
+}
+
+// FollowRequestsService keeps the follows that wait for the approval of the
+// followed user.
+type FollowRequestsService struct {
+	Storage       FollowRequestsRepository
+	Mailer        Mailer
+	Events        EventBroker
+	Notifications NotificationsService
+	Config        config.Config
+}
+
+func NewFollowRequestsService(storage FollowRequestsRepository, mailer Mailer, events EventBroker, notifications NotificationsService, cfg config.Config) FollowRequestsService {
+	return FollowRequestsService{
+		Storage:       storage,
+		Mailer:        mailer,
+		Events:        events,
+		Notifications: notifications,
+		Config:        cfg,
+	}
+}
+
+// requestFollow asks the target to approve the follower and lets them know.
+func (s FollowRequestsService) requestFollow(follower, target domain.User) error {
+	request, err := s.Storage.InsertFollowRequest(follower.ID, target.ID, s.Config.MaxFollows, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrExists):
+			return domain.ErrExists
+		case errors.Is(err, domain.ErrNotExists):
+			return domain.ErrNotFound
+		case errors.Is(err, domain.ErrBlocked):
+			return domain.ErrBlocked
+		case errors.Is(err, domain.ErrFollowLimit):
+			return domain.ErrFollowLimit
+		}
+		return err
+	}
+
+	// the request is kept even when the letter can't be sent
+	err = s.Mailer.Send(target.Email, "New follow request",
+		fmt.Sprintf("%s wants to follow your birthday. Approve or reject the request in the app.", follower.Name))
+	if err != nil {
+		log.Println(err)
+	}
+
+	s.Notifications.notify(target.ID, domain.NotificationFollowRequest, "", map[string]any{
+		"requestId": request.ID,
+		"user":      userPayload(follower),
+	})
+
+	return nil
+}
+
+func (s FollowRequestsService) GetFollowRequests(token string, incoming bool) ([]domain.FollowRequestResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return []domain.FollowRequestResponse{}, domain.ErrNotExists
+	}
+
+	return s.Storage.GetFollowRequests(currentUser.ID, incoming)
+}
+
+func (s FollowRequestsService) ApproveFollowRequest(token string, id int) error {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.ErrNotExists
+	}
+
+	request, err := s.Storage.ApproveFollowRequest(currentUser.ID, id, s.Config.MaxFollows)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.ErrNotFound
+		case errors.Is(err, domain.ErrFollowLimit):
+			return domain.ErrFollowLimit
+		}
+		return err
+	}
+
+	s.Notifications.notify(request.FollowerID, domain.NotificationFollowApproved, "", map[string]any{
+		"requestId": request.ID,
+		"user":      userPayload(currentUser),
+	})
+	subscriptionChanged(s.Events, request.FollowerID, domain.SubscriptionEvent{Action: domain.SubscriptionAdded, UserID: currentUser.ID})
+
+	return nil
+}
+
+func (s FollowRequestsService) RejectFollowRequest(token string, id int) error {
+	return s.deleteFollowRequest(token, id, true)
+}
+
+func (s FollowRequestsService) CancelFollowRequest(token string, id int) error {
+	return s.deleteFollowRequest(token, id, false)
+}
+
+func (s FollowRequestsService) deleteFollowRequest(token string, id int, incoming bool) error {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.ErrNotExists
+	}
+
+	err = s.Storage.DeleteFollowRequest(currentUser.ID, id, incoming)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.ErrNotFound
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"strings"
+	"time"
+)
+
+const MaxFundTitleLength = 200
+
+type FundsRepository interface {
+	UserLookup
+	InsertFund(fund domain.Fund) (domain.FundResponse, error)
+	GetFunds(userID int) ([]domain.FundResponse, error)
+	GetFund(userID, id int) (domain.FundResponse, error)
+	InviteFollowers(userID, id int) (domain.FundResponse, []int, error)
+	SetPledge(userID, id, participantID int, request domain.PledgeRequest, now time.Time) (domain.FundResponse, error)
+	DeleteFund(userID, id int) error
+}
+
+// FundsService collects the money for birthday gifts.
+type FundsService struct {
+	Storage FundsRepository
+	Mailer  Mailer
+}
+
+func NewFundsService(storage FundsRepository, mailer Mailer) FundsService {
+	return FundsService{
+		Storage: storage,
+		Mailer:  mailer,
+	}
+}
+
+// CreateFund opens a gift fund for the next birthday of the user and invites
+// their followers. Without a deadline the money is collected until the day
+// before the birthday.
+func (s FundsService) CreateFund(token string, request domain.FundRequest) (domain.FundResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.FundResponse{}, domain.ErrNotExists
+	}
+
+	if currentUser.ID == request.RecipientId {
+		return domain.FundResponse{}, domain.ErrSelfFund
+	}
+
+	recipient, err := activeUser(s.Storage, request.RecipientId)
+	if err != nil {
+		return domain.FundResponse{}, err
+	}
+
+	if contains(recipient.BlockedUsers, currentUser.ID) {
+		return domain.FundResponse{}, domain.ErrBlocked
+	}
+
+	if !recipient.BirthdayVisible(currentUser.ID) {
+		return domain.FundResponse{}, domain.ErrBirthdayHidden
+	}
+
+	now := time.Now()
+	days, err := domain.DaysUntilBirthday(recipient.DateOfBirth, now)
+	if err != nil {
+		return domain.FundResponse{}, domain.ErrInvalidFund
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	birthday := today.AddDate(0, 0, days)
+
+	title := strings.TrimSpace(request.Title)
+	if title == "" {
+		title = "Gift for " + recipient.Name
+	}
+	if len([]rune(title)) > MaxFundTitleLength || request.Target <= 0 {
+		return domain.FundResponse{}, domain.ErrInvalidFund
+	}
+
+	deadline := birthday.AddDate(0, 0, -1)
+	if days == 0 {
+		deadline = birthday
+	}
+	if request.Deadline != "" {
+		deadline, err = time.Parse(domain.DateLayout, request.Deadline)
+		if err != nil || deadline.Before(today) || deadline.After(birthday) {
+			return domain.FundResponse{}, domain.ErrInvalidFund
+		}
+	}
+
+	fund, err := s.Storage.InsertFund(domain.Fund{
+		RecipientID: recipient.ID,
+		OrganizerID: currentUser.ID,
+		Title:       title,
+		Birthday:    birthday.Format(domain.DateLayout),
+		Deadline:    deadline.Format(domain.DateLayout),
+		Target:      request.Target,
+		CreatedAt:   now,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrExists):
+			return domain.FundResponse{}, domain.ErrExists
+		case errors.Is(err, domain.ErrNotExists):
+			return domain.FundResponse{}, domain.ErrNotFound
+		}
+		return domain.FundResponse{}, err
+	}
+
+	invited := make([]int, 0, len(fund.Participants))
+	for _, participant := range fund.Participants {
+		if participant.User.ID != currentUser.ID {
+			invited = append(invited, participant.User.ID)
+		}
+	}
+	s.sendFundInvitations(fund, invited)
+
+	return fund, nil
+}
+
+func (s FundsService) GetFunds(token string) ([]domain.FundResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return []domain.FundResponse{}, domain.ErrNotExists
+	}
+
+	return s.Storage.GetFunds(currentUser.ID)
+}
+
+func (s FundsService) GetFund(token string, id int) (domain.FundResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.FundResponse{}, domain.ErrNotExists
+	}
+
+	fund, err := s.Storage.GetFund(currentUser.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.FundResponse{}, domain.ErrNotFound
+		}
+		return domain.FundResponse{}, err
+	}
+
+	return fund, nil
+}
+
+// InviteFollowers invites the users that followed the recipient after the fund was opened.
+func (s FundsService) InviteFollowers(token string, id int) (domain.FundResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.FundResponse{}, domain.ErrNotExists
+	}
+
+	fund, invited, err := s.Storage.InviteFollowers(currentUser.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.FundResponse{}, domain.ErrNotFound
+		case errors.Is(err, domain.ErrForbidden):
+			return domain.FundResponse{}, domain.ErrForbidden
+		}
+		return domain.FundResponse{}, err
+	}
+
+	s.sendFundInvitations(fund, invited)
+
+	return fund, nil
+}
+
+// SetPledge records the pledge of the participant. Amounts can't be changed
+// after the deadline, but pledges can still be marked paid.
+func (s FundsService) SetPledge(token string, id, participantId int, request domain.PledgeRequest) (domain.FundResponse, error) {
+	if request.Amount == nil && request.Paid == nil {
+		return domain.FundResponse{}, domain.ErrFieldsRequired
+	}
+	if request.Amount != nil && *request.Amount < 0 {
+		return domain.FundResponse{}, domain.ErrInvalidFund
+	}
+
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.FundResponse{}, domain.ErrNotExists
+	}
+
+	fund, err := s.Storage.GetFund(currentUser.ID, id)
+	if err != nil {
+		return domain.FundResponse{}, domain.ErrNotFound
+	}
+
+	if request.Amount != nil && time.Now().Format(domain.DateLayout) > fund.Deadline {
+		return domain.FundResponse{}, domain.ErrFundClosed
+	}
+
+	fund, err = s.Storage.SetPledge(currentUser.ID, id, participantId, request, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.FundResponse{}, domain.ErrNotFound
+		case errors.Is(err, domain.ErrForbidden):
+			return domain.FundResponse{}, domain.ErrForbidden
+		}
+		return domain.FundResponse{}, err
+	}
+
+	return fund, nil
+}
+
+func (s FundsService) DeleteFund(token string, id int) error {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.ErrNotExists
+	}
+
+	err = s.Storage.DeleteFund(currentUser.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.ErrNotFound
+		case errors.Is(err, domain.ErrForbidden):
+			return domain.ErrForbidden
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s FundsService) sendFundInvitations(fund domain.FundResponse, invited []int) {
+	for _, id := range invited {
+		user, err := s.Storage.GetUserByID(id)
+		if err != nil {
+			continue
+		}
+
+		err = s.Mailer.Send(user.Email, "Gift fund for "+fund.Recipient.Name,
+			fmt.Sprintf("%s is collecting for a gift to %s for the birthday on %s, until %s. Open the fund in the app to pledge.",
+				fund.Organizer.Name, fund.Recipient.Name, fund.Birthday, fund.Deadline))
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
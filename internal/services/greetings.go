@@ -0,0 +1,307 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/krevetkou/test-rutube/internal/config"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MaxGreetingLength    = 1000
+	MaxGreetingReactions = 10
+	// maxReactionLength fits emoji joined from several code points, like families or flags.
+	maxReactionLength = 8
+)
+
+type GreetingsRepository interface {
+	UserLookup
+	InsertGreeting(greeting domain.Greeting) (domain.GreetingResponse, error)
+	GetGreetings(viewerID, recipientID int, birthday string) ([]domain.GreetingResponse, error)
+	GetGreeting(id int) (domain.Greeting, error)
+	UpdateGreeting(authorID, id int, request domain.GreetingUpdateRequest, now time.Time) (domain.GreetingResponse, error)
+	DeleteGreeting(authorID, id int) error
+}
+
+// GreetingsService keeps the greetings on the birthday pages.
+type GreetingsService struct {
+	Storage       GreetingsRepository
+	Mailer        Mailer
+	Events        EventBroker
+	Notifications NotificationsService
+	Config        config.Config
+}
+
+func NewGreetingsService(storage GreetingsRepository, mailer Mailer, events EventBroker, notifications NotificationsService, cfg config.Config) GreetingsService {
+	return GreetingsService{
+		Storage:       storage,
+		Mailer:        mailer,
+		Events:        events,
+		Notifications: notifications,
+		Config:        cfg,
+	}
+}
+
+// PostGreeting leaves a greeting on the birthday page of the user. It is
+// possible only within Config.GreetingWindowDays of the birthday.
+func (s GreetingsService) PostGreeting(token string, userId int, request domain.GreetingRequest) (domain.GreetingResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.GreetingResponse{}, domain.ErrNotExists
+	}
+
+	if currentUser.ID == userId {
+		return domain.GreetingResponse{}, domain.ErrSelfGreeting
+	}
+
+	recipient, err := activeUser(s.Storage, userId)
+	if err != nil {
+		return domain.GreetingResponse{}, err
+	}
+
+	if contains(recipient.BlockedUsers, currentUser.ID) {
+		return domain.GreetingResponse{}, domain.ErrBlocked
+	}
+
+	if !recipient.BirthdayVisible(currentUser.ID) {
+		return domain.GreetingResponse{}, domain.ErrBirthdayHidden
+	}
+
+	birthday, days, err := domain.NearestBirthday(recipient.DateOfBirth, time.Now())
+	if err != nil || !s.windowOpen(days) {
+		return domain.GreetingResponse{}, domain.ErrGreetingClosed
+	}
+
+	text, reactions, err := validateGreeting(request.Text, request.Reactions)
+	if err != nil {
+		return domain.GreetingResponse{}, err
+	}
+
+	greeting, err := s.Storage.InsertGreeting(domain.Greeting{
+		RecipientID: recipient.ID,
+		AuthorID:    currentUser.ID,
+		Birthday:    birthday.Format(domain.DateLayout),
+		Text:        text,
+		Reactions:   reactions,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			return domain.GreetingResponse{}, domain.ErrNotFound
+		}
+		return domain.GreetingResponse{}, err
+	}
+
+	// the greeting is saved even when the letter can't be sent
+	err = s.Mailer.Send(recipient.Email, "You got a birthday greeting",
+		fmt.Sprintf("%s left you a greeting: %s", currentUser.Name, greetingPreview(greeting)))
+	if err != nil {
+		log.Println(err)
+	}
+
+	s.Notifications.notify(recipient.ID, domain.NotificationGreeting, "", map[string]any{
+		"greetingId": greeting.ID,
+		"user":       userPayload(currentUser),
+		"text":       greeting.Text,
+		"reactions":  greeting.Reactions,
+	})
+	if recipient.BirthdayVisible(0) {
+		publish(s.Events, domain.PublicStream, domain.EventGreeting, domain.GreetingEvent{
+			ID:        greeting.ID,
+			Recipient: domain.UserSummary{ID: recipient.ID, Name: recipient.Name},
+			Author:    domain.UserSummary{ID: currentUser.ID, Name: currentUser.Name},
+			Text:      greeting.Text,
+			Reactions: greeting.Reactions,
+			CreatedAt: greeting.CreatedAt,
+		})
+	}
+
+	return greeting, nil
+}
+
+// GetBirthdayGreetings returns the greetings on the birthday page of the user:
+// the ones for the nearest birthday. The page is empty when the birthday is
+// hidden, and closed to the users the recipient blocked.
+func (s GreetingsService) GetBirthdayGreetings(token string, userId int) ([]domain.GreetingResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return []domain.GreetingResponse{}, domain.ErrNotExists
+	}
+
+	recipient, err := activeUser(s.Storage, userId)
+	if err != nil {
+		return []domain.GreetingResponse{}, err
+	}
+
+	if contains(recipient.BlockedUsers, currentUser.ID) {
+		return []domain.GreetingResponse{}, domain.ErrBlocked
+	}
+
+	birthday, _, err := domain.NearestBirthday(recipient.DateOfBirth, time.Now())
+	if err != nil || !recipient.BirthdayVisible(currentUser.ID) {
+		return []domain.GreetingResponse{}, nil
+	}
+
+	return s.Storage.GetGreetings(currentUser.ID, recipient.ID, birthday.Format(domain.DateLayout))
+}
+
+// GetReceivedGreetings returns the greetings for all birthdays of the current user.
+func (s GreetingsService) GetReceivedGreetings(token string) ([]domain.GreetingResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return []domain.GreetingResponse{}, domain.ErrNotExists
+	}
+
+	return s.Storage.GetGreetings(currentUser.ID, currentUser.ID, "")
+}
+
+// UpdateGreeting changes the greeting while the window for its birthday is
+// still open, the same Config.GreetingWindowDays as for posting.
+func (s GreetingsService) UpdateGreeting(token string, id int, request domain.GreetingUpdateRequest) (domain.GreetingResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.GreetingResponse{}, domain.ErrNotExists
+	}
+
+	if request.Text == nil && request.Reactions == nil {
+		return domain.GreetingResponse{}, domain.ErrFieldsRequired
+	}
+
+	if request.Text != nil {
+		text := strings.TrimSpace(*request.Text)
+		if utf8.RuneCountInString(text) > MaxGreetingLength {
+			return domain.GreetingResponse{}, domain.ErrInvalidGreeting
+		}
+		request.Text = &text
+	}
+	if request.Reactions != nil {
+		reactions, err := validateReactions(*request.Reactions)
+		if err != nil {
+			return domain.GreetingResponse{}, err
+		}
+		request.Reactions = &reactions
+	}
+
+	existing, err := s.Storage.GetGreeting(id)
+	if err != nil {
+		return domain.GreetingResponse{}, domain.ErrNotFound
+	}
+	if existing.AuthorID != currentUser.ID {
+		return domain.GreetingResponse{}, domain.ErrForbidden
+	}
+
+	birthday, err := time.Parse(domain.DateLayout, existing.Birthday)
+	if err != nil || !s.windowOpen(daysFromToday(birthday, time.Now())) {
+		return domain.GreetingResponse{}, domain.ErrGreetingClosed
+	}
+
+	greeting, err := s.Storage.UpdateGreeting(currentUser.ID, id, request, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.GreetingResponse{}, domain.ErrNotFound
+		case errors.Is(err, domain.ErrForbidden):
+			return domain.GreetingResponse{}, domain.ErrForbidden
+		case errors.Is(err, domain.ErrInvalidGreeting):
+			return domain.GreetingResponse{}, domain.ErrInvalidGreeting
+		}
+		return domain.GreetingResponse{}, err
+	}
+
+	return greeting, nil
+}
+
+func (s GreetingsService) DeleteGreeting(token string, id int) error {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.ErrNotExists
+	}
+
+	err = s.Storage.DeleteGreeting(currentUser.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.ErrNotFound
+		case errors.Is(err, domain.ErrForbidden):
+			return domain.ErrForbidden
+		}
+		return err
+	}
+
+	return nil
+}
+
+// windowOpen reports whether the birthday days from today can be greeted.
+func (s GreetingsService) windowOpen(days int) bool {
+	return days >= -s.Config.GreetingWindowDays && days <= s.Config.GreetingWindowDays
+}
+
+// daysFromToday counts the days from today to the date, negative for a past
+// one. Dates are compared in UTC, as domain.NearestBirthday does.
+func daysFromToday(date time.Time, now time.Time) int {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	return int(date.Sub(today).Hours() / 24)
+}
+
+func validateGreeting(text string, reactions []string) (string, []string, error) {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) > MaxGreetingLength {
+		return "", nil, domain.ErrInvalidGreeting
+	}
+
+	reactions, err := validateReactions(reactions)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if text == "" && len(reactions) == 0 {
+		return "", nil, domain.ErrInvalidGreeting
+	}
+
+	return text, reactions, nil
+}
+
+// validateReactions accepts only emoji and drops the repeated ones.
+func validateReactions(reactions []string) ([]string, error) {
+	if len(reactions) > MaxGreetingReactions {
+		return nil, domain.ErrInvalidGreeting
+	}
+
+	result := make([]string, 0, len(reactions))
+	for _, reaction := range reactions {
+		if !isEmoji(reaction) {
+			return nil, domain.ErrInvalidGreeting
+		}
+		if !slices.Contains(result, reaction) {
+			result = append(result, reaction)
+		}
+	}
+
+	return result, nil
+}
+
+func isEmoji(value string) bool {
+	if value == "" || utf8.RuneCountInString(value) > maxReactionLength {
+		return false
+	}
+
+	for _, r := range value {
+		if r < utf8.RuneSelf || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func greetingPreview(greeting domain.GreetingResponse) string {
+	return strings.TrimSpace(greeting.Text + " " + strings.Join(greeting.Reactions, " "))
+}
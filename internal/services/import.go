@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// ImportUsers creates and updates users by email. Imported users have no
+// password, they log in with single sign-on or set it with the password reset.
+// Their emails come from HR and are trusted as verified. With dryRun nothing
+// is saved, the result shows what would happen.
+func (s UsersService) ImportUsers(records []domain.ImportRecord, dryRun bool) (domain.ImportResult, error) {
+	result := domain.ImportResult{
+		DryRun: dryRun,
+		Errors: make([]domain.ImportRowError, 0),
+	}
+
+	seen := make(map[string]int)
+	for i, record := range records {
+		row := i + 1
+
+		record, err := validateImportRecord(record)
+		if err != nil {
+			result.Skipped++
+			result.Errors = append(result.Errors, domain.ImportRowError{Row: row, Email: record.Email, Error: err.Error()})
+			continue
+		}
+
+		key := strings.ToLower(record.Email)
+		if first, ok := seen[key]; ok {
+			result.Skipped++
+			result.Errors = append(result.Errors, domain.ImportRowError{
+				Row:   row,
+				Email: record.Email,
+				Error: "duplicate of row " + strconv.Itoa(first),
+			})
+			continue
+		}
+		seen[key] = row
+
+		user, err := s.Storage.GetUserByEmail(record.Email)
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			err = nil
+			if !dryRun {
+				err = s.createImported(record)
+			}
+			if err == nil {
+				result.Created++
+			}
+		case err != nil:
+			// the row is reported as failed below
+		case user.Name == record.Name && user.DateOfBirth == record.DateOfBirth && user.Department == record.Department:
+			result.Skipped++
+		default:
+			if !dryRun {
+				err = s.Storage.UpdateImported(user.ID, record)
+			}
+			if err == nil {
+				result.Updated++
+			}
+		}
+		if err != nil {
+			log.Printf("import row %d: %s", row, err)
+			result.Failed++
+			result.Errors = append(result.Errors, domain.ImportRowError{Row: row, Email: record.Email, Error: "failed to save"})
+		}
+	}
+
+	if !dryRun && result.Created+result.Updated > 0 {
+		s.usersChanged()
+	}
+
+	return result, nil
+}
+
+func (s UsersService) createImported(record domain.ImportRecord) error {
+	user, err := s.Storage.InsertUser(domain.RegisterRequest{
+		Email:       record.Email,
+		Name:        record.Name,
+		DateOfBirth: record.DateOfBirth,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = s.Storage.MarkVerified(user.ID, user.Email)
+	if err != nil {
+		return err
+	}
+
+	return s.Storage.UpdateImported(user.ID, record)
+}
+
+func validateImportRecord(record domain.ImportRecord) (domain.ImportRecord, error) {
+	record.Email = strings.TrimSpace(record.Email)
+	record.Department = strings.TrimSpace(record.Department)
+	if record.Email == "" || strings.TrimSpace(record.Name) == "" || record.DateOfBirth == "" {
+		return record, errors.New("email, name and date of birth are required")
+	}
+
+	fields, err := validateSettings(domain.SettingsRequest{Email: &record.Email, Name: &record.Name})
+	if err != nil {
+		return record, errors.New("invalid email")
+	}
+	record.Email = *fields.Email
+	record.Name = *fields.Name
+
+	_, err = validateSettings(domain.SettingsRequest{DateOfBirth: &record.DateOfBirth})
+	if err != nil {
+		return record, errors.New("invalid date of birth, the format is 2006-01-02")
+	}
+
+	return record, nil
+}
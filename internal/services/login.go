@@ -0,0 +1,75 @@
+package services
+
+import (
+	"github.com/krevetkou/test-rutube/internal/auth"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"strings"
+)
+
+// Login answers with ErrBadCredentials both for an unknown email and a wrong
+// password. Failures are counted per email, so unknown emails get locked too
+// and the lockout doesn't reveal who is registered.
+func (s UsersService) Login(user domain.LoginRequest, ip string) (domain.UserResponse, error) {
+	if !s.loginByIP.Allow(ip) {
+		return domain.UserResponse{}, domain.ErrTooManyRequests
+	}
+
+	email := strings.ToLower(strings.TrimSpace(user.Email))
+	if !s.loginByAccount.Allow(email) {
+		return domain.UserResponse{}, domain.ErrTooManyRequests
+	}
+
+	if _, locked := s.loginLockout.LockedUntil(email); locked {
+		return domain.UserResponse{}, domain.ErrAccountLocked
+	}
+
+	userData, err := s.Storage.GetUserByEmail(email)
+	if err != nil {
+		auth.CheckNoUser(user.Password)
+		s.loginLockout.Fail(email)
+		return domain.UserResponse{}, domain.ErrBadCredentials
+	}
+
+	if !auth.CheckPassword(userData.Password, user.Password) {
+		s.loginLockout.Fail(email)
+		return domain.UserResponse{}, domain.ErrBadCredentials
+	}
+
+	if userData.Disabled {
+		return domain.UserResponse{}, domain.ErrAccountDisabled
+	}
+
+	if userData.TOTPSecret != "" {
+		challenge, err := s.Tokens.Sign(userData.ID, auth.PurposeTwoFactor, userData.Email, s.Config.TwoFactorChallengeTTL)
+		if err != nil {
+			return domain.UserResponse{}, err
+		}
+
+		return domain.UserResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+		}, nil
+	}
+
+	s.loginLockout.Reset(email)
+
+	return domain.UserResponse{
+		Email:              userData.Email,
+		DaysToNotification: userData.DaysToNotification,
+		Name:               userData.Name,
+		DateOfBirth:        userData.DateOfBirth,
+		Verified:           userData.Verified,
+		TwoFactorEnabled:   userData.TOTPSecret != "",
+		Role:               userData.Role,
+	}, nil
+}
+
+func (s UsersService) UnlockAccount(email string) error {
+	if !s.Storage.IsUserExists(email) {
+		return domain.ErrNotFound
+	}
+
+	s.loginLockout.Reset(strings.ToLower(strings.TrimSpace(email)))
+
+	return nil
+}
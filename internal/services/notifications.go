@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"strconv"
+	"time"
+)
+
+type NotificationsRepository interface {
+	UserLookup
+	InsertNotification(notification domain.Notification) (domain.NotificationResponse, error)
+	GetNotifications(userID int, query domain.NotificationQuery) (domain.NotificationListResponse, error)
+	CountUnread(userID int) (int, error)
+	MarkNotificationRead(userID, id int, now time.Time) (domain.NotificationResponse, error)
+	MarkAllNotificationsRead(userID int, now time.Time) (int, error)
+	GetDueReminders(now time.Time) ([]domain.Reminder, error)
+}
+
+// NotificationsService keeps the inboxes and sends the birthday reminders.
+type NotificationsService struct {
+	Storage NotificationsRepository
+	Mailer  Mailer
+	Events  EventBroker
+	// Bot is nil when the chat bot isn't configured.
+	Bot BotAPI
+}
+
+func NewNotificationsService(storage NotificationsRepository, mailer Mailer, events EventBroker, bot BotAPI) NotificationsService {
+	return NotificationsService{
+		Storage: storage,
+		Mailer:  mailer,
+		Events:  events,
+		Bot:     bot,
+	}
+}
+
+func (s NotificationsService) GetNotifications(token string, query domain.NotificationQuery) (domain.NotificationListResponse, error) {
+	if query.Limit < 0 {
+		return domain.NotificationListResponse{}, domain.ErrInvalidQuery
+	}
+
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.NotificationListResponse{}, domain.ErrNotExists
+	}
+
+	notifications, err := s.Storage.GetNotifications(currentUser.ID, query)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidCursor):
+			return domain.NotificationListResponse{}, domain.ErrInvalidCursor
+		}
+		return domain.NotificationListResponse{}, err
+	}
+
+	return notifications, nil
+}
+
+func (s NotificationsService) CountUnread(token string) (domain.UnreadCountResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.UnreadCountResponse{}, domain.ErrNotExists
+	}
+
+	count, err := s.Storage.CountUnread(currentUser.ID)
+	if err != nil {
+		return domain.UnreadCountResponse{}, err
+	}
+
+	return domain.UnreadCountResponse{Count: count}, nil
+}
+
+func (s NotificationsService) MarkNotificationRead(token string, id int) (domain.NotificationResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.NotificationResponse{}, domain.ErrNotExists
+	}
+
+	notification, err := s.Storage.MarkNotificationRead(currentUser.ID, id, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.NotificationResponse{}, domain.ErrNotFound
+		}
+		return domain.NotificationResponse{}, err
+	}
+
+	return notification, nil
+}
+
+func (s NotificationsService) MarkAllNotificationsRead(token string) error {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.ErrNotExists
+	}
+
+	_, err = s.Storage.MarkAllNotificationsRead(currentUser.ID, time.Now())
+	return err
+}
+
+// SendReminders puts the due birthday reminders into the inboxes and emails
+// them. Every birthday is reminded about once, so it is safe to run often.
+func (s NotificationsService) SendReminders(now time.Time) (int, error) {
+	reminders, err := s.Storage.GetDueReminders(now)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, r := range reminders {
+		birthday := r.Birthday
+		kind, key := domain.NotificationBirthday, "birthday:"+strconv.Itoa(birthday.ID)+":"+r.Date
+		if birthday.DaysLeft == 0 {
+			// the day itself is announced even if the user was reminded before
+			kind, key = domain.NotificationBirthdayToday, "today:"+strconv.Itoa(birthday.ID)+":"+r.Date
+		}
+
+		notification, ok := s.notify(r.UserID, kind, key, map[string]any{
+			"user":        map[string]any{"id": birthday.ID, "name": birthday.Name},
+			"dateOfBirth": birthday.DateOfBirth,
+			"date":        r.Date,
+			"daysLeft":    birthday.DaysLeft,
+		})
+		if !ok {
+			continue
+		}
+		sent++
+
+		if kind == domain.NotificationBirthdayToday {
+			publish(s.Events, r.UserID, domain.EventBirthdayToday, notification.Payload)
+		}
+
+		when := "today"
+		if birthday.DaysLeft == 1 {
+			when = "tomorrow"
+		} else if birthday.DaysLeft > 1 {
+			when = fmt.Sprintf("in %d days, on %s", birthday.DaysLeft, r.Date)
+		}
+
+		text := fmt.Sprintf("Hi, %s! %s has a birthday %s.", r.Name, birthday.Name, when)
+		// users with a linked chat get the reminder there, the email is the fallback
+		if s.sendBotMessage(r.ChatID, text) {
+			continue
+		}
+
+		err = s.Mailer.Send(r.Email, "Birthday of "+birthday.Name, text)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	return sent, nil
+}
+
+// StartReminders sends the due reminders right away and then every interval
+// until the context is done.
+func (s NotificationsService) StartReminders(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sent, err := s.SendReminders(time.Now())
+		if err != nil {
+			log.Printf("birthday reminders: %s", err)
+		} else if sent > 0 {
+			log.Printf("birthday reminders: sent %d", sent)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// notify puts the event into the inbox of the user. It reports false when
+// the event with the key is already there or the inbox is unavailable,
+// the event itself isn't failed because of the inbox.
+func (s NotificationsService) notify(userID int, kind domain.NotificationType, key string, payload map[string]any) (domain.NotificationResponse, bool) {
+	notification, err := s.Storage.InsertNotification(domain.Notification{
+		UserID:    userID,
+		Type:      kind,
+		Payload:   payload,
+		Key:       key,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		if !errors.Is(err, domain.ErrExists) {
+			log.Println(err)
+		}
+		return domain.NotificationResponse{}, false
+	}
+
+	publish(s.Events, userID, domain.EventNotification, notification)
+
+	return notification, true
+}
+
+// sendBotMessage reports whether the message reached the linked chat.
+func (s NotificationsService) sendBotMessage(chatID int64, text string) bool {
+	if s.Bot == nil || chatID == 0 {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), BotSendTimeout)
+	defer cancel()
+
+	err := s.Bot.SendMessage(ctx, chatID, text)
+	if err != nil {
+		log.Printf("chat bot: %s", err)
+		return false
+	}
+
+	return true
+}
+
+// userPayload names the user in notifications, the email is left out so the
+// privacy settings don't need to be checked for every recipient.
+func userPayload(user domain.User) map[string]any {
+	return map[string]any{"id": user.ID, "name": user.Name}
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/krevetkou/test-rutube/internal/auth"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const ForgotPasswordInterval = time.Minute
+
+// ForgotPassword sends a reset link if the email is registered. The result
+// doesn't depend on whether the user exists, only the per-IP limit is reported.
+func (s UsersService) ForgotPassword(email, ip string) error {
+	if !s.forgotByIP.Allow(ip) {
+		return domain.ErrTooManyRequests
+	}
+
+	email = strings.TrimSpace(email)
+	if !s.forgotByEmail.Allow(strings.ToLower(email)) {
+		return nil
+	}
+
+	user, err := s.Storage.GetUserByEmail(email)
+	if err != nil {
+		return nil
+	}
+
+	token, hash, err := auth.RandomToken()
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	err = s.Storage.SetPasswordReset(user.ID, hash, time.Now().Add(s.Config.PasswordResetTTL))
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	err = s.Mailer.Send(user.Email, "Password reset",
+		fmt.Sprintf("To set a new password use the token %s or open the link: %s\n"+
+			"If you didn't ask for it, just ignore this letter.", token, s.Config.FrontendURL+"/reset-password?token="+url.QueryEscape(token)))
+	if err != nil {
+		log.Println(err)
+	}
+
+	return nil
+}
+
+func (s UsersService) ResetPassword(request domain.ResetPasswordRequest) error {
+	if request.Token == "" {
+		return domain.ErrInvalidToken
+	}
+
+	err := s.Passwords.Validate(request.Password)
+	if err != nil {
+		return err
+	}
+
+	hash, err := auth.HashPassword(request.Password)
+	if err != nil {
+		return err
+	}
+
+	err = s.Storage.ResetPassword(auth.HashToken(request.Token), hash, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidToken):
+			return domain.ErrInvalidToken
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s UsersService) ChangePassword(token string, request domain.ChangePasswordRequest) error {
+	user, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.ErrNotExists
+	}
+
+	if !auth.CheckPassword(user.Password, request.CurrentPassword) {
+		return domain.ErrBadCredentials
+	}
+
+	err = s.Passwords.Validate(request.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	hash, err := auth.HashPassword(request.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	keepToken := ""
+	if request.LogoutOtherSessions {
+		keepToken = token
+	}
+
+	err = s.Storage.ChangePassword(user.ID, hash, keepToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			return domain.ErrNotExists
+		}
+		return err
+	}
+
+	return nil
+}
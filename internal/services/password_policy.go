@@ -0,0 +1,82 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"os"
+	"strings"
+)
+
+// PasswordPolicy checks new passwords. The breached list is a local file with
+// one password per line, or SHA-1 hashes in the "HASH[:count]" format of breach dumps.
+type PasswordPolicy struct {
+	MinLength int
+	breached  map[string]struct{}
+}
+
+func LoadPasswordPolicy(minLength int, breachedFile string) (PasswordPolicy, error) {
+	policy := PasswordPolicy{
+		MinLength: minLength,
+		breached:  make(map[string]struct{}),
+	}
+
+	if breachedFile == "" {
+		return policy, nil
+	}
+
+	file, err := os.Open(breachedFile)
+	if err != nil {
+		return PasswordPolicy{}, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if hash, _, _ := strings.Cut(line, ":"); isSHA1(hash) {
+			policy.breached[strings.ToLower(hash)] = struct{}{}
+			continue
+		}
+
+		policy.breached[sha1Hex(line)] = struct{}{}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return PasswordPolicy{}, err
+	}
+
+	return policy, nil
+}
+
+func (p PasswordPolicy) Validate(password string) error {
+	if len([]rune(password)) < p.MinLength {
+		return domain.ErrWeakPassword
+	}
+
+	if _, ok := p.breached[sha1Hex(password)]; ok {
+		return domain.ErrBreachedPassword
+	}
+
+	return nil
+}
+
+func isSHA1(value string) bool {
+	if len(value) != sha1.Size*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
+
+func sha1Hex(value string) string {
+	sum := sha1.Sum([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
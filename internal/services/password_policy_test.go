@@ -0,0 +1,72 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	breached := filepath.Join(t.TempDir(), "breached.txt")
+	lines := "password\n" +
+		"\n" +
+		"  letmein  \n" +
+		// "qwerty123" and "hunter22" in the format of breach dumps
+		"5CEC175B165E3D5E62C9E13CE848EF6FEAC81BFF:24012\n" +
+		"60b3af8bfe3735623c7d4a5ef749bb6ac1a4413a\n"
+	if err := os.WriteFile(breached, []byte(lines), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := LoadPasswordPolicy(8, breached)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  error
+	}{
+		{name: "strong", password: "correct horse battery"},
+		{name: "too short", password: "short", wantErr: domain.ErrWeakPassword},
+		{name: "counts runes", password: "пароль", wantErr: domain.ErrWeakPassword},
+		{name: "long enough in runes", password: "парольчик"},
+		{name: "plain line", password: "password", wantErr: domain.ErrBreachedPassword},
+		{name: "plain line is trimmed", password: "letmein1", wantErr: nil},
+		{name: "hash with a count", password: "qwerty123", wantErr: domain.ErrBreachedPassword},
+		{name: "hash without a count", password: "hunter22", wantErr: domain.ErrBreachedPassword},
+		{name: "case matters", password: "PASSWORD", wantErr: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := policy.Validate(tt.password); err != tt.wantErr {
+				t.Errorf("Validate(%q) error = %v, want %v", tt.password, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadPasswordPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr bool
+	}{
+		{name: "no file", file: ""},
+		{name: "missing file", file: filepath.Join(t.TempDir(), "missing.txt"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := LoadPasswordPolicy(8, tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadPasswordPolicy() error = %v, want an error: %v", err, tt.wantErr)
+			}
+			if err == nil && policy.Validate("password") != nil {
+				t.Error("an empty breached list rejected a password")
+			}
+		})
+	}
+}
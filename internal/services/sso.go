@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"github.com/krevetkou/test-rutube/internal/auth"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"strings"
+)
+
+// LoginExternal finds the account of the identity provider user and creates
+// it on the first login. An existing account with the same email is linked
+// only when the provider has verified the email. Accounts with two-factor
+// authentication get the challenge as with the password login.
+func (s UsersService) LoginExternal(identity domain.ExternalIdentity) (domain.UserResponse, error) {
+	if identity.Issuer == "" || identity.Subject == "" {
+		return domain.UserResponse{}, domain.ErrInvalidToken
+	}
+
+	user, err := s.Storage.GetUserBySubject(identity.Key())
+	if errors.Is(err, domain.ErrNotFound) {
+		user, err = s.linkExternal(identity)
+	}
+	if err != nil {
+		return domain.UserResponse{}, err
+	}
+
+	if user.Disabled {
+		return domain.UserResponse{}, domain.ErrAccountDisabled
+	}
+
+	if user.DateOfBirth == "" && identity.DateOfBirth != "" {
+		updated, err := s.UpdateUser(user.ID, domain.AdminSettingsRequest{DateOfBirth: &identity.DateOfBirth})
+		if err != nil {
+			log.Printf("birthdate of %s from the identity provider: %s", user.Email, err)
+		} else {
+			user.DateOfBirth = updated.DateOfBirth
+		}
+	}
+
+	if user.TOTPSecret != "" {
+		challenge, err := s.Tokens.Sign(user.ID, auth.PurposeTwoFactor, user.Email, s.Config.TwoFactorChallengeTTL)
+		if err != nil {
+			return domain.UserResponse{}, err
+		}
+
+		return domain.UserResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+		}, nil
+	}
+
+	return domain.UserResponse{
+		Email:              user.Email,
+		DaysToNotification: user.DaysToNotification,
+		Name:               user.Name,
+		DateOfBirth:        user.DateOfBirth,
+		Verified:           user.Verified,
+		TwoFactorEnabled:   user.TOTPSecret != "",
+		Role:               user.Role,
+	}, nil
+}
+
+func (s UsersService) linkExternal(identity domain.ExternalIdentity) (domain.User, error) {
+	if identity.Email == "" {
+		return domain.User{}, domain.ErrFieldsRequired
+	}
+
+	user, err := s.Storage.GetUserByEmail(identity.Email)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		user, err = s.createExternal(identity)
+		if err != nil {
+			return domain.User{}, err
+		}
+	case err != nil:
+		return domain.User{}, err
+	case !identity.EmailVerified:
+		return domain.User{}, domain.ErrExists
+	}
+
+	err = s.Storage.LinkSubject(user.ID, identity.Key())
+	if err != nil {
+		return domain.User{}, err
+	}
+	user.OIDCSubject = identity.Key()
+
+	return user, nil
+}
+
+// createExternal creates the account without a password,
+// such users can log in only through the identity provider.
+func (s UsersService) createExternal(identity domain.ExternalIdentity) (domain.User, error) {
+	name := identity.Name
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	fields, err := validateSettings(domain.SettingsRequest{
+		Email: &identity.Email,
+		Name:  &name,
+	})
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	user, err := s.Storage.InsertUser(domain.RegisterRequest{
+		Email: *fields.Email,
+		Name:  *fields.Name,
+	})
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	if identity.EmailVerified {
+		err = s.Storage.MarkVerified(user.ID, user.Email)
+		if err != nil {
+			return domain.User{}, err
+		}
+		user.Verified = true
+		s.usersChanged()
+	}
+
+	log.Printf("user %s is created on the first single sign-on", user.Email)
+
+	return user, nil
+}
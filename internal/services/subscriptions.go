@@ -0,0 +1,106 @@
+package services
+
+import (
+	"errors"
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func (s UsersService) GetFollowers(token string) ([]domain.FollowResponse, error) {
+	users, err := s.Storage.GetFollowers(token)
+	if err != nil {
+		return []domain.FollowResponse{}, err
+	}
+
+	return users, nil
+}
+
+func (s UsersService) GetFollowing(token string) ([]domain.FollowResponse, error) {
+	users, err := s.Storage.GetFollowing(token)
+	if err != nil {
+		return []domain.FollowResponse{}, err
+	}
+
+	return users, nil
+}
+
+func (s UsersService) GetBlocked(token string) ([]domain.FollowResponse, error) {
+	users, err := s.Storage.GetBlocked(token)
+	if err != nil {
+		return []domain.FollowResponse{}, err
+	}
+
+	return users, nil
+}
+
+func (s UsersService) RemoveFollower(token string, followerId int) error {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.ErrNotExists
+	}
+
+	err = s.Storage.RemoveFollower(token, followerId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			return domain.ErrNotExists
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.ErrNotFound
+		}
+		return err
+	}
+
+	// the follower isn't told, the same as when they are blocked
+	subscriptionChanged(s.Events, currentUser.ID, domain.SubscriptionEvent{Action: domain.FollowerRemoved, UserID: followerId})
+
+	return nil
+}
+
+func (s UsersService) Block(token string, userId int) error {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.ErrNotExists
+	}
+
+	if currentUser.ID == userId {
+		return domain.ErrSelfSubscribe
+	}
+
+	err = s.Storage.Block(token, userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrExists):
+			return domain.ErrExists
+		case errors.Is(err, domain.ErrNotExists):
+			return domain.ErrNotExists
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.ErrNotFound
+		}
+		return err
+	}
+
+	subscriptionChanged(s.Events, currentUser.ID, domain.SubscriptionEvent{Action: domain.FollowerRemoved, UserID: userId})
+
+	return nil
+}
+
+func (s UsersService) Unblock(token string, userId int) error {
+	err := s.Storage.Unblock(token, userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			return domain.ErrNotExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+func contains(s []int, e int) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"errors"
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func (s UsersService) GetTeams(token string) ([]domain.TeamResponse, error) {
+	teams, err := s.Storage.GetTeams(token)
+	if err != nil {
+		return []domain.TeamResponse{}, err
+	}
+
+	return teams, nil
+}
+
+func (s UsersService) GetTeamMembers(token string, teamID int) ([]domain.ProfileResponse, error) {
+	members, err := s.Storage.GetTeamMembers(token, teamID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			return []domain.ProfileResponse{}, domain.ErrNotExists
+		case errors.Is(err, domain.ErrNotFound):
+			return []domain.ProfileResponse{}, domain.ErrNotFound
+		}
+		return []domain.ProfileResponse{}, err
+	}
+
+	return members, nil
+}
+
+// SubscribeTeam follows the current members and everyone who joins the team
+// later. The follow limit is checked only for the current members.
+func (s UsersService) SubscribeTeam(token string, teamID int) error {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.ErrNotExists
+	}
+
+	err = s.Storage.SubscribeTeam(token, teamID, s.Config.MaxFollows)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrFollowLimit):
+			return domain.ErrFollowLimit
+		case errors.Is(err, domain.ErrExists):
+			return domain.ErrExists
+		case errors.Is(err, domain.ErrNotExists):
+			return domain.ErrNotExists
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.ErrNotFound
+		}
+		return err
+	}
+
+	subscriptionChanged(s.Events, currentUser.ID, domain.SubscriptionEvent{Action: domain.SubscriptionAdded, TeamID: teamID})
+
+	return nil
+}
+
+func (s UsersService) UnsubscribeTeam(token string, teamID int) error {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.ErrNotExists
+	}
+
+	err = s.Storage.UnsubscribeTeam(token, teamID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			return domain.ErrNotExists
+		}
+		return err
+	}
+
+	subscriptionChanged(s.Events, currentUser.ID, domain.SubscriptionEvent{Action: domain.SubscriptionRemoved, TeamID: teamID})
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"errors"
+	"github.com/krevetkou/test-rutube/internal/auth"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"slices"
+	"strings"
+	"time"
+)
+
+const MaxTokenExpiresInDays = 3650
+
+func (s UsersService) CreatePersonalToken(token string, request domain.CreateTokenRequest) (domain.CreatedTokenResponse, error) {
+	user, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.CreatedTokenResponse{}, domain.ErrNotExists
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" || len(request.Scopes) == 0 {
+		return domain.CreatedTokenResponse{}, domain.ErrFieldsRequired
+	}
+
+	if request.ExpiresInDays < 0 || request.ExpiresInDays > MaxTokenExpiresInDays {
+		return domain.CreatedTokenResponse{}, domain.ErrInvalidSettings
+	}
+
+	scopes := make([]string, 0, len(request.Scopes))
+	for _, scope := range request.Scopes {
+		if !slices.Contains(domain.Scopes, scope) {
+			return domain.CreatedTokenResponse{}, domain.ErrInvalidSettings
+		}
+		if scope == domain.ScopeAdmin && !user.Role.Can(domain.PermViewUsers) {
+			return domain.CreatedTokenResponse{}, domain.ErrForbidden
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	secret, _, err := auth.RandomToken()
+	if err != nil {
+		return domain.CreatedTokenResponse{}, domain.ErrTokenNotCreated
+	}
+	secret = domain.PersonalTokenPrefix + secret
+
+	now := time.Now()
+	personalToken := domain.PersonalToken{
+		UserID:    user.ID,
+		Name:      name,
+		Hash:      auth.HashToken(secret),
+		Hint:      secret[len(secret)-4:],
+		Scopes:    scopes,
+		CreatedAt: now,
+	}
+	if request.ExpiresInDays > 0 {
+		personalToken.ExpiresAt = now.AddDate(0, 0, request.ExpiresInDays)
+	}
+
+	personalToken, err = s.Storage.InsertPersonalToken(personalToken)
+	if err != nil {
+		return domain.CreatedTokenResponse{}, err
+	}
+
+	return domain.CreatedTokenResponse{
+		PersonalTokenResponse: personalTokenResponse(personalToken),
+		Token:                 secret,
+	}, nil
+}
+
+func (s UsersService) GetPersonalTokens(token string) ([]domain.PersonalTokenResponse, error) {
+	user, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return []domain.PersonalTokenResponse{}, domain.ErrNotExists
+	}
+
+	tokens, err := s.Storage.GetPersonalTokens(user.ID)
+	if err != nil {
+		return []domain.PersonalTokenResponse{}, err
+	}
+
+	response := make([]domain.PersonalTokenResponse, 0, len(tokens))
+	for _, t := range tokens {
+		response = append(response, personalTokenResponse(t))
+	}
+
+	return response, nil
+}
+
+func (s UsersService) RevokePersonalToken(token string, id int) error {
+	user, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.ErrNotExists
+	}
+
+	err = s.Storage.DeletePersonalToken(user.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.ErrNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// AuthorizePersonalToken checks that a personal token is active and has the scope.
+func (s UsersService) AuthorizePersonalToken(token, scope string) error {
+	personalToken, err := s.Storage.GetPersonalToken(auth.HashToken(token), time.Now())
+	if err != nil {
+		return domain.ErrInvalidToken
+	}
+
+	if !slices.Contains(personalToken.Scopes, scope) {
+		return domain.ErrForbidden
+	}
+
+	return nil
+}
+
+func personalTokenResponse(t domain.PersonalToken) domain.PersonalTokenResponse {
+	response := domain.PersonalTokenResponse{
+		ID:        t.ID,
+		Name:      t.Name,
+		Hint:      t.Hint,
+		Scopes:    t.Scopes,
+		CreatedAt: t.CreatedAt,
+	}
+	if !t.ExpiresAt.IsZero() {
+		response.ExpiresAt = &t.ExpiresAt
+	}
+	if !t.LastUsedAt.IsZero() {
+		response.LastUsedAt = &t.LastUsedAt
+	}
+
+	return response
+}
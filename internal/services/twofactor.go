@@ -0,0 +1,170 @@
+package services
+
+import (
+	"errors"
+	"github.com/krevetkou/test-rutube/internal/auth"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"strings"
+	"time"
+)
+
+const RecoveryCodesCount = 10
+
+func (s UsersService) EnrollTwoFactor(token string) (domain.TwoFactorEnrollResponse, error) {
+	user, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.TwoFactorEnrollResponse{}, domain.ErrNotExists
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		return domain.TwoFactorEnrollResponse{}, err
+	}
+
+	err = s.Storage.SetPendingTOTP(user.ID, secret)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrTwoFactorEnabled):
+			return domain.TwoFactorEnrollResponse{}, domain.ErrTwoFactorEnabled
+		case errors.Is(err, domain.ErrNotExists):
+			return domain.TwoFactorEnrollResponse{}, domain.ErrNotExists
+		}
+		return domain.TwoFactorEnrollResponse{}, err
+	}
+
+	return domain.TwoFactorEnrollResponse{
+		Secret: secret,
+		URI:    auth.TOTPURI(s.Config.TwoFactorIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor enables 2FA once the user proves the app is set up,
+// the recovery codes are shown only in this response.
+func (s UsersService) ConfirmTwoFactor(token, code string) (domain.RecoveryCodesResponse, error) {
+	user, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.RecoveryCodesResponse{}, domain.ErrNotExists
+	}
+
+	if user.TOTPSecret != "" {
+		return domain.RecoveryCodesResponse{}, domain.ErrTwoFactorEnabled
+	}
+
+	step, ok := auth.ValidateTOTP(user.TOTPPendingSecret, code, time.Now())
+	if !ok {
+		return domain.RecoveryCodesResponse{}, domain.ErrInvalidCode
+	}
+
+	codes, err := auth.NewRecoveryCodes(RecoveryCodesCount)
+	if err != nil {
+		return domain.RecoveryCodesResponse{}, err
+	}
+
+	hashes := make([]string, 0, len(codes))
+	for _, c := range codes {
+		hashes = append(hashes, auth.HashToken(c))
+	}
+
+	err = s.Storage.EnableTOTP(user.ID, user.TOTPPendingSecret, step, hashes)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrTwoFactorEnabled):
+			return domain.RecoveryCodesResponse{}, domain.ErrTwoFactorEnabled
+		case errors.Is(err, domain.ErrInvalidCode):
+			return domain.RecoveryCodesResponse{}, domain.ErrInvalidCode
+		}
+		return domain.RecoveryCodesResponse{}, err
+	}
+
+	return domain.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func (s UsersService) DisableTwoFactor(token string, request domain.TwoFactorDisableRequest) error {
+	user, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.ErrNotExists
+	}
+
+	if user.TOTPSecret == "" {
+		return domain.ErrTwoFactorOff
+	}
+
+	if !auth.CheckPassword(user.Password, request.Password) {
+		return domain.ErrBadCredentials
+	}
+
+	err = s.checkSecondFactor(user, request.Code)
+	if err != nil {
+		return err
+	}
+
+	return s.Storage.DisableTOTP(user.ID)
+}
+
+// LoginTwoFactor finishes the login started by Login for users with 2FA.
+// Wrong codes count as failed logins of the account.
+func (s UsersService) LoginTwoFactor(request domain.TwoFactorLoginRequest, ip string) (domain.UserResponse, error) {
+	if !s.loginByIP.Allow(ip) {
+		return domain.UserResponse{}, domain.ErrTooManyRequests
+	}
+
+	claims, err := s.Tokens.Parse(request.ChallengeToken, auth.PurposeTwoFactor)
+	if err != nil {
+		return domain.UserResponse{}, domain.ErrInvalidToken
+	}
+
+	id, err := claims.UserID()
+	if err != nil {
+		return domain.UserResponse{}, domain.ErrInvalidToken
+	}
+
+	key := strings.ToLower(claims.Email)
+	if _, locked := s.loginLockout.LockedUntil(key); locked {
+		return domain.UserResponse{}, domain.ErrAccountLocked
+	}
+
+	user, err := s.Storage.GetUserByID(id)
+	if err != nil || user.TOTPSecret == "" {
+		return domain.UserResponse{}, domain.ErrInvalidToken
+	}
+
+	if user.Disabled {
+		return domain.UserResponse{}, domain.ErrAccountDisabled
+	}
+
+	err = s.checkSecondFactor(user, request.Code)
+	if err != nil {
+		s.loginLockout.Fail(key)
+		return domain.UserResponse{}, err
+	}
+
+	s.loginLockout.Reset(key)
+
+	return domain.UserResponse{
+		Email:              user.Email,
+		DaysToNotification: user.DaysToNotification,
+		Name:               user.Name,
+		DateOfBirth:        user.DateOfBirth,
+		Verified:           user.Verified,
+		TwoFactorEnabled:   true,
+		Role:               user.Role,
+		Privacy:            user.Privacy,
+	}, nil
+}
+
+func (s UsersService) checkSecondFactor(user domain.User, code string) error {
+	if step, ok := auth.ValidateTOTP(user.TOTPSecret, code, time.Now()); ok {
+		err := s.Storage.UseTOTPStep(user.ID, step)
+		if err != nil {
+			return domain.ErrInvalidCode
+		}
+		return nil
+	}
+
+	err := s.Storage.UseRecoveryCode(user.ID, auth.HashToken(auth.NormalizeRecoveryCode(code)))
+	if err != nil {
+		return domain.ErrInvalidCode
+	}
+
+	return nil
+}
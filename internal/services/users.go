@@ -2,39 +2,165 @@ package services
 
 import (
 	"errors"
+	"github.com/krevetkou/test-rutube/internal/auth"
+	"github.com/krevetkou/test-rutube/internal/config"
 	"github.com/krevetkou/test-rutube/internal/domain"
+	"github.com/krevetkou/test-rutube/internal/ratelimit"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
 )
 
+// UserLookup finds the users the features work with.
+type UserLookup interface {
+	GetUserByID(id int) (domain.User, error)
+	GetUserByToken(token string) (domain.User, error)
+}
+
 type UsersRepository interface {
+	UserLookup
 	InsertUser(userReg domain.RegisterRequest) (domain.User, error)
 	IsUserExists(email string) bool
-	GetUsersByToken(token string) ([]domain.ProfileResponse, error)
+	GetUsersByToken(token string, query domain.ListQuery) (domain.ProfileListResponse, error)
 	GetAllUsers() ([]domain.UserInListResponse, error)
 	GetUserByEmail(email string) (domain.User, error)
-	CreateToken(email string) (string, error)
+	GetProfile(token string, id int) (domain.ProfileResponse, error)
+	GetUpcomingBirthdays(userID int, days int) ([]domain.UpcomingBirthdayResponse, error)
+	AddSession(id int, session domain.Session, limit int, now time.Time) error
 	GetUserInfo(token string) (domain.UserResponse, error)
 	IsTokenExists(token string) (bool, error)
-	Subscribe(token string, id int) error
+	Subscribe(followerID int, id int, maxFollows int) error
 	Unsubscribe(token string, id int) error
-	Settings(token string, daysToBirthday int, email string) error
+	Settings(token string, settings domain.SettingsRequest) (domain.UserResponse, error)
+	GetFollowers(token string) ([]domain.FollowResponse, error)
+	GetFollowing(token string) ([]domain.FollowResponse, error)
+	GetBlocked(token string) ([]domain.FollowResponse, error)
+	RemoveFollower(token string, followerId int) error
+	Block(token string, userId int) error
+	Unblock(token string, userId int) error
+	SetPendingEmail(id int, email string) (domain.UserResponse, error)
+	ConfirmEmail(id int, email string) (domain.UserResponse, error)
+	RevertEmail(id int, email string) (domain.UserResponse, error)
+	MarkVerified(id int, email string) error
+	TouchVerificationSent(id int, now time.Time, interval time.Duration) error
+	SetPasswordReset(id int, tokenHash string, expires time.Time) error
+	ResetPassword(tokenHash string, passwordHash string, now time.Time) error
+	ChangePassword(id int, passwordHash string, keepToken string) error
+	SetPendingTOTP(id int, secret string) error
+	EnableTOTP(id int, secret string, step int64, recoveryHashes []string) error
+	DisableTOTP(id int) error
+	UseTOTPStep(id int, step int64) error
+	UseRecoveryCode(id int, hash string) error
+	InsertPersonalToken(token domain.PersonalToken) (domain.PersonalToken, error)
+	GetPersonalTokens(userID int) ([]domain.PersonalToken, error)
+	GetPersonalToken(hash string, now time.Time) (domain.PersonalToken, error)
+	DeletePersonalToken(userID, id int) error
+	GetUsers() ([]domain.AdminUserResponse, error)
+	SetRole(id int, role domain.Role) (domain.AdminUserResponse, error)
+	SetDisabled(id int, disabled bool) (domain.AdminUserResponse, error)
+	UpdateUser(id int, settings domain.AdminSettingsRequest) (domain.AdminUserResponse, error)
+	DeleteUser(id int) error
+	GetUserBySubject(subject string) (domain.User, error)
+	LinkSubject(id int, subject string) error
+	UpdateImported(id int, record domain.ImportRecord) error
+	GetDirectoryUsers() ([]domain.User, error)
+	SetFromDirectory(id int) error
+	DeactivateUser(id int) (int, error)
+	GetTeams(token string) ([]domain.TeamResponse, error)
+	GetTeamMembers(token string, teamID int) ([]domain.ProfileResponse, error)
+	SubscribeTeam(token string, teamID int, maxFollows int) error
+	UnsubscribeTeam(token string, teamID int) error
+	GetDashboard(viewerID int, now time.Time, days int) (domain.DashboardResponse, error)
 }
 
+const MaxDaysToNotification = 365
+
+const (
+	// SessionTTL is how long a login lasts.
+	SessionTTL = time.Hour * 72
+	// MaxSessions is how many logins a user may have at once, a new login
+	// ends the oldest one.
+	MaxSessions = 20
+)
+
 type UsersService struct {
-	Storage UsersRepository
+	Storage   UsersRepository
+	Mailer    Mailer
+	Tokens    auth.Signer
+	Passwords PasswordPolicy
+	Config    config.Config
+	Events    EventBroker
+	// Notifications and FollowRequests tell the users they were followed
+	// and ask for the approval of the follow.
+	Notifications  NotificationsService
+	FollowRequests FollowRequestsService
+
+	forgotByIP     *ratelimit.Limiter
+	forgotByEmail  *ratelimit.Limiter
+	loginByIP      *ratelimit.Limiter
+	loginByAccount *ratelimit.Limiter
+	loginLockout   *ratelimit.Lockout
 }
 
-func NewUserService(storage UsersRepository) UsersService {
+func NewUserService(storage UsersRepository, mailer Mailer, tokens auth.Signer, passwords PasswordPolicy, events EventBroker,
+	notifications NotificationsService, followRequests FollowRequestsService, cfg config.Config) UsersService {
 	return UsersService{
-		Storage: storage,
+		Storage:        storage,
+		Mailer:         mailer,
+		Tokens:         tokens,
+		Passwords:      passwords,
+		Config:         cfg,
+		Events:         events,
+		Notifications:  notifications,
+		FollowRequests: followRequests,
+
+		forgotByIP:    ratelimit.NewLimiter(float64(cfg.PasswordForgotPerIP)/time.Hour.Seconds(), cfg.PasswordForgotPerIP),
+		forgotByEmail: ratelimit.NewLimiter(ratelimit.Every(ForgotPasswordInterval), 1),
+
+		loginByIP:      ratelimit.NewLimiter(float64(cfg.LoginPerIP)/time.Minute.Seconds(), cfg.LoginPerIP),
+		loginByAccount: ratelimit.NewLimiter(float64(cfg.LoginPerAccount)/time.Minute.Seconds(), cfg.LoginPerAccount),
+		loginLockout:   ratelimit.NewLockout(cfg.LoginMaxFailures, cfg.LoginLockout, cfg.LoginMaxLockout),
 	}
 }
 
+// activeUser hides the unverified and disabled users, as the lists do.
+func activeUser(users UserLookup, userId int) (domain.User, error) {
+	user, err := users.GetUserByID(userId)
+	if err != nil || !user.Verified || user.Disabled {
+		return domain.User{}, domain.ErrNotFound
+	}
+
+	return user, nil
+}
+
 func (s UsersService) Create(user domain.RegisterRequest) (domain.User, error) {
 	// входящие параметры необходимо валидировать
 	if user.Name == "" || user.Email == "" || user.Password == "" || user.DateOfBirth == "" {
 		return domain.User{}, domain.ErrFieldsRequired
 	}
 
+	fields, err := validateSettings(domain.SettingsRequest{
+		Email:       &user.Email,
+		Name:        &user.Name,
+		DateOfBirth: &user.DateOfBirth,
+	})
+	if err != nil {
+		return domain.User{}, err
+	}
+	user.Email = *fields.Email
+	user.Name = *fields.Name
+
+	err = s.Passwords.Validate(user.Password)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	user.Password, err = auth.HashPassword(user.Password)
+	if err != nil {
+		return domain.User{}, err
+	}
+
 	isUserExists := s.Storage.IsUserExists(user.Email)
 	if isUserExists {
 		return domain.User{}, domain.ErrExists
@@ -45,6 +171,12 @@ func (s UsersService) Create(user domain.RegisterRequest) (domain.User, error) {
 		return domain.User{}, err
 	}
 
+	err = s.sendVerification(newUser)
+	if err != nil {
+		// the user can ask for another letter after login
+		log.Println(err)
+	}
+
 	return newUser, nil
 }
 
@@ -57,39 +189,39 @@ func (s UsersService) GetAllUsers() ([]domain.UserInListResponse, error) {
 	return users, nil
 }
 
-func (s UsersService) GetUsersByToken(token string) ([]domain.ProfileResponse, error) {
-	users, err := s.Storage.GetUsersByToken(token)
+func (s UsersService) GetUsersByToken(token string, query domain.ListQuery) (domain.ProfileListResponse, error) {
+	switch query.Sort {
+	case "", domain.SortByName, domain.SortByBirthday:
+	default:
+		return domain.ProfileListResponse{}, domain.ErrInvalidQuery
+	}
+	if query.Limit < 0 {
+		return domain.ProfileListResponse{}, domain.ErrInvalidQuery
+	}
+
+	users, err := s.Storage.GetUsersByToken(token, query)
 	if err != nil {
-		return []domain.ProfileResponse{}, err
+		return domain.ProfileListResponse{}, err
 	}
 
 	return users, nil
 }
 
-func (s UsersService) Login(user domain.LoginRequest) (domain.UserResponse, error) {
-	isUserExists := s.Storage.IsUserExists(user.Email)
-	if !isUserExists {
-		return domain.UserResponse{}, domain.ErrNotExists
-	}
-
-	userData, err := s.Storage.GetUserByEmail(user.Email)
+// CreateToken starts a session of the user, the token is signed with the
+// configured secret.
+func (s UsersService) CreateToken(email string) (string, error) {
+	user, err := s.Storage.GetUserByEmail(email)
 	if err != nil {
-		return domain.UserResponse{}, domain.ErrNotExists
+		return "", domain.ErrTokenNotCreated
 	}
 
-	if user.Password != userData.Password {
-		return domain.UserResponse{}, domain.ErrBadCredentials
+	now := time.Now()
+	t, err := s.Tokens.Sign(user.ID, auth.PurposeSession, "", SessionTTL)
+	if err != nil {
+		return "", domain.ErrTokenNotCreated
 	}
 
-	return domain.UserResponse{
-		Email:              userData.Email,
-		DaysToNotification: userData.DaysToNotification,
-		Name:               userData.Name,
-	}, nil
-}
-
-func (s UsersService) CreateToken(email string) (string, error) {
-	t, err := s.Storage.CreateToken(email)
+	err = s.Storage.AddSession(user.ID, domain.Session{Token: t, ExpiresAt: now.Add(SessionTTL)}, MaxSessions, now)
 	if err != nil {
 		return "", domain.ErrTokenNotCreated
 	}
@@ -106,37 +238,196 @@ func (s UsersService) GetUserInfo(token string) (domain.UserResponse, error) {
 	return user, nil
 }
 
-func (s UsersService) Subscribe(token string, userId int) error {
-	err := s.Storage.Subscribe(token, userId)
+func (s UsersService) GetUpcomingBirthdays(token string, days int) ([]domain.UpcomingBirthdayResponse, error) {
+	if days < 0 || days > 365 {
+		return []domain.UpcomingBirthdayResponse{}, domain.ErrInvalidQuery
+	}
+
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return []domain.UpcomingBirthdayResponse{}, domain.ErrNotExists
+	}
+
+	users, err := s.Storage.GetUpcomingBirthdays(currentUser.ID, days)
+	if err != nil {
+		return []domain.UpcomingBirthdayResponse{}, err
+	}
+
+	return users, nil
+}
+
+func (s UsersService) GetProfile(token string, id int) (domain.ProfileResponse, error) {
+	profile, err := s.Storage.GetProfile(token, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists):
+			return domain.ProfileResponse{}, domain.ErrNotExists
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.ProfileResponse{}, domain.ErrNotFound
+		}
+		return domain.ProfileResponse{}, err
+	}
+
+	return profile, nil
+}
+
+// Subscribe follows the user, or sends a follow request when the user
+// approves their followers.
+func (s UsersService) Subscribe(token string, userId int) (domain.SubscribeResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.SubscribeResponse{}, domain.ErrNotExists
+	}
+
+	return s.subscribe(currentUser, userId)
+}
+
+func (s UsersService) subscribe(currentUser domain.User, userId int) (domain.SubscribeResponse, error) {
+	if currentUser.ID == userId {
+		return domain.SubscribeResponse{}, domain.ErrSelfSubscribe
+	}
+
+	target, err := s.Storage.GetUserByID(userId)
+	if err != nil {
+		return domain.SubscribeResponse{}, domain.ErrNotFound
+	}
+
+	if !target.AcceptsFollower(currentUser) {
+		if target.Privacy.Policy() == domain.FollowTeam {
+			return domain.SubscribeResponse{}, domain.ErrTeamOnly
+		}
+
+		err = s.FollowRequests.requestFollow(currentUser, target)
+		if err != nil {
+			return domain.SubscribeResponse{}, err
+		}
+		return domain.SubscribeResponse{Success: true, Pending: true}, nil
+	}
+
+	err = s.Storage.Subscribe(currentUser.ID, userId, s.Config.MaxFollows)
 	if err != nil {
 		switch {
 		case errors.Is(err, domain.ErrExists):
-			return domain.ErrExists
+			return domain.SubscribeResponse{}, domain.ErrExists
 		case errors.Is(err, domain.ErrNotExists):
-			return domain.ErrNotExists
+			return domain.SubscribeResponse{}, domain.ErrNotExists
+		case errors.Is(err, domain.ErrNotFound):
+			return domain.SubscribeResponse{}, domain.ErrNotFound
+		case errors.Is(err, domain.ErrBlocked):
+			return domain.SubscribeResponse{}, domain.ErrBlocked
+		case errors.Is(err, domain.ErrFollowLimit):
+			return domain.SubscribeResponse{}, domain.ErrFollowLimit
 		}
+		return domain.SubscribeResponse{}, err
 	}
-	return nil
+
+	s.Notifications.notify(target.ID, domain.NotificationFollower, "", map[string]any{
+		"user": userPayload(currentUser),
+	})
+	subscriptionChanged(s.Events, currentUser.ID, domain.SubscriptionEvent{Action: domain.SubscriptionAdded, UserID: target.ID})
+
+	return domain.SubscribeResponse{Success: true}, nil
 }
 
 func (s UsersService) Unsubscribe(token string, userId int) error {
-	err := s.Storage.Unsubscribe(token, userId)
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.ErrNotExists
+	}
+
+	err = s.Storage.Unsubscribe(token, userId)
 	if err != nil {
 		switch {
 		case errors.Is(err, domain.ErrNotExists):
 			return domain.ErrNotExists
 		}
+		return err
 	}
+
+	subscriptionChanged(s.Events, currentUser.ID, domain.SubscriptionEvent{Action: domain.SubscriptionRemoved, UserID: userId})
+
 	return nil
 }
 
-func (s UsersService) Settings(token string, daysToBirthday int, email string) error {
-	err := s.Storage.Settings(token, daysToBirthday, email)
+func (s UsersService) Settings(token string, settings domain.SettingsRequest) (domain.UserResponse, error) {
+	settings, err := validateSettings(settings)
+	if err != nil {
+		return domain.UserResponse{}, err
+	}
+
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.UserResponse{}, domain.ErrNotExists
+	}
+
+	// the email is changed only after the new address is confirmed
+	email := settings.Email
+	settings.Email = nil
+
+	if email != nil && !strings.EqualFold(*email, currentUser.Email) && s.Storage.IsUserExists(*email) {
+		return domain.UserResponse{}, domain.ErrExists
+	}
+
+	// the email change goes first: when the letter can't be sent nothing is saved
+	if email != nil && *email != currentUser.Email {
+		err = s.requestEmailChange(currentUser, *email)
+		if err != nil {
+			return domain.UserResponse{}, err
+		}
+	}
+
+	user, err := s.Storage.Settings(token, settings)
 	if err != nil {
 		switch {
 		case errors.Is(err, domain.ErrNotExists):
-			return domain.ErrNotExists
+			return domain.UserResponse{}, domain.ErrNotExists
+		case errors.Is(err, domain.ErrExists):
+			return domain.UserResponse{}, domain.ErrExists
 		}
+		return domain.UserResponse{}, err
 	}
-	return nil
+
+	s.usersChanged()
+
+	return user, nil
+}
+
+// validateSettings checks the fields that were sent and returns them normalized.
+func validateSettings(settings domain.SettingsRequest) (domain.SettingsRequest, error) {
+	if settings.DaysToNotification != nil {
+		days := *settings.DaysToNotification
+		if days < 0 || days > MaxDaysToNotification {
+			return domain.SettingsRequest{}, domain.ErrInvalidSettings
+		}
+	}
+
+	if settings.Email != nil {
+		email := strings.TrimSpace(*settings.Email)
+		address, err := mail.ParseAddress(email)
+		if err != nil || address.Address != email {
+			return domain.SettingsRequest{}, domain.ErrInvalidSettings
+		}
+		settings.Email = &email
+	}
+
+	if settings.Name != nil {
+		name := strings.TrimSpace(*settings.Name)
+		if name == "" {
+			return domain.SettingsRequest{}, domain.ErrInvalidSettings
+		}
+		settings.Name = &name
+	}
+
+	if settings.DateOfBirth != nil {
+		birth, err := time.Parse(domain.DateLayout, *settings.DateOfBirth)
+		if err != nil || birth.After(time.Now()) {
+			return domain.SettingsRequest{}, domain.ErrInvalidSettings
+		}
+	}
+
+	if settings.Privacy != nil && settings.Privacy.FollowPolicy != nil && !settings.Privacy.FollowPolicy.IsValid() {
+		return domain.SettingsRequest{}, domain.ErrInvalidSettings
+	}
+
+	return settings, nil
 }
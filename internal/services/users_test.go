@@ -0,0 +1,1368 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/krevetkou/test-rutube/internal/auth"
+	"github.com/krevetkou/test-rutube/internal/config"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"github.com/krevetkou/test-rutube/internal/events"
+	"github.com/krevetkou/test-rutube/internal/storage"
+)
+
+type letter struct {
+	to, subject, body string
+}
+
+// mailbox keeps the sent letters, while down it fails to send them.
+type mailbox struct {
+	letters []letter
+	down    bool
+}
+
+func (m *mailbox) Send(to, subject, body string) error {
+	if m.down {
+		return errors.New("mail server is down")
+	}
+	m.letters = append(m.letters, letter{to: to, subject: subject, body: body})
+
+	return nil
+}
+
+// last returns the last letter sent to the address.
+func (m *mailbox) last(t *testing.T, to string) letter {
+	t.Helper()
+
+	for i := len(m.letters) - 1; i >= 0; i-- {
+		if m.letters[i].to == to {
+			return m.letters[i]
+		}
+	}
+	t.Fatalf("no letters to %s", to)
+
+	return letter{}
+}
+
+// linkToken returns the token of the link in the letter.
+func (l letter) linkToken(t *testing.T) string {
+	t.Helper()
+
+	i := strings.Index(l.body, "http")
+	if i < 0 {
+		t.Fatalf("no link in %q", l.body)
+	}
+	link, err := url.Parse(strings.Fields(l.body[i:])[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return link.Query().Get("token")
+}
+
+// newTestService returns a service on an empty storage. Logins aren't
+// throttled unless the config sets the limits.
+func newTestService(t *testing.T, cfg config.Config) (UsersService, *mailbox) {
+	t.Helper()
+
+	if cfg.LoginPerIP == 0 {
+		cfg.LoginPerIP = 1000
+	}
+	if cfg.LoginPerAccount == 0 {
+		cfg.LoginPerAccount = 1000
+	}
+	mail := &mailbox{}
+	s := storage.NewStorage()
+	hub := events.NewHub(10)
+	notifications := NewNotificationsService(s, mail, hub, nil)
+	followRequests := NewFollowRequestsService(s, mail, hub, notifications, cfg)
+
+	return NewUserService(s, mail, auth.NewSigner("secret"), PasswordPolicy{MinLength: 8}, hub, notifications, followRequests, cfg), mail
+}
+
+// The feature services share the storage of the users service.
+
+func greetingsService(svc UsersService) GreetingsService {
+	return NewGreetingsService(svc.Storage.(*storage.Storage), svc.Mailer, svc.Events, svc.Notifications, svc.Config)
+}
+
+func fundsService(svc UsersService) FundsService {
+	return NewFundsService(svc.Storage.(*storage.Storage), svc.Mailer)
+}
+
+func wishlistService(svc UsersService) WishlistService {
+	return NewWishlistService(svc.Storage.(*storage.Storage))
+}
+
+// addUser registers a verified user and returns them with their session token.
+func addUser(t *testing.T, svc UsersService, email string) (domain.User, string) {
+	t.Helper()
+
+	user, err := svc.Storage.InsertUser(domain.RegisterRequest{Email: email, Name: email, DateOfBirth: "1990-01-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Storage.MarkVerified(user.ID, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := svc.CreateToken(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user, token
+}
+
+func TestGetUsersByTokenValidatesQuery(t *testing.T) {
+	svc, _ := newTestService(t, config.Config{})
+	_, token := addUser(t, svc, "anna@corp.ru")
+
+	tests := []struct {
+		name    string
+		query   domain.ListQuery
+		wantErr error
+	}{
+		{name: "defaults"},
+		{name: "by name", query: domain.ListQuery{Sort: domain.SortByName, Limit: 10}},
+		{name: "by birthday", query: domain.ListQuery{Sort: domain.SortByBirthday}},
+		{name: "unknown sort", query: domain.ListQuery{Sort: "age"}, wantErr: domain.ErrInvalidQuery},
+		{name: "negative limit", query: domain.ListQuery{Limit: -1}, wantErr: domain.ErrInvalidQuery},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.GetUsersByToken(token, tt.query)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetUsersByToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxFollows int
+		// follows are the users anna follows before the subscription.
+		follows []int
+		target  int
+		wantErr error
+	}{
+		{name: "subscribes", target: 2},
+		{name: "yourself", target: 1, wantErr: domain.ErrSelfSubscribe},
+		{name: "unknown user", target: 100, wantErr: domain.ErrNotFound},
+		{name: "twice", follows: []int{2}, target: 2, wantErr: domain.ErrExists},
+		{name: "follow limit", maxFollows: 1, follows: []int{3}, target: 2, wantErr: domain.ErrFollowLimit},
+		{name: "no follow limit", follows: []int{3}, target: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestService(t, config.Config{MaxFollows: tt.maxFollows})
+			anna, token := addUser(t, svc, "anna@corp.ru")
+			addUser(t, svc, "boris@corp.ru")
+			addUser(t, svc, "vera@corp.ru")
+			for _, id := range tt.follows {
+				if err := svc.Storage.Subscribe(anna.ID, id, 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err := svc.Subscribe(token, tt.target)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Subscribe() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSettings(t *testing.T) {
+	ptr := func(v string) *string { return &v }
+	days := func(v int) *int { return &v }
+	hide := true
+	policy := func(v domain.FollowPolicy) *domain.FollowPolicy { return &v }
+	everyone := domain.Privacy{FollowPolicy: domain.FollowEveryone}
+
+	tests := []struct {
+		name     string
+		settings domain.SettingsRequest
+		want     domain.UserResponse
+		wantErr  error
+	}{
+		{
+			name:     "nothing changes",
+			settings: domain.SettingsRequest{},
+			want:     domain.UserResponse{Email: "anna@corp.ru", Name: "anna@corp.ru", DateOfBirth: "1990-01-01", DaysToNotification: 2, Verified: true, Role: domain.RoleUser, Privacy: everyone},
+		},
+		{
+			name:     "only the sent fields change",
+			settings: domain.SettingsRequest{DaysToNotification: days(7), Name: ptr("  Anna  ")},
+			want:     domain.UserResponse{Email: "anna@corp.ru", Name: "Anna", DateOfBirth: "1990-01-01", DaysToNotification: 7, Verified: true, Role: domain.RoleUser, Privacy: everyone},
+		},
+		{
+			name:     "privacy",
+			settings: domain.SettingsRequest{Privacy: &domain.PrivacyRequest{HideEmail: &hide, FollowPolicy: policy(domain.FollowTeam)}},
+			want: domain.UserResponse{Email: "anna@corp.ru", Name: "anna@corp.ru", DateOfBirth: "1990-01-01", DaysToNotification: 2, Verified: true, Role: domain.RoleUser,
+				Privacy: domain.Privacy{HideEmail: true, FollowPolicy: domain.FollowTeam}},
+		},
+		{name: "unknown follow policy", settings: domain.SettingsRequest{Privacy: &domain.PrivacyRequest{FollowPolicy: policy("friends")}}, wantErr: domain.ErrInvalidSettings},
+		{name: "negative days", settings: domain.SettingsRequest{DaysToNotification: days(-1)}, wantErr: domain.ErrInvalidSettings},
+		{name: "too many days", settings: domain.SettingsRequest{DaysToNotification: days(366)}, wantErr: domain.ErrInvalidSettings},
+		{name: "empty name", settings: domain.SettingsRequest{Name: ptr(" ")}, wantErr: domain.ErrInvalidSettings},
+		{name: "invalid email", settings: domain.SettingsRequest{Email: ptr("Anna <anna@corp.ru>")}, wantErr: domain.ErrInvalidSettings},
+		{name: "invalid date", settings: domain.SettingsRequest{DateOfBirth: ptr("1990-02-30")}, wantErr: domain.ErrInvalidSettings},
+		{name: "born in the future", settings: domain.SettingsRequest{DateOfBirth: ptr("2990-01-01")}, wantErr: domain.ErrInvalidSettings},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestService(t, config.Config{})
+			_, token := addUser(t, svc, "anna@corp.ru")
+
+			got, err := svc.Settings(token, tt.settings)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Settings() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Settings() = %+v, want %+v", got, tt.want)
+			}
+
+			// the changes are saved
+			info, err := svc.GetUserInfo(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr == nil && info != tt.want {
+				t.Errorf("GetUserInfo() = %+v, want %+v", info, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangeEmail(t *testing.T) {
+	svc, mail := newTestService(t, config.Config{BaseURL: "http://birthdays"})
+	_, token := addUser(t, svc, "anna@corp.ru")
+	addUser(t, svc, "boris@corp.ru")
+	email := func(v string) domain.SettingsRequest { return domain.SettingsRequest{Email: &v} }
+
+	_, err := svc.Settings(token, email("boris@corp.ru"))
+	if !errors.Is(err, domain.ErrExists) {
+		t.Errorf("Settings() with a taken email error = %v, want ErrExists", err)
+	}
+
+	mail.down = true
+	_, err = svc.Settings(token, email("anna@mail.ru"))
+	if !errors.Is(err, domain.ErrMailNotSent) {
+		t.Errorf("Settings() without mail error = %v, want ErrMailNotSent", err)
+	}
+	if info, _ := svc.GetUserInfo(token); info.PendingEmail != "" {
+		t.Errorf("the change is pending without a letter: %+v", info)
+	}
+	mail.down = false
+
+	user, err := svc.Settings(token, email("anna@mail.ru"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "anna@corp.ru" || user.PendingEmail != "anna@mail.ru" {
+		t.Errorf("Settings() = %+v, want the new email pending", user)
+	}
+	confirm := mail.last(t, "anna@mail.ru").linkToken(t)
+	revert := mail.last(t, "anna@corp.ru").linkToken(t)
+
+	_, err = svc.ConfirmEmail(revert)
+	if !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("ConfirmEmail() with the revert token error = %v, want ErrInvalidToken", err)
+	}
+
+	user, err = svc.ConfirmEmail(confirm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "anna@mail.ru" || user.PendingEmail != "" {
+		t.Errorf("ConfirmEmail() = %+v, want the new email", user)
+	}
+	_, err = svc.ConfirmEmail(confirm)
+	if !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("ConfirmEmail() twice error = %v, want ErrInvalidToken", err)
+	}
+
+	user, err = svc.RevertEmail(revert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "anna@corp.ru" {
+		t.Errorf("RevertEmail() = %+v, want the old email", user)
+	}
+
+	// the session survives the email changes
+	if _, err = svc.GetUserInfo(token); err != nil {
+		t.Errorf("GetUserInfo() error = %v", err)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	svc, mail := newTestService(t, config.Config{BaseURL: "http://birthdays", VerificationResendInterval: time.Minute})
+	user, err := svc.Create(domain.RegisterRequest{Email: "anna@corp.ru", Name: "Anna", Password: "secret-password", DateOfBirth: "1990-01-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify := mail.last(t, "anna@corp.ru").linkToken(t)
+	token, err := svc.CreateToken("anna@corp.ru")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.ResendVerification(token)
+	if !errors.Is(err, domain.ErrTooManyRequests) {
+		t.Errorf("ResendVerification() right away error = %v, want ErrTooManyRequests", err)
+	}
+
+	otherEmail, err := svc.Tokens.Sign(user.ID, auth.PurposeVerify, "anna@mail.ru", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = svc.VerifyEmail(otherEmail); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("VerifyEmail() for another email error = %v, want ErrInvalidToken", err)
+	}
+
+	if err = svc.VerifyEmail(verify); err != nil {
+		t.Fatal(err)
+	}
+	if err = svc.VerifyEmail(verify); !errors.Is(err, domain.ErrVerified) {
+		t.Errorf("VerifyEmail() twice error = %v, want ErrVerified", err)
+	}
+	if err = svc.ResendVerification(token); !errors.Is(err, domain.ErrVerified) {
+		t.Errorf("ResendVerification() after verification error = %v, want ErrVerified", err)
+	}
+
+	info, err := svc.GetUserInfo(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Verified {
+		t.Errorf("GetUserInfo() = %+v, want verified", info)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	svc, mail := newTestService(t, config.Config{
+		FrontendURL:         "http://birthdays",
+		PasswordResetTTL:    time.Hour,
+		PasswordForgotPerIP: 2,
+	})
+	_, token := addUser(t, svc, "anna@corp.ru")
+
+	// an unknown email looks the same as a known one
+	if err := svc.ForgotPassword("nobody@corp.ru", "10.0.0.1"); err != nil {
+		t.Errorf("ForgotPassword() for an unknown email error = %v", err)
+	}
+	if err := svc.ForgotPassword(" anna@corp.ru ", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.ForgotPassword("anna@corp.ru", "10.0.0.1"); !errors.Is(err, domain.ErrTooManyRequests) {
+		t.Errorf("ForgotPassword() over the IP limit error = %v, want ErrTooManyRequests", err)
+	}
+	if len(mail.letters) != 1 {
+		t.Fatalf("%d letters sent, want 1", len(mail.letters))
+	}
+	reset := mail.last(t, "anna@corp.ru").linkToken(t)
+
+	err := svc.ResetPassword(domain.ResetPasswordRequest{Token: reset, Password: "short"})
+	if !errors.Is(err, domain.ErrWeakPassword) {
+		t.Errorf("ResetPassword() with a short password error = %v, want ErrWeakPassword", err)
+	}
+	if err = svc.ResetPassword(domain.ResetPasswordRequest{Token: reset, Password: "new-password"}); err != nil {
+		t.Fatal(err)
+	}
+	err = svc.ResetPassword(domain.ResetPasswordRequest{Token: reset, Password: "other-password"})
+	if !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("ResetPassword() twice error = %v, want ErrInvalidToken", err)
+	}
+
+	if _, err = svc.GetUserInfo(token); err == nil {
+		t.Error("the session survived the reset")
+	}
+	if _, err = svc.Login(domain.LoginRequest{Email: "anna@corp.ru", Password: "new-password"}, "10.0.0.1"); err != nil {
+		t.Errorf("Login() with the new password error = %v", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	tests := []struct {
+		name    string
+		request domain.ChangePasswordRequest
+		wantErr error
+		// wantOther is whether the other session of the user still works.
+		wantOther bool
+	}{
+		{
+			name:      "keeps the other sessions",
+			request:   domain.ChangePasswordRequest{CurrentPassword: "old-password", NewPassword: "new-password"},
+			wantOther: true,
+		},
+		{
+			name:    "logs out the other sessions",
+			request: domain.ChangePasswordRequest{CurrentPassword: "old-password", NewPassword: "new-password", LogoutOtherSessions: true},
+		},
+		{
+			name:      "wrong current password",
+			request:   domain.ChangePasswordRequest{CurrentPassword: "wrong-password", NewPassword: "new-password"},
+			wantErr:   domain.ErrBadCredentials,
+			wantOther: true,
+		},
+		{
+			name:      "weak new password",
+			request:   domain.ChangePasswordRequest{CurrentPassword: "old-password", NewPassword: "short"},
+			wantErr:   domain.ErrWeakPassword,
+			wantOther: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestService(t, config.Config{})
+			_, err := svc.Create(domain.RegisterRequest{Email: "anna@corp.ru", Name: "Anna", Password: "old-password", DateOfBirth: "1990-01-01"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, err := svc.CreateToken("anna@corp.ru")
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, err := svc.CreateToken("anna@corp.ru")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = svc.ChangePassword(token, tt.request)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ChangePassword() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if _, err = svc.GetUserInfo(token); err != nil {
+				t.Errorf("the current session ended: %v", err)
+			}
+			if _, err = svc.GetUserInfo(other); (err == nil) != tt.wantOther {
+				t.Errorf("the other session works: %v, want %v", err == nil, tt.wantOther)
+			}
+
+			password := tt.request.NewPassword
+			if tt.wantErr != nil {
+				password = "old-password"
+			}
+			if _, err = svc.Login(domain.LoginRequest{Email: "anna@corp.ru", Password: password}, "10.0.0.1"); err != nil {
+				t.Errorf("Login() with %q error = %v", password, err)
+			}
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	type attempt struct {
+		email, password, ip string
+		wantErr             error
+	}
+
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		attempts []attempt
+	}{
+		{
+			name: "logs in",
+			attempts: []attempt{
+				{email: "anna@corp.ru", password: "anna-password"},
+			},
+		},
+		{
+			name: "email in another case",
+			attempts: []attempt{
+				{email: " Anna@Corp.RU ", password: "anna-password"},
+			},
+		},
+		{
+			name: "unknown email looks like a wrong password",
+			attempts: []attempt{
+				{email: "nobody@corp.ru", password: "anna-password", wantErr: domain.ErrBadCredentials},
+				{email: "anna@corp.ru", password: "wrong-password", wantErr: domain.ErrBadCredentials},
+			},
+		},
+		{
+			name: "locks after the failures",
+			cfg:  config.Config{LoginMaxFailures: 2, LoginLockout: time.Minute, LoginMaxLockout: time.Hour},
+			attempts: []attempt{
+				{email: "anna@corp.ru", password: "wrong-password", wantErr: domain.ErrBadCredentials},
+				{email: "anna@corp.ru", password: "wrong-password", wantErr: domain.ErrBadCredentials},
+				{email: "ANNA@corp.ru", password: "anna-password", wantErr: domain.ErrAccountLocked},
+				{email: "boris@corp.ru", password: "boris-password"},
+			},
+		},
+		{
+			name: "unknown emails are locked too",
+			cfg:  config.Config{LoginMaxFailures: 1, LoginLockout: time.Minute, LoginMaxLockout: time.Hour},
+			attempts: []attempt{
+				{email: "nobody@corp.ru", password: "anna-password", wantErr: domain.ErrBadCredentials},
+				{email: "nobody@corp.ru", password: "anna-password", wantErr: domain.ErrAccountLocked},
+			},
+		},
+		{
+			name: "success resets the failures",
+			cfg:  config.Config{LoginMaxFailures: 2, LoginLockout: time.Minute, LoginMaxLockout: time.Hour},
+			attempts: []attempt{
+				{email: "anna@corp.ru", password: "wrong-password", wantErr: domain.ErrBadCredentials},
+				{email: "anna@corp.ru", password: "anna-password"},
+				{email: "anna@corp.ru", password: "wrong-password", wantErr: domain.ErrBadCredentials},
+				{email: "anna@corp.ru", password: "anna-password"},
+			},
+		},
+		{
+			name: "per IP limit",
+			cfg:  config.Config{LoginPerIP: 1},
+			attempts: []attempt{
+				{email: "anna@corp.ru", password: "anna-password", ip: "10.0.0.1"},
+				{email: "boris@corp.ru", password: "boris-password", ip: "10.0.0.1", wantErr: domain.ErrTooManyRequests},
+				{email: "boris@corp.ru", password: "boris-password", ip: "10.0.0.2"},
+			},
+		},
+		{
+			name: "per account limit",
+			cfg:  config.Config{LoginPerAccount: 1},
+			attempts: []attempt{
+				{email: "anna@corp.ru", password: "anna-password", ip: "10.0.0.1"},
+				{email: "anna@corp.ru", password: "anna-password", ip: "10.0.0.2", wantErr: domain.ErrTooManyRequests},
+				{email: "boris@corp.ru", password: "boris-password", ip: "10.0.0.2"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestService(t, tt.cfg)
+			for _, email := range []string{"anna@corp.ru", "boris@corp.ru"} {
+				name, _, _ := strings.Cut(email, "@")
+				_, err := svc.Create(domain.RegisterRequest{Email: email, Name: name, Password: name + "-password", DateOfBirth: "1990-01-01"})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for i, a := range tt.attempts {
+				user, err := svc.Login(domain.LoginRequest{Email: a.email, Password: a.password}, a.ip)
+				if !errors.Is(err, a.wantErr) {
+					t.Fatalf("attempt %d: Login() error = %v, want %v", i, err, a.wantErr)
+				}
+				if err == nil && !strings.EqualFold(user.Email, strings.TrimSpace(a.email)) {
+					t.Errorf("attempt %d: Login() = %+v, want %s", i, user, a.email)
+				}
+			}
+		})
+	}
+}
+
+func TestUnlockAccount(t *testing.T) {
+	svc, _ := newTestService(t, config.Config{LoginMaxFailures: 1, LoginLockout: time.Minute, LoginMaxLockout: time.Hour})
+	_, err := svc.Create(domain.RegisterRequest{Email: "anna@corp.ru", Name: "Anna", Password: "anna-password", DateOfBirth: "1990-01-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Login(domain.LoginRequest{Email: "anna@corp.ru", Password: "wrong-password"}, "10.0.0.1")
+	if !errors.Is(err, domain.ErrBadCredentials) {
+		t.Fatalf("Login() error = %v, want ErrBadCredentials", err)
+	}
+	if err = svc.UnlockAccount("nobody@corp.ru"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("UnlockAccount() for an unknown email error = %v, want ErrNotFound", err)
+	}
+	if err = svc.UnlockAccount("anna@corp.ru"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = svc.Login(domain.LoginRequest{Email: "anna@corp.ru", Password: "anna-password"}, "10.0.0.1"); err != nil {
+		t.Errorf("Login() after the unlock error = %v", err)
+	}
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	svc, _ := newTestService(t, config.Config{TwoFactorChallengeTTL: time.Minute})
+	anna, err := svc.Create(domain.RegisterRequest{Email: "anna@corp.ru", Name: "Anna", Password: "anna-password", DateOfBirth: "1990-01-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = svc.Storage.SetPendingTOTP(anna.ID, secret); err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Storage.EnableTOTP(anna.ID, secret, 0, []string{auth.HashToken("aaaaa-bbbbb"), auth.HashToken("ccccc-ddddd")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login, err := svc.Login(domain.LoginRequest{Email: "anna@corp.ru", Password: "anna-password"}, "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !login.TwoFactorRequired || login.Email != "" {
+		t.Fatalf("Login() = %+v, want a challenge only", login)
+	}
+	session, err := svc.Tokens.Sign(anna.ID, auth.PurposeSession, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the steps spend the recovery codes, so they run in order
+	steps := []struct {
+		name    string
+		request domain.TwoFactorLoginRequest
+		wantErr error
+	}{
+		{name: "wrong code", request: domain.TwoFactorLoginRequest{ChallengeToken: login.ChallengeToken, Code: "123456"}, wantErr: domain.ErrInvalidCode},
+		{name: "session token", request: domain.TwoFactorLoginRequest{ChallengeToken: session, Code: "aaaaa-bbbbb"}, wantErr: domain.ErrInvalidToken},
+		{name: "recovery code", request: domain.TwoFactorLoginRequest{ChallengeToken: login.ChallengeToken, Code: " AAAAA-BBBBB "}},
+		{name: "used recovery code", request: domain.TwoFactorLoginRequest{ChallengeToken: login.ChallengeToken, Code: "aaaaa-bbbbb"}, wantErr: domain.ErrInvalidCode},
+	}
+	for _, step := range steps {
+		user, err := svc.LoginTwoFactor(step.request, "10.0.0.1")
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: LoginTwoFactor() error = %v, want %v", step.name, err, step.wantErr)
+		}
+		if err == nil && (user.Email != "anna@corp.ru" || !user.TwoFactorEnabled) {
+			t.Errorf("%s: LoginTwoFactor() = %+v, want anna with 2FA", step.name, user)
+		}
+	}
+}
+
+func TestLoginExternal(t *testing.T) {
+	identity := func(subject, email string, verified bool) domain.ExternalIdentity {
+		return domain.ExternalIdentity{Issuer: "https://idp", Subject: subject, Email: email, EmailVerified: verified, DateOfBirth: "1990-05-01"}
+	}
+
+	tests := []struct {
+		name     string
+		identity domain.ExternalIdentity
+		wantErr  error
+		// wantID is the account the identity logs in to.
+		wantID int
+	}{
+		{name: "links a verified email", identity: identity("1", "anna@corp.ru", true), wantID: 1},
+		{name: "doesn't link an unverified email", identity: identity("1", "anna@corp.ru", false), wantErr: domain.ErrExists},
+		{name: "creates an account", identity: identity("2", "boris@corp.ru", true), wantID: 2},
+		{name: "no subject", identity: identity("", "anna@corp.ru", true), wantErr: domain.ErrInvalidToken},
+		{name: "no email", identity: identity("2", "", true), wantErr: domain.ErrFieldsRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestService(t, config.Config{})
+			addUser(t, svc, "anna@corp.ru")
+
+			user, err := svc.LoginExternal(tt.identity)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("LoginExternal() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			stored, err := svc.Storage.GetUserByEmail(user.Email)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.ID != tt.wantID || stored.OIDCSubject != tt.identity.Key() {
+				t.Errorf("logged in to %+v, want user %d linked to %s", stored, tt.wantID, tt.identity.Key())
+			}
+
+			// the next login finds the account by the subject, even with another email
+			tt.identity.Email = "other@corp.ru"
+			again, err := svc.LoginExternal(tt.identity)
+			if err != nil || again.Email != user.Email {
+				t.Errorf("second LoginExternal() = %+v, %v, want %s", again, err, user.Email)
+			}
+		})
+	}
+}
+
+func TestImportUsers(t *testing.T) {
+	records := []domain.ImportRecord{
+		{Email: "anna@corp.ru", Name: "Anna", DateOfBirth: "1990-01-01", Department: "Sales"},
+		{Email: "boris@corp.ru", Name: "Boris", DateOfBirth: "1991-02-03", Department: "IT"},
+		{Email: "vera@corp.ru", Name: "Vera", DateOfBirth: "1992-03-04"},
+		{Email: "BORIS@corp.ru", Name: "Boris", DateOfBirth: "1991-02-03"},
+		{Email: "gleb@corp.ru", Name: "Gleb", DateOfBirth: "03.04.1992"},
+	}
+
+	tests := []struct {
+		name   string
+		dryRun bool
+		want   domain.ImportResult
+	}{
+		{name: "imports", want: domain.ImportResult{Created: 2, Updated: 1, Skipped: 2}},
+		{name: "dry run", dryRun: true, want: domain.ImportResult{DryRun: true, Created: 2, Updated: 1, Skipped: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestService(t, config.Config{})
+			addUser(t, svc, "anna@corp.ru")
+
+			got, err := svc.ImportUsers(records, tt.dryRun)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows := make([]int, 0)
+			for _, e := range got.Errors {
+				rows = append(rows, e.Row)
+			}
+			got.Errors = nil
+			if !reflect.DeepEqual(got, tt.want) || !slices.Equal(rows, []int{4, 5}) {
+				t.Errorf("ImportUsers() = %+v with errors in rows %v, want %+v with rows [4 5]", got, rows, tt.want)
+			}
+
+			anna, err := svc.Storage.GetUserByEmail("anna@corp.ru")
+			if err != nil {
+				t.Fatal(err)
+			}
+			boris, err := svc.Storage.GetUserByEmail("boris@corp.ru")
+			if tt.dryRun {
+				if anna.Department != "" || !errors.Is(err, domain.ErrNotFound) {
+					t.Errorf("the dry run saved the changes: %+v, boris error %v", anna, err)
+				}
+				return
+			}
+			if anna.Department != "Sales" || err != nil || !boris.Verified || boris.Department != "IT" {
+				t.Errorf("the import isn't saved: %+v, %+v, %v", anna, boris, err)
+			}
+
+			// the same file again changes nothing
+			again, err := svc.ImportUsers(records, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.Created != 0 || again.Updated != 0 || again.Skipped != len(records) {
+				t.Errorf("second ImportUsers() = %+v, want everything skipped", again)
+			}
+		})
+	}
+}
+
+func TestSyncDirectory(t *testing.T) {
+	record := func(email, name string) domain.DirectoryRecord {
+		return domain.DirectoryRecord{
+			DN:           "uid=" + name + ",ou=people",
+			ImportRecord: domain.ImportRecord{Email: email, Name: name, DateOfBirth: "1990-01-01"},
+		}
+	}
+	svc, _ := newTestService(t, config.Config{})
+	_, local := addUser(t, svc, "local@corp.ru")
+
+	report, err := svc.SyncDirectory([]domain.DirectoryRecord{record("anna@corp.ru", "Anna"), record("boris@corp.ru", "Boris")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Created) != 2 {
+		t.Errorf("first sync created %v, want anna and boris", report.Created)
+	}
+	// the local user follows boris
+	if _, err = svc.Subscribe(local, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// boris left, anna changed the name, the broken entry is reported
+	broken := record("", "Nobody")
+	report, err = svc.SyncDirectory([]domain.DirectoryRecord{record("anna@corp.ru", "Anna K"), broken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(report.Updated, []string{"anna@corp.ru"}) || !slices.Equal(report.Deactivated, []string{"boris@corp.ru"}) ||
+		report.RemovedSubscriptions != 1 || len(report.Errors) != 1 {
+		t.Errorf("second sync = %+v, want anna updated, boris deactivated with one subscription", report)
+	}
+
+	boris, err := svc.Storage.GetUserByEmail("boris@corp.ru")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !boris.Disabled {
+		t.Errorf("boris isn't disabled: %+v", boris)
+	}
+	if _, err = svc.Storage.GetUserByEmail("local@corp.ru"); err != nil {
+		t.Errorf("the local account is gone: %v", err)
+	}
+
+	// boris came back
+	report, err = svc.SyncDirectory([]domain.DirectoryRecord{record("anna@corp.ru", "Anna K"), record("boris@corp.ru", "Boris")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(report.Reactivated, []string{"boris@corp.ru"}) || report.Unchanged != 1 {
+		t.Errorf("third sync = %+v, want boris reactivated and anna unchanged", report)
+	}
+
+	if _, err = svc.SyncDirectory(nil); !errors.Is(err, domain.ErrEmptyDirectory) {
+		t.Errorf("SyncDirectory() without records error = %v, want ErrEmptyDirectory", err)
+	}
+}
+
+// bornIn returns a date of birth with the birthday the days from today,
+// negative days are in the past. The year is a leap one for February 29.
+func bornIn(days int) string {
+	return "1992-" + time.Now().AddDate(0, 0, days).Format("01-02")
+}
+
+func TestPostGreetingWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		birthday string
+		wantErr  error
+	}{
+		{name: "on the birthday", birthday: bornIn(0)},
+		{name: "before the birthday", birthday: bornIn(3)},
+		{name: "after the birthday", birthday: bornIn(-3)},
+		{name: "too early", birthday: bornIn(4), wantErr: domain.ErrGreetingClosed},
+		{name: "too late", birthday: bornIn(-4), wantErr: domain.ErrGreetingClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, mail := newTestService(t, config.Config{GreetingWindowDays: 3})
+			_, token := addUser(t, svc, "anna@corp.ru")
+			boris, _ := addUser(t, svc, "boris@corp.ru")
+			if _, err := svc.UpdateUser(boris.ID, domain.AdminSettingsRequest{DateOfBirth: &tt.birthday}); err != nil {
+				t.Fatal(err)
+			}
+
+			greeting, err := greetingsService(svc).PostGreeting(token, boris.ID, domain.GreetingRequest{Text: " Happy birthday! ", Reactions: []string{"🎉", "🎉"}})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PostGreeting() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if greeting.Text != "Happy birthday!" || !slices.Equal(greeting.Reactions, []string{"🎉"}) {
+				t.Errorf("PostGreeting() = %+v, want the trimmed text and one reaction", greeting)
+			}
+			if letter := mail.last(t, "boris@corp.ru"); !strings.Contains(letter.body, "Happy birthday!") {
+				t.Errorf("the letter %q doesn't have the greeting", letter.body)
+			}
+		})
+	}
+}
+
+func TestPostGreeting(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, svc UsersService, anna, boris string)
+		target  int
+		request domain.GreetingRequest
+		wantErr error
+	}{
+		{name: "posts", target: 2, request: domain.GreetingRequest{Text: "Happy birthday!"}},
+		{name: "only reactions", target: 2, request: domain.GreetingRequest{Reactions: []string{"🎂", "👨‍👩‍👧"}}},
+		{name: "yourself", target: 1, request: domain.GreetingRequest{Text: "Happy birthday!"}, wantErr: domain.ErrSelfGreeting},
+		{name: "unknown user", target: 100, request: domain.GreetingRequest{Text: "Happy birthday!"}, wantErr: domain.ErrNotFound},
+		{name: "empty", target: 2, request: domain.GreetingRequest{Text: "  "}, wantErr: domain.ErrInvalidGreeting},
+		{name: "text as a reaction", target: 2, request: domain.GreetingRequest{Reactions: []string{"ok"}}, wantErr: domain.ErrInvalidGreeting},
+		{name: "too long", target: 2, request: domain.GreetingRequest{Text: strings.Repeat("a", MaxGreetingLength+1)}, wantErr: domain.ErrInvalidGreeting},
+		{
+			name: "blocked by the recipient",
+			setup: func(t *testing.T, svc UsersService, _, boris string) {
+				if err := svc.Block(boris, 1); err != nil {
+					t.Fatal(err)
+				}
+			},
+			target:  2,
+			request: domain.GreetingRequest{Text: "Happy birthday!"},
+			wantErr: domain.ErrBlocked,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestService(t, config.Config{GreetingWindowDays: 3})
+			anna, annaToken := addUser(t, svc, "anna@corp.ru")
+			boris, borisToken := addUser(t, svc, "boris@corp.ru")
+			for _, user := range []domain.User{anna, boris} {
+				birthday := bornIn(0)
+				if _, err := svc.UpdateUser(user.ID, domain.AdminSettingsRequest{DateOfBirth: &birthday}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.setup != nil {
+				tt.setup(t, svc, annaToken, borisToken)
+			}
+
+			_, err := greetingsService(svc).PostGreeting(annaToken, tt.target, tt.request)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("PostGreeting() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateGreeting(t *testing.T) {
+	svc, _ := newTestService(t, config.Config{GreetingWindowDays: 3})
+	anna, annaToken := addUser(t, svc, "anna@corp.ru")
+	boris, borisToken := addUser(t, svc, "boris@corp.ru")
+	greetings := greetingsService(svc)
+
+	insert := func(birthday time.Time) int {
+		greeting, err := svc.Storage.(*storage.Storage).InsertGreeting(domain.Greeting{
+			RecipientID: boris.ID,
+			AuthorID:    anna.ID,
+			Birthday:    birthday.Format(domain.DateLayout),
+			Text:        "Happy birthday!",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return greeting.ID
+	}
+	open := insert(time.Now().AddDate(0, 0, -3))
+	closed := insert(time.Now().AddDate(0, 0, -4))
+
+	text := "Happy birthday again!"
+	tests := []struct {
+		name    string
+		token   string
+		id      int
+		wantErr error
+	}{
+		{name: "within the window", token: annaToken, id: open},
+		{name: "after the window", token: annaToken, id: closed, wantErr: domain.ErrGreetingClosed},
+		{name: "not the author", token: borisToken, id: open, wantErr: domain.ErrForbidden},
+		{name: "unknown greeting", token: annaToken, id: 100, wantErr: domain.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			greeting, err := greetings.UpdateGreeting(tt.token, tt.id, domain.GreetingUpdateRequest{Text: &text})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateGreeting() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && greeting.Text != text {
+				t.Errorf("UpdateGreeting() = %+v, want the new text", greeting)
+			}
+		})
+	}
+}
+
+func TestGetBirthdayGreetingsBlocked(t *testing.T) {
+	svc, _ := newTestService(t, config.Config{GreetingWindowDays: 3})
+	anna, annaToken := addUser(t, svc, "anna@corp.ru")
+	_, borisToken := addUser(t, svc, "boris@corp.ru")
+	greetings := greetingsService(svc)
+
+	if _, err := greetings.GetBirthdayGreetings(borisToken, anna.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Block(annaToken, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := greetings.GetBirthdayGreetings(borisToken, anna.ID); !errors.Is(err, domain.ErrBlocked) {
+		t.Errorf("GetBirthdayGreetings() by a blocked user error = %v, want %v", err, domain.ErrBlocked)
+	}
+}
+
+func TestCreateFund(t *testing.T) {
+	svc, mail := newTestService(t, config.Config{})
+	organizer, anna := addUser(t, svc, "anna@corp.ru")
+	boris, borisToken := addUser(t, svc, "boris@corp.ru")
+	_, vera := addUser(t, svc, "vera@corp.ru")
+	gleb, glebToken := addUser(t, svc, "gleb@corp.ru")
+	if _, err := svc.Subscribe(vera, boris.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Block(glebToken, organizer.ID); err != nil {
+		t.Fatal(err)
+	}
+	funds := fundsService(svc)
+
+	tests := []struct {
+		name    string
+		request domain.FundRequest
+		wantErr error
+	}{
+		{name: "yourself", request: domain.FundRequest{RecipientId: 1, Target: 1000}, wantErr: domain.ErrSelfFund},
+		{name: "unknown user", request: domain.FundRequest{RecipientId: 100, Target: 1000}, wantErr: domain.ErrNotFound},
+		{name: "recipient blocked the organizer", request: domain.FundRequest{RecipientId: gleb.ID, Target: 1000}, wantErr: domain.ErrBlocked},
+		{name: "no target", request: domain.FundRequest{RecipientId: boris.ID}, wantErr: domain.ErrInvalidFund},
+		{name: "deadline after the birthday", request: domain.FundRequest{RecipientId: boris.ID, Target: 1000, Deadline: "2990-01-01"}, wantErr: domain.ErrInvalidFund},
+		{name: "opens", request: domain.FundRequest{RecipientId: boris.ID, Target: 1000}},
+		{name: "twice for the birthday", request: domain.FundRequest{RecipientId: boris.ID, Target: 1000}, wantErr: domain.ErrExists},
+	}
+	// the cases share the storage and run in order
+	for _, tt := range tests {
+		fund, err := funds.CreateFund(anna, tt.request)
+		if !errors.Is(err, tt.wantErr) {
+			t.Fatalf("%s: CreateFund() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if err == nil && (fund.Title != "Gift for boris@corp.ru" || fund.Deadline >= fund.Birthday) {
+			t.Errorf("%s: CreateFund() = %+v, want the default title and deadline", tt.name, fund)
+		}
+	}
+
+	// the follower is invited, the recipient doesn't learn about the fund
+	mail.last(t, "vera@corp.ru")
+	for _, letter := range mail.letters {
+		if letter.to == "boris@corp.ru" {
+			t.Errorf("the recipient got a letter: %+v", letter)
+		}
+	}
+	received, err := funds.GetFunds(borisToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 0 {
+		t.Errorf("GetFunds() of the recipient = %+v, want none", received)
+	}
+}
+
+func TestWishlistReservation(t *testing.T) {
+	svc, _ := newTestService(t, config.Config{})
+	anna, annaToken := addUser(t, svc, "anna@corp.ru")
+	_, boris := addUser(t, svc, "boris@corp.ru")
+	if _, err := svc.Subscribe(boris, anna.ID); err != nil {
+		t.Fatal(err)
+	}
+	wishlist := wishlistService(svc)
+
+	item, err := wishlist.AddWishItem(annaToken, domain.WishItemRequest{Title: " Book "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Title != "Book" || item.Priority != domain.PriorityMedium {
+		t.Errorf("AddWishItem() = %+v, want the trimmed title and the medium priority", item)
+	}
+
+	if _, err := wishlist.ReserveWishItem(annaToken, anna.ID, item.ID); !errors.Is(err, domain.ErrNotFollowing) {
+		t.Errorf("ReserveWishItem() of own item error = %v, want %v", err, domain.ErrNotFollowing)
+	}
+	if _, err := wishlist.ReserveWishItem(boris, anna.ID, item.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := wishlist.GetWishlist(annaToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Reserved || items[0].ReservedByMe {
+		t.Errorf("GetWishlist() of the owner = %+v, want no reservation", items)
+	}
+}
+
+func TestPrivacy(t *testing.T) {
+	svc, _ := newTestService(t, config.Config{GreetingWindowDays: 3})
+	anna, annaToken := addUser(t, svc, "anna@corp.ru")
+	_, boris := addUser(t, svc, "boris@corp.ru")
+	hide := true
+	policy := func(v domain.FollowPolicy) *domain.FollowPolicy { return &v }
+	today := time.Now().Format(domain.DateLayout)
+
+	steps := []struct {
+		name    string
+		privacy domain.PrivacyRequest
+		action  func() error
+		wantErr error
+	}{
+		{
+			name:    "team only",
+			privacy: domain.PrivacyRequest{FollowPolicy: policy(domain.FollowTeam)},
+			action: func() error {
+				_, err := svc.Subscribe(boris, anna.ID)
+				return err
+			},
+			wantErr: domain.ErrTeamOnly,
+		},
+		{
+			name:    "approved only",
+			privacy: domain.PrivacyRequest{FollowPolicy: policy(domain.FollowApproved)},
+			action: func() error {
+				response, err := svc.Subscribe(boris, anna.ID)
+				if err == nil && !response.Pending {
+					t.Errorf("Subscribe() = %+v, want a pending follow request", response)
+				}
+				return err
+			},
+		},
+		{
+			name:    "greeting a hidden birthday",
+			privacy: domain.PrivacyRequest{HideBirthday: &hide},
+			action: func() error {
+				_, err := greetingsService(svc).PostGreeting(boris, anna.ID, domain.GreetingRequest{Text: "Happy birthday!"})
+				return err
+			},
+			wantErr: domain.ErrBirthdayHidden,
+		},
+		{
+			name: "fund for a hidden birthday",
+			action: func() error {
+				_, err := fundsService(svc).CreateFund(boris, domain.FundRequest{RecipientId: anna.ID, Target: 1000})
+				return err
+			},
+			wantErr: domain.ErrBirthdayHidden,
+		},
+		{
+			name: "dashboard without a hidden birthday",
+			action: func() error {
+				upcoming, err := svc.GetUpcomingBirthdays(boris, 7)
+				if err == nil && len(upcoming) != 0 {
+					t.Errorf("GetUpcomingBirthdays() = %+v, want none", upcoming)
+				}
+				return err
+			},
+		},
+	}
+	if _, err := svc.Settings(annaToken, domain.SettingsRequest{DateOfBirth: &today}); err != nil {
+		t.Fatal(err)
+	}
+	// the steps share the settings and run in order
+	for _, step := range steps {
+		if _, err := svc.Settings(annaToken, domain.SettingsRequest{Privacy: &step.privacy}); err != nil {
+			t.Fatalf("%s: Settings() error = %v", step.name, err)
+		}
+		if err := step.action(); !errors.Is(err, step.wantErr) {
+			t.Errorf("%s: error = %v, want %v", step.name, err, step.wantErr)
+		}
+	}
+}
+
+func TestFollowRequest(t *testing.T) {
+	svc, mail := newTestService(t, config.Config{})
+	anna, annaToken := addUser(t, svc, "anna@corp.ru")
+	_, boris := addUser(t, svc, "boris@corp.ru")
+	approved := domain.FollowApproved
+	_, err := svc.Settings(annaToken, domain.SettingsRequest{Privacy: &domain.PrivacyRequest{FollowPolicy: &approved}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := svc.Subscribe(boris, anna.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !response.Pending {
+		t.Errorf("Subscribe() = %+v, want a pending follow request", response)
+	}
+	if got := mail.last(t, "anna@corp.ru"); got.subject != "New follow request" {
+		t.Errorf("the letter to the user = %+v, want the follow request", got)
+	}
+	if _, err := svc.Subscribe(boris, anna.ID); !errors.Is(err, domain.ErrExists) {
+		t.Errorf("Subscribe() twice error = %v, want %v", err, domain.ErrExists)
+	}
+
+	// no reminders until the request is approved
+	profile, err := svc.GetProfile(boris, anna.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.IsSubscribed {
+		t.Error("the follower is subscribed while the request is pending")
+	}
+
+	requests, err := svc.FollowRequests.GetFollowRequests(annaToken, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 1 || requests[0].User.Email != "boris@corp.ru" {
+		t.Fatalf("GetFollowRequests() = %+v, want the request of the follower", requests)
+	}
+	if err := svc.FollowRequests.RejectFollowRequest(boris, requests[0].ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("RejectFollowRequest() by the follower error = %v, want %v", err, domain.ErrNotFound)
+	}
+	if err := svc.FollowRequests.ApproveFollowRequest(annaToken, requests[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err = svc.GetProfile(boris, anna.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !profile.IsSubscribed {
+		t.Error("the follower isn't subscribed after the approval")
+	}
+}
+
+func TestSendReminders(t *testing.T) {
+	svc, mail := newTestService(t, config.Config{})
+	_, anna := addUser(t, svc, "anna@corp.ru")
+	_, vera := addUser(t, svc, "vera@corp.ru")
+	boris, borisToken := addUser(t, svc, "boris@corp.ru")
+	dina, dinaToken := addUser(t, svc, "dina@corp.ru")
+	tomorrow := bornIn(1)
+	hide, approved := true, domain.FollowApproved
+	for _, settings := range []struct {
+		token   string
+		privacy domain.PrivacyRequest
+	}{
+		{token: borisToken, privacy: domain.PrivacyRequest{FollowPolicy: &approved}},
+		{token: dinaToken, privacy: domain.PrivacyRequest{HideBirthday: &hide}},
+	} {
+		_, err := svc.Settings(settings.token, domain.SettingsRequest{DateOfBirth: &tomorrow, Privacy: &settings.privacy})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// anna follows boris, vera only asked to; both follow dina
+	for _, follow := range []struct {
+		token  string
+		target int
+	}{
+		{token: anna, target: boris.ID},
+		{token: vera, target: boris.ID},
+		{token: anna, target: dina.ID},
+		{token: vera, target: dina.ID},
+	} {
+		if _, err := svc.Subscribe(follow.token, follow.target); err != nil {
+			t.Fatal(err)
+		}
+	}
+	requests, err := svc.FollowRequests.GetFollowRequests(borisToken, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 || requests[0].User.Email != "anna@corp.ru" {
+		t.Fatalf("GetFollowRequests() = %+v, want the requests of anna and vera", requests)
+	}
+	if err := svc.FollowRequests.ApproveFollowRequest(borisToken, requests[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	sent, err := svc.Notifications.SendReminders(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != 1 {
+		t.Errorf("SendReminders() = %d, want only the reminder about boris to anna", sent)
+	}
+	if got := mail.last(t, "anna@corp.ru"); got.subject != "Birthday of boris@corp.ru" {
+		t.Errorf("the reminder = %+v, want the birthday of boris", got)
+	}
+	for _, letter := range mail.letters {
+		if letter.to == "vera@corp.ru" {
+			t.Errorf("a pending follow request gave a reminder: %+v", letter)
+		}
+	}
+
+	// every birthday is reminded about once
+	sent, err = svc.Notifications.SendReminders(time.Now())
+	if err != nil || sent != 0 {
+		t.Errorf("SendReminders() again = %d, %v, want 0", sent, err)
+	}
+}
+
+func TestGetDashboard(t *testing.T) {
+	office := time.FixedZone("MSK", 3*60*60)
+	svc, _ := newTestService(t, config.Config{TimeZone: office, DashboardDays: 7})
+	_, session := addUser(t, svc, "anna@corp.ru")
+	boris, borisToken := addUser(t, svc, "boris@corp.ru")
+	_, dinaToken := addUser(t, svc, "dina@corp.ru")
+
+	// 23:30 in UTC is already the next day in the office
+	now := time.Date(2024, 3, 1, 23, 30, 0, 0, time.UTC)
+	officeDay := "1990-03-02"
+	hide := true
+	if _, err := svc.Settings(borisToken, domain.SettingsRequest{DateOfBirth: &officeDay}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := svc.Settings(dinaToken, domain.SettingsRequest{DateOfBirth: &officeDay, Privacy: &domain.PrivacyRequest{HideBirthday: &hide}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	personal := func(scopes ...string) string {
+		created, err := svc.CreatePersonalToken(session, domain.CreateTokenRequest{Name: "screen", Scopes: scopes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return created.Token
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "session", token: session, wantErr: domain.ErrReadOnlyToken},
+		{name: "token that can change subscriptions", token: personal(domain.ScopeReadBirthdays, domain.ScopeWriteSubscriptions), wantErr: domain.ErrReadOnlyToken},
+		{name: "unknown token", token: domain.PersonalTokenPrefix + "nope", wantErr: domain.ErrInvalidToken},
+		{name: "read-only token", token: personal(domain.ScopeReadBirthdays)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dashboard, err := svc.GetDashboard(tt.token, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetDashboard() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			// the hidden birthday isn't on the shared screen
+			if dashboard.Date != "2024-03-02" || len(dashboard.Today) != 1 || dashboard.Today[0].ID != boris.ID {
+				t.Errorf("GetDashboard() = %+v, want only boris today in the office", dashboard)
+			}
+		})
+	}
+}
+
+// chatBot keeps the messages sent to the chats.
+type chatBot struct {
+	sent map[int64][]string
+}
+
+func (b *chatBot) GetUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]domain.BotUpdate, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (b *chatBot) SendMessage(ctx context.Context, chatID int64, text string) error {
+	b.sent[chatID] = append(b.sent[chatID], text)
+	return nil
+}
+
+func TestBotLink(t *testing.T) {
+	svc, _ := newTestService(t, config.Config{})
+	_, token := addUser(t, svc, "anna@corp.ru")
+
+	if _, err := NewBotService(svc.Storage.(*storage.Storage), svc, nil).CreateBotLink(token); !errors.Is(err, domain.ErrBotDisabled) {
+		t.Errorf("CreateBotLink() without a bot error = %v, want %v", err, domain.ErrBotDisabled)
+	}
+
+	bot := NewBotService(svc.Storage.(*storage.Storage), svc, &chatBot{sent: make(map[int64][]string)})
+	link, err := bot.CreateBotLink(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replaced, err := bot.CreateBotLink(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name      string
+		update    domain.BotUpdate
+		wantReply string
+	}{
+		{name: "group chat", update: domain.BotUpdate{ChatID: 1, Text: replaced.Command}, wantReply: "Write to me in a private chat."},
+		{name: "replaced code", update: domain.BotUpdate{ChatID: 1, Private: true, Text: link.Command}, wantReply: "The code is invalid"},
+		{name: "not linked", update: domain.BotUpdate{ChatID: 1, Private: true, Text: "/today"}, wantReply: "This chat isn't linked"},
+		{name: "link", update: domain.BotUpdate{ChatID: 1, Private: true, Text: replaced.Command}, wantReply: "Hi, anna@corp.ru! The chat is linked"},
+		{name: "used code", update: domain.BotUpdate{ChatID: 2, Private: true, Text: "/start " + replaced.Code}, wantReply: "The code is invalid"},
+		{name: "linked", update: domain.BotUpdate{ChatID: 1, Private: true, Text: "/today"}, wantReply: "No birthdays today."},
+	}
+	// the steps share the storage and run in order
+	for _, step := range steps {
+		if got := bot.botReply(step.update); !strings.HasPrefix(got, step.wantReply) {
+			t.Errorf("%s: botReply() = %q, want %q", step.name, got, step.wantReply)
+		}
+	}
+
+	status, err := bot.GetBotStatus(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Enabled || !status.Linked {
+		t.Errorf("GetBotStatus() = %+v, want linked", status)
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/krevetkou/test-rutube/internal/auth"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"log"
+	"time"
+)
+
+const VerificationTTL = time.Hour * 48
+
+func (s UsersService) VerifyEmail(token string) error {
+	claims, err := s.Tokens.Parse(token, auth.PurposeVerify)
+	if err != nil {
+		return domain.ErrInvalidToken
+	}
+
+	id, err := claims.UserID()
+	if err != nil {
+		return domain.ErrInvalidToken
+	}
+
+	err = s.Storage.MarkVerified(id, claims.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotExists), errors.Is(err, domain.ErrInvalidToken):
+			return domain.ErrInvalidToken
+		case errors.Is(err, domain.ErrVerified):
+			return domain.ErrVerified
+		}
+		return err
+	}
+
+	s.usersChanged()
+
+	return nil
+}
+
+func (s UsersService) ResendVerification(token string) error {
+	user, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.ErrNotExists
+	}
+
+	return s.sendVerification(user)
+}
+
+func (s UsersService) sendVerification(user domain.User) error {
+	err := s.Storage.TouchVerificationSent(user.ID, time.Now(), s.Config.VerificationResendInterval)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrTooManyRequests):
+			return domain.ErrTooManyRequests
+		case errors.Is(err, domain.ErrVerified):
+			return domain.ErrVerified
+		case errors.Is(err, domain.ErrNotExists):
+			return domain.ErrNotExists
+		}
+		return err
+	}
+
+	t, err := s.Tokens.Sign(user.ID, auth.PurposeVerify, user.Email, VerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	err = s.Mailer.Send(user.Email, "Confirm your email",
+		fmt.Sprintf("Welcome, %s! To activate your account open the link: %s", user.Name, s.link("/user/verify", t)))
+	if err != nil {
+		log.Println(err)
+		return domain.ErrMailNotSent
+	}
+
+	return nil
+}
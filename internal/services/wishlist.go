@@ -0,0 +1,217 @@
+package services
+
+import (
+	"errors"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MaxWishlistItems   = 100
+	MaxWishTitleLength = 200
+	MaxWishLinkLength  = 2000
+)
+
+type WishlistRepository interface {
+	UserLookup
+	GetWishlist(ownerID int) ([]domain.WishItemResponse, error)
+	GetFollowerWishlist(viewerID, ownerID int) ([]domain.WishItemResponse, error)
+	AddWishItem(ownerID int, item domain.WishItem, limit int) (domain.WishItemResponse, error)
+	UpdateWishItem(ownerID, id int, request domain.WishItemUpdateRequest) (domain.WishItemResponse, error)
+	DeleteWishItem(ownerID, id int) error
+	ReserveWishItem(viewerID, ownerID, id int, now time.Time) (domain.WishItemResponse, error)
+	ReleaseWishItem(viewerID, ownerID, id int) (domain.WishItemResponse, error)
+}
+
+// WishlistService keeps the wishlists and the reservations of the followers.
+type WishlistService struct {
+	Storage WishlistRepository
+}
+
+func NewWishlistService(storage WishlistRepository) WishlistService {
+	return WishlistService{Storage: storage}
+}
+
+func (s WishlistService) GetWishlist(token string) ([]domain.WishItemResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return []domain.WishItemResponse{}, domain.ErrNotExists
+	}
+
+	return s.Storage.GetWishlist(currentUser.ID)
+}
+
+// GetUserWishlist shows the wishlist of the user to a follower, with the
+// items somebody has already reserved marked.
+func (s WishlistService) GetUserWishlist(token string, userId int) ([]domain.WishItemResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return []domain.WishItemResponse{}, domain.ErrNotExists
+	}
+
+	items, err := s.Storage.GetFollowerWishlist(currentUser.ID, userId)
+	if err != nil {
+		return []domain.WishItemResponse{}, wishlistError(err)
+	}
+
+	return items, nil
+}
+
+func (s WishlistService) AddWishItem(token string, request domain.WishItemRequest) (domain.WishItemResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.WishItemResponse{}, domain.ErrNotExists
+	}
+
+	if request.Priority == "" {
+		request.Priority = domain.PriorityMedium
+	}
+
+	fields, err := validateWishItem(domain.WishItemUpdateRequest{
+		Title:    &request.Title,
+		Link:     &request.Link,
+		PriceMin: &request.PriceMin,
+		PriceMax: &request.PriceMax,
+		Priority: &request.Priority,
+	})
+	if err != nil {
+		return domain.WishItemResponse{}, err
+	}
+	if *fields.Title == "" {
+		return domain.WishItemResponse{}, domain.ErrFieldsRequired
+	}
+	if request.PriceMax > 0 && request.PriceMin > request.PriceMax {
+		return domain.WishItemResponse{}, domain.ErrInvalidWishItem
+	}
+
+	item, err := s.Storage.AddWishItem(currentUser.ID, domain.WishItem{
+		Title:     *fields.Title,
+		Link:      *fields.Link,
+		PriceMin:  request.PriceMin,
+		PriceMax:  request.PriceMax,
+		Priority:  request.Priority,
+		CreatedAt: time.Now(),
+	}, MaxWishlistItems)
+	if err != nil {
+		return domain.WishItemResponse{}, wishlistError(err)
+	}
+
+	return item, nil
+}
+
+func (s WishlistService) UpdateWishItem(token string, id int, request domain.WishItemUpdateRequest) (domain.WishItemResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.WishItemResponse{}, domain.ErrNotExists
+	}
+
+	request, err = validateWishItem(request)
+	if err != nil {
+		return domain.WishItemResponse{}, err
+	}
+	if request.Title != nil && *request.Title == "" {
+		return domain.WishItemResponse{}, domain.ErrInvalidWishItem
+	}
+
+	item, err := s.Storage.UpdateWishItem(currentUser.ID, id, request)
+	if err != nil {
+		return domain.WishItemResponse{}, wishlistError(err)
+	}
+
+	return item, nil
+}
+
+func (s WishlistService) DeleteWishItem(token string, id int) error {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.ErrNotExists
+	}
+
+	err = s.Storage.DeleteWishItem(currentUser.ID, id)
+	if err != nil {
+		return wishlistError(err)
+	}
+
+	return nil
+}
+
+func (s WishlistService) ReserveWishItem(token string, userId, id int) (domain.WishItemResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.WishItemResponse{}, domain.ErrNotExists
+	}
+
+	item, err := s.Storage.ReserveWishItem(currentUser.ID, userId, id, time.Now())
+	if err != nil {
+		return domain.WishItemResponse{}, wishlistError(err)
+	}
+
+	return item, nil
+}
+
+func (s WishlistService) ReleaseWishItem(token string, userId, id int) (domain.WishItemResponse, error) {
+	currentUser, err := s.Storage.GetUserByToken(token)
+	if err != nil {
+		return domain.WishItemResponse{}, domain.ErrNotExists
+	}
+
+	item, err := s.Storage.ReleaseWishItem(currentUser.ID, userId, id)
+	if err != nil {
+		return domain.WishItemResponse{}, wishlistError(err)
+	}
+
+	return item, nil
+}
+
+// validateWishItem checks the fields that were sent and returns them normalized.
+func validateWishItem(request domain.WishItemUpdateRequest) (domain.WishItemUpdateRequest, error) {
+	if request.Title != nil {
+		title := strings.TrimSpace(*request.Title)
+		if utf8.RuneCountInString(title) > MaxWishTitleLength {
+			return domain.WishItemUpdateRequest{}, domain.ErrInvalidWishItem
+		}
+		request.Title = &title
+	}
+
+	if request.Link != nil {
+		link := strings.TrimSpace(*request.Link)
+		if link != "" {
+			u, err := url.Parse(link)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(link) > MaxWishLinkLength {
+				return domain.WishItemUpdateRequest{}, domain.ErrInvalidWishItem
+			}
+		}
+		request.Link = &link
+	}
+
+	if (request.PriceMin != nil && *request.PriceMin < 0) || (request.PriceMax != nil && *request.PriceMax < 0) {
+		return domain.WishItemUpdateRequest{}, domain.ErrInvalidWishItem
+	}
+
+	if request.Priority != nil && !request.Priority.IsValid() {
+		return domain.WishItemUpdateRequest{}, domain.ErrInvalidWishItem
+	}
+
+	return request, nil
+}
+
+func wishlistError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotExists):
+		return domain.ErrNotExists
+	case errors.Is(err, domain.ErrNotFound):
+		return domain.ErrNotFound
+	case errors.Is(err, domain.ErrNotFollowing):
+		return domain.ErrNotFollowing
+	case errors.Is(err, domain.ErrExists):
+		return domain.ErrExists
+	case errors.Is(err, domain.ErrWishlistFull):
+		return domain.ErrWishlistFull
+	case errors.Is(err, domain.ErrInvalidWishItem):
+		return domain.ErrInvalidWishItem
+	}
+	return err
+}
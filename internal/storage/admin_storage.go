@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func (s *Storage) GetUsers() ([]domain.AdminUserResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]domain.AdminUserResponse, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, adminUserResponse(user))
+	}
+
+	return users, nil
+}
+
+func (s *Storage) SetRole(id int, role domain.Role) (domain.AdminUserResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.AdminUserResponse{}, domain.ErrNotFound
+	}
+
+	s.users[i].Role = role
+
+	return adminUserResponse(s.users[i]), nil
+}
+
+// SetDisabled disables or enables the account. Disabling ends all sessions,
+// personal tokens are kept but don't work while the account is disabled.
+func (s *Storage) SetDisabled(id int, disabled bool) (domain.AdminUserResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.AdminUserResponse{}, domain.ErrNotFound
+	}
+
+	if s.users[i].Disabled != disabled {
+		s.users[i].Disabled = disabled
+		if disabled {
+			s.users[i].Sessions = nil
+		} else {
+			s.followTeams(i)
+		}
+		s.refreshTeamFollowers(i)
+	}
+
+	return adminUserResponse(s.users[i]), nil
+}
+
+func (s *Storage) UpdateUser(id int, settings domain.AdminSettingsRequest) (domain.AdminUserResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.AdminUserResponse{}, domain.ErrNotFound
+	}
+
+	user := &s.users[i]
+	if settings.DaysToNotification != nil {
+		user.DaysToNotification = *settings.DaysToNotification
+	}
+	if settings.DateOfBirth != nil {
+		user.DateOfBirth = *settings.DateOfBirth
+	}
+	if settings.Department != nil {
+		s.setDepartment(i, *settings.Department)
+	}
+
+	return adminUserResponse(s.users[i]), nil
+}
+
+// DeleteUser removes the account with its subscriptions in both directions,
+// blocks, follow requests, personal tokens, greetings, gift funds, wishlist
+// reservations and notifications.
+func (s *Storage) DeleteUser(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.ErrNotFound
+	}
+
+	for _, userId := range s.users[i].SubscribeUsers {
+		s.removeFollower(userId, id)
+	}
+	delete(s.followers, id)
+	s.users = append(s.users[:i], s.users[i+1:]...)
+
+	for j := range s.users {
+		user := &s.users[j]
+		user.SubscribeUsers = removeID(user.SubscribeUsers, id)
+		user.SubscribedByTeam = removeID(user.SubscribedByTeam, id)
+		user.TeamExceptions = removeID(user.TeamExceptions, id)
+		user.BlockedUsers = removeID(user.BlockedUsers, id)
+	}
+
+	tokens := s.tokens[:0]
+	for _, t := range s.tokens {
+		if t.UserID != id {
+			tokens = append(tokens, t)
+		}
+	}
+	s.tokens = tokens
+
+	s.removeGreetings(id)
+	s.removeFunds(id)
+	s.releaseReservations(id)
+	s.removeFollowRequests(id, 0)
+	s.removeNotifications(id)
+
+	return nil
+}
+
+// UpdateImported sets the fields that come from an HR upload.
+func (s *Storage) UpdateImported(id int, record domain.ImportRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.ErrNotFound
+	}
+
+	s.users[i].Name = record.Name
+	s.users[i].DateOfBirth = record.DateOfBirth
+	s.setDepartment(i, record.Department)
+
+	return nil
+}
+
+func adminUserResponse(user domain.User) domain.AdminUserResponse {
+	return domain.AdminUserResponse{
+		ID:                 user.ID,
+		Email:              user.Email,
+		Name:               user.Name,
+		DateOfBirth:        user.DateOfBirth,
+		DaysToNotification: user.DaysToNotification,
+		Department:         user.Department,
+		Role:               user.Role,
+		Verified:           user.Verified,
+		Disabled:           user.Disabled,
+		TwoFactorEnabled:   user.TOTPSecret != "",
+	}
+}
+
+func removeID(ids []int, id int) []int {
+	for i := range ids {
+		if ids[i] == id {
+			return append(ids[:i], ids[i+1:]...)
+		}
+	}
+
+	return ids
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func TestSetDisabledEndsSessions(t *testing.T) {
+	s := NewStorage()
+	anna := addUser(t, s, "anna@corp.ru")
+
+	if _, err := s.SetDisabled(anna.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SetDisabled(anna.ID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// enabling the account again doesn't bring the old logins back
+	if _, err := s.GetUserByToken(anna.Email); err != domain.ErrNotExists {
+		t.Errorf("GetUserByToken() after the disable error = %v, want ErrNotExists", err)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	s := NewStorage()
+	anna := addUser(t, s, "anna@corp.ru")
+	boris := addUser(t, s, "boris@corp.ru")
+	vera := addUser(t, s, "vera@corp.ru")
+	for _, sub := range [][2]domain.User{{anna, boris}, {boris, anna}, {vera, boris}} {
+		if err := s.Subscribe(sub[0].ID, sub[1].ID, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Block(vera.Email, boris.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteUser(boris.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteUser(boris.ID); err != domain.ErrNotFound {
+		t.Errorf("DeleteUser() twice error = %v, want ErrNotFound", err)
+	}
+
+	for _, user := range []domain.User{anna, vera} {
+		stored, err := s.GetUserByID(user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stored.SubscribeUsers) != 0 || len(stored.BlockedUsers) != 0 || len(s.followers[user.ID]) != 0 {
+			t.Errorf("%s still refers to the deleted user: %+v", user.Email, stored)
+		}
+	}
+
+	// the ID of the deleted user isn't given out again
+	gleb := addUser(t, s, "gleb@corp.ru")
+	if gleb.ID == boris.ID {
+		t.Errorf("the new user got the deleted ID %d", gleb.ID)
+	}
+}
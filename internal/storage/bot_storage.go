@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"time"
+)
+
+func (s *Storage) SetBotLinkCode(id int, hash string, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	s.users[i].BotLinkHash = hash
+	s.users[i].BotLinkExpires = expires
+
+	return nil
+}
+
+// LinkBotChat links the chat to the account the one-time code was issued
+// for. A chat belongs to one account, the previous link of the chat is removed.
+func (s *Storage) LinkBotChat(hash string, chatID int64, now time.Time) (domain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := -1
+	for ind := range s.users {
+		if hash != "" && s.users[ind].BotLinkHash == hash {
+			i = ind
+			break
+		}
+	}
+	if i < 0 || s.users[i].Disabled || now.After(s.users[i].BotLinkExpires) {
+		return domain.User{}, domain.ErrInvalidCode
+	}
+
+	for ind := range s.users {
+		if s.users[ind].BotChatID == chatID {
+			s.users[ind].BotChatID = 0
+			s.users[ind].BotLinkedAt = time.Time{}
+		}
+	}
+
+	s.users[i].BotChatID = chatID
+	s.users[i].BotLinkedAt = now
+	s.users[i].BotLinkHash = ""
+	s.users[i].BotLinkExpires = time.Time{}
+
+	return cloneUser(s.users[i]), nil
+}
+
+func (s *Storage) UnlinkBotChat(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	if s.users[i].BotChatID == 0 {
+		return domain.ErrNotFound
+	}
+
+	s.users[i].BotChatID = 0
+	s.users[i].BotLinkedAt = time.Time{}
+
+	return nil
+}
+
+// GetUserByBotChat returns the account linked to the chat, disabled accounts
+// can't use the bot.
+func (s *Storage) GetUserByBotChat(chatID int64) (domain.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if chatID != 0 && user.BotChatID == chatID && !user.Disabled {
+			return cloneUser(user), nil
+		}
+	}
+
+	return domain.User{}, domain.ErrNotFound
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func TestLinkBotChat(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		hash    string
+		at      time.Time
+		disable bool
+		wantErr error
+	}{
+		{name: "valid code", hash: "code", at: now},
+		{name: "on the expiry", hash: "code", at: now.Add(15 * time.Minute)},
+		{name: "expired code", hash: "code", at: now.Add(15*time.Minute + time.Second), wantErr: domain.ErrInvalidCode},
+		{name: "unknown code", hash: "other", at: now, wantErr: domain.ErrInvalidCode},
+		{name: "empty code", hash: "", at: now, wantErr: domain.ErrInvalidCode},
+		{name: "disabled account", hash: "code", at: now, disable: true, wantErr: domain.ErrInvalidCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStorage()
+			anna := addUser(t, s, "anna@corp.ru")
+			if err := s.SetBotLinkCode(anna.ID, "code", now.Add(15*time.Minute)); err != nil {
+				t.Fatal(err)
+			}
+			s.users[s.indexByID(anna.ID)].Disabled = tt.disable
+
+			user, err := s.LinkBotChat(tt.hash, 42, tt.at)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("LinkBotChat() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if user.ID != anna.ID || user.BotChatID != 42 {
+				t.Errorf("LinkBotChat() = %+v, want anna linked to the chat", user)
+			}
+
+			// the code is single-use
+			if _, err := s.LinkBotChat(tt.hash, 43, tt.at); !errors.Is(err, domain.ErrInvalidCode) {
+				t.Errorf("LinkBotChat() with a used code error = %v, want %v", err, domain.ErrInvalidCode)
+			}
+		})
+	}
+}
+
+func TestLinkBotChatMovesChat(t *testing.T) {
+	s := NewStorage()
+	anna := addUser(t, s, "anna@corp.ru")
+	boris := addUser(t, s, "boris@corp.ru")
+	expires := time.Now().Add(time.Hour)
+	for _, user := range []domain.User{anna, boris} {
+		if err := s.SetBotLinkCode(user.ID, user.Email, expires); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.LinkBotChat(user.Email, 42, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a chat belongs to one account
+	linked, err := s.GetUserByBotChat(42)
+	if err != nil || linked.ID != boris.ID {
+		t.Errorf("GetUserByBotChat() = %+v, %v, want boris", linked, err)
+	}
+	if err := s.UnlinkBotChat(anna.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("UnlinkBotChat() of the previous account error = %v, want %v", err, domain.ErrNotFound)
+	}
+
+	if err := s.UnlinkBotChat(boris.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetUserByBotChat(42); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetUserByBotChat() after unlinking error = %v, want %v", err, domain.ErrNotFound)
+	}
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"strings"
+	"time"
+)
+
+// cursor points at the last element of a page: the value of the sort key
+// and the user ID as a tie-breaker, so pages stay stable when users are added.
+// List is the hash of the sort and the filters of the first page, a cursor of
+// another list is rejected. Date is the day the birthday keys were counted
+// from, the next pages use it too and don't shift at midnight.
+type cursor struct {
+	Key  string `json:"k"`
+	ID   int    `json:"i"`
+	List string `json:"l,omitempty"`
+	Date string `json:"d,omitempty"`
+}
+
+type listItem struct {
+	cursor  cursor
+	profile domain.ProfileResponse
+}
+
+func (c cursor) less(other cursor) bool {
+	if c.Key != other.Key {
+		return c.Key < other.Key
+	}
+
+	return c.ID < other.ID
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor{}, domain.ErrInvalidCursor
+	}
+
+	var c cursor
+	err = json.Unmarshal(data, &c)
+	if err != nil {
+		return cursor{}, domain.ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// listHash tells the lists with different sorts and filters apart.
+func listHash(sortBy domain.ListSort, search string, subscribed *bool) string {
+	filter := "all"
+	if subscribed != nil {
+		filter = fmt.Sprint(*subscribed)
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s", sortBy, filter, search)))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:9])
+}
+
+func sortKey(user domain.User, sortBy domain.ListSort, now time.Time) string {
+	switch sortBy {
+	case domain.SortByName:
+		return strings.ToLower(user.Name)
+	case domain.SortByBirthday:
+		days, err := domain.DaysUntilBirthday(user.DateOfBirth, now)
+		if err != nil || user.Privacy.HideBirthday {
+			// users without a valid or visible date go to the end of the list
+			days = 999
+		}
+		return fmt.Sprintf("%03d", days)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []cursor{
+		{},
+		{Key: "anna", ID: 1},
+		{Key: "007", ID: 42},
+		{Key: "имя с пробелами", ID: 1 << 30},
+	}
+	for _, c := range tests {
+		got, err := decodeCursor(c.encode())
+		if err != nil || got != c {
+			t.Errorf("decodeCursor(%+v.encode()) = %+v, %v", c, got, err)
+		}
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "not base64", value: "***"},
+		{name: "padded base64", value: base64.URLEncoding.EncodeToString([]byte(`{"k":"a","i":10}`))},
+		{name: "not json", value: base64.RawURLEncoding.EncodeToString([]byte("k=a"))},
+		{name: "truncated json", value: base64.RawURLEncoding.EncodeToString([]byte(`{"k":"a"`))},
+		{name: "wrong types", value: base64.RawURLEncoding.EncodeToString([]byte(`{"k":1,"i":"a"}`))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeCursor(tt.value)
+			if !errors.Is(err, domain.ErrInvalidCursor) {
+				t.Errorf("decodeCursor(%q) error = %v, want ErrInvalidCursor", tt.value, err)
+			}
+		})
+	}
+}
+
+func TestCursorLess(t *testing.T) {
+	tests := []struct {
+		a, b cursor
+		want bool
+	}{
+		{a: cursor{Key: "a", ID: 2}, b: cursor{Key: "b", ID: 1}, want: true},
+		{a: cursor{Key: "b", ID: 1}, b: cursor{Key: "a", ID: 2}, want: false},
+		{a: cursor{Key: "a", ID: 1}, b: cursor{Key: "a", ID: 2}, want: true},
+		{a: cursor{Key: "a", ID: 2}, b: cursor{Key: "a", ID: 2}, want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.less(tt.b); got != tt.want {
+			t.Errorf("%+v.less(%+v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSortKey(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		user   domain.User
+		sortBy domain.ListSort
+		want   string
+	}{
+		{name: "name is lower case", user: domain.User{Name: "Anna Ivanova"}, sortBy: domain.SortByName, want: "anna ivanova"},
+		{name: "birthday today", user: domain.User{DateOfBirth: "1990-03-01"}, sortBy: domain.SortByBirthday, want: "000"},
+		{name: "birthday tomorrow", user: domain.User{DateOfBirth: "1990-03-02"}, sortBy: domain.SortByBirthday, want: "001"},
+		{name: "birthday passed", user: domain.User{DateOfBirth: "1990-02-28"}, sortBy: domain.SortByBirthday, want: "364"},
+		{name: "invalid date", user: domain.User{DateOfBirth: "1990-13-01"}, sortBy: domain.SortByBirthday, want: "999"},
+		{name: "hidden birthday", user: domain.User{DateOfBirth: "1990-03-01", Privacy: domain.Privacy{HideBirthday: true}}, sortBy: domain.SortByBirthday, want: "999"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sortKey(tt.user, tt.sortBy, now); got != tt.want {
+				t.Errorf("sortKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
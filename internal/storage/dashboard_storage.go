@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"sort"
+	"time"
+)
+
+// GetDashboard returns the birthdays of the active users for the next days,
+// now is in the time zone of the office.
+func (s *Storage) GetDashboard(viewerID int, now time.Time, days int) (domain.DashboardResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	dashboard := domain.DashboardResponse{
+		Date:     now.Format(domain.DateLayout),
+		TimeZone: now.Location().String(),
+		Today:    make([]domain.DashboardBirthday, 0),
+		Upcoming: make([]domain.DashboardBirthday, 0),
+	}
+
+	for _, user := range s.users {
+		if !user.Verified || user.Disabled || !user.BirthdayVisible(viewerID) {
+			continue
+		}
+
+		daysLeft, err := domain.DaysUntilBirthday(user.DateOfBirth, now)
+		if err != nil || daysLeft > days {
+			continue
+		}
+
+		birthday := domain.DashboardBirthday{
+			ID:          user.ID,
+			Name:        user.Name,
+			DateOfBirth: user.VisibleDateOfBirth(viewerID),
+			Date:        now.AddDate(0, 0, daysLeft).Format(domain.DateLayout),
+			DaysLeft:    daysLeft,
+		}
+		if daysLeft == 0 {
+			dashboard.Today = append(dashboard.Today, birthday)
+		} else {
+			dashboard.Upcoming = append(dashboard.Upcoming, birthday)
+		}
+	}
+
+	sort.Slice(dashboard.Today, func(i, j int) bool {
+		return dashboard.Today[i].ID < dashboard.Today[j].ID
+	})
+	sort.Slice(dashboard.Upcoming, func(i, j int) bool {
+		if dashboard.Upcoming[i].DaysLeft != dashboard.Upcoming[j].DaysLeft {
+			return dashboard.Upcoming[i].DaysLeft < dashboard.Upcoming[j].DaysLeft
+		}
+		return dashboard.Upcoming[i].ID < dashboard.Upcoming[j].ID
+	})
+
+	return dashboard, nil
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func (s *Storage) GetDirectoryUsers() ([]domain.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]domain.User, 0)
+	for _, user := range s.users {
+		if user.FromDirectory {
+			users = append(users, cloneUser(user))
+		}
+	}
+
+	return users, nil
+}
+
+// SetFromDirectory hands the account over to the directory sync. A user who
+// left and came back is enabled again.
+func (s *Storage) SetFromDirectory(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.ErrNotFound
+	}
+
+	user := &s.users[i]
+	user.FromDirectory = true
+	if user.LeftDirectory {
+		user.LeftDirectory = false
+		user.Disabled = false
+
+		// teams bring the subscriptions back in both directions
+		s.followTeams(i)
+		s.refreshTeamFollowers(i)
+	}
+
+	return nil
+}
+
+// DeactivateUser disables the account of a user who left and removes their
+// subscriptions in both directions, follow requests and wishlist
+// reservations, it returns how many subscriptions were removed.
+func (s *Storage) DeactivateUser(id int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return 0, domain.ErrNotFound
+	}
+
+	removed := 0
+	for _, userId := range s.users[i].SubscribeUsers {
+		s.removeFollower(userId, id)
+		removed++
+	}
+	s.users[i].SubscribeUsers = nil
+	s.users[i].SubscribedByTeam = nil
+
+	for _, followerId := range mapKeys(s.followers[id]) {
+		j := s.indexByID(followerId)
+		if j >= 0 && s.removeSubscription(j, id) {
+			removed++
+		}
+	}
+	delete(s.followers, id)
+	s.removeFollowRequests(id, 0)
+	s.releaseReservations(id)
+
+	s.users[i].Disabled = true
+	s.users[i].LeftDirectory = true
+	s.users[i].Sessions = nil
+
+	return removed, nil
+}
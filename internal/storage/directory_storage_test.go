@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func TestDeactivateUser(t *testing.T) {
+	s := NewStorage()
+	anna := addUser(t, s, "anna@corp.ru")
+	boris := addUser(t, s, "boris@corp.ru")
+	vera := addUser(t, s, "vera@corp.ru")
+	for _, sub := range [][2]domain.User{{anna, boris}, {boris, anna}, {vera, anna}} {
+		if err := s.Subscribe(sub[0].ID, sub[1].ID, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SetFromDirectory(anna.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := s.DeactivateUser(anna.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 3 {
+		t.Errorf("DeactivateUser() removed %d subscriptions, want 3", removed)
+	}
+	if _, err = s.GetUserByToken(anna.Email); err != domain.ErrNotExists {
+		t.Errorf("the session of the deactivated user works: %v", err)
+	}
+	for _, user := range []domain.User{boris, vera} {
+		stored, err := s.GetUserByID(user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stored.SubscribeUsers) != 0 || len(s.followers[user.ID]) != 0 {
+			t.Errorf("%s still follows or is followed: %+v", user.Email, stored)
+		}
+	}
+
+	// coming back to the directory enables the account
+	if err = s.SetFromDirectory(anna.ID); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := s.GetUserByID(anna.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Disabled || stored.LeftDirectory {
+		t.Errorf("the returned user is still disabled: %+v", stored)
+	}
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"slices"
+	"time"
+)
+
+func (s *Storage) SetPendingEmail(id int, email string) (domain.UserResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.UserResponse{}, domain.ErrNotExists
+	}
+
+	if s.indexByEmail(email) >= 0 {
+		return domain.UserResponse{}, domain.ErrExists
+	}
+
+	s.users[i].PendingEmail = email
+	if !slices.Contains(s.users[i].PreviousEmails, s.users[i].Email) {
+		s.users[i].PreviousEmails = append(s.users[i].PreviousEmails, s.users[i].Email)
+	}
+
+	return userResponse(s.users[i]), nil
+}
+
+func (s *Storage) ConfirmEmail(id int, email string) (domain.UserResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.UserResponse{}, domain.ErrNotExists
+	}
+
+	// only the latest requested change can be confirmed
+	if s.users[i].PendingEmail == "" || s.users[i].PendingEmail != email {
+		return domain.UserResponse{}, domain.ErrInvalidToken
+	}
+
+	if s.indexByEmail(email) >= 0 {
+		return domain.UserResponse{}, domain.ErrExists
+	}
+
+	s.users[i].Email = email
+	s.users[i].PendingEmail = ""
+	// the link was opened from the new mailbox, so it is verified too
+	if !s.users[i].Verified {
+		s.users[i].Verified = true
+		s.refreshTeamFollowers(i)
+	}
+
+	return userResponse(s.users[i]), nil
+}
+
+func (s *Storage) RevertEmail(id int, email string) (domain.UserResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.UserResponse{}, domain.ErrNotExists
+	}
+
+	// only an address the account had before can be brought back
+	if !slices.Contains(s.users[i].PreviousEmails, email) {
+		return domain.UserResponse{}, domain.ErrInvalidToken
+	}
+
+	if owner := s.indexByEmail(email); owner >= 0 && owner != i {
+		return domain.UserResponse{}, domain.ErrExists
+	}
+
+	s.users[i].Email = email
+	s.users[i].PendingEmail = ""
+
+	return userResponse(s.users[i]), nil
+}
+
+func (s *Storage) MarkVerified(id int, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	// a letter sent to a previous address can't verify the current one
+	if s.users[i].Email != email {
+		return domain.ErrInvalidToken
+	}
+
+	if s.users[i].Verified {
+		return domain.ErrVerified
+	}
+
+	s.users[i].Verified = true
+	s.refreshTeamFollowers(i)
+
+	return nil
+}
+
+// TouchVerificationSent records that a verification letter is being sent,
+// it fails with ErrTooManyRequests if the previous one was sent less than interval ago.
+func (s *Storage) TouchVerificationSent(id int, now time.Time, interval time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	if s.users[i].Verified {
+		return domain.ErrVerified
+	}
+
+	if now.Sub(s.users[i].VerificationSentAt) < interval {
+		return domain.ErrTooManyRequests
+	}
+
+	s.users[i].VerificationSentAt = now
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"sort"
+	"time"
+)
+
+func (s *Storage) InsertFollowRequest(followerID, targetID int, maxFollows int, now time.Time) (domain.FollowRequestResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, t := s.indexByID(followerID), s.indexByID(targetID)
+	if i < 0 || t < 0 {
+		return domain.FollowRequestResponse{}, domain.ErrNotExists
+	}
+
+	if contains(s.users[t].BlockedUsers, followerID) {
+		return domain.FollowRequestResponse{}, domain.ErrBlocked
+	}
+
+	if contains(s.users[i].SubscribeUsers, targetID) || s.followRequestIndex(followerID, targetID) >= 0 {
+		return domain.FollowRequestResponse{}, domain.ErrExists
+	}
+
+	if maxFollows > 0 && len(s.users[i].SubscribeUsers) >= maxFollows {
+		return domain.FollowRequestResponse{}, domain.ErrFollowLimit
+	}
+
+	s.lastFollowRequestID++
+	request := domain.FollowRequest{
+		ID:         s.lastFollowRequestID,
+		FollowerID: followerID,
+		TargetID:   targetID,
+		CreatedAt:  now,
+	}
+	s.followRequests = append(s.followRequests, request)
+
+	return domain.FollowRequestResponse{
+		ID:        request.ID,
+		User:      s.userSummary(targetID, followerID),
+		CreatedAt: request.CreatedAt,
+	}, nil
+}
+
+// GetFollowRequests returns the requests to the user when incoming is set
+// and the requests sent by them otherwise, the oldest first.
+func (s *Storage) GetFollowRequests(userID int, incoming bool) ([]domain.FollowRequestResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	requests := make([]domain.FollowRequestResponse, 0)
+	for _, r := range s.followRequests {
+		other := r.TargetID
+		if incoming {
+			other = r.FollowerID
+		}
+		if (incoming && r.TargetID != userID) || (!incoming && r.FollowerID != userID) {
+			continue
+		}
+
+		requests = append(requests, domain.FollowRequestResponse{
+			ID:        r.ID,
+			User:      s.userSummary(other, userID),
+			CreatedAt: r.CreatedAt,
+		})
+	}
+
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].ID < requests[j].ID
+	})
+
+	return requests, nil
+}
+
+// ApproveFollowRequest subscribes the follower to the target. The follow
+// limit of the follower is checked again, it could be reached while waiting.
+func (s *Storage) ApproveFollowRequest(targetID, id int, maxFollows int) (domain.FollowRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.followRequestByID(id)
+	if r < 0 || s.followRequests[r].TargetID != targetID {
+		return domain.FollowRequest{}, domain.ErrNotFound
+	}
+
+	request := s.followRequests[r]
+	i := s.indexByID(request.FollowerID)
+	if i < 0 {
+		return domain.FollowRequest{}, domain.ErrNotFound
+	}
+
+	if !contains(s.users[i].SubscribeUsers, targetID) {
+		if maxFollows > 0 && len(s.users[i].SubscribeUsers) >= maxFollows {
+			return domain.FollowRequest{}, domain.ErrFollowLimit
+		}
+
+		s.users[i].SubscribeUsers = append(s.users[i].SubscribeUsers, targetID)
+		s.users[i].TeamExceptions = removeID(s.users[i].TeamExceptions, targetID)
+		s.addFollower(targetID, request.FollowerID)
+	}
+
+	s.followRequests = append(s.followRequests[:r], s.followRequests[r+1:]...)
+
+	return request, nil
+}
+
+// DeleteFollowRequest rejects the request to the user when incoming is set
+// and cancels the request sent by them otherwise.
+func (s *Storage) DeleteFollowRequest(userID, id int, incoming bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.followRequestByID(id)
+	if r < 0 {
+		return domain.ErrNotFound
+	}
+
+	request := s.followRequests[r]
+	if (incoming && request.TargetID != userID) || (!incoming && request.FollowerID != userID) {
+		return domain.ErrNotFound
+	}
+
+	s.followRequests = append(s.followRequests[:r], s.followRequests[r+1:]...)
+
+	return nil
+}
+
+func (s *Storage) followRequestByID(id int) int {
+	for i := range s.followRequests {
+		if s.followRequests[i].ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (s *Storage) followRequestIndex(followerID, targetID int) int {
+	for i := range s.followRequests {
+		if s.followRequests[i].FollowerID == followerID && s.followRequests[i].TargetID == targetID {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// removeFollowRequests drops the requests between the two users in both
+// directions, or all requests of userID when otherID is 0.
+func (s *Storage) removeFollowRequests(userID, otherID int) {
+	requests := s.followRequests[:0]
+	for _, r := range s.followRequests {
+		involved := r.FollowerID == userID || r.TargetID == userID
+		if involved && (otherID == 0 || r.FollowerID == otherID || r.TargetID == otherID) {
+			continue
+		}
+		requests = append(requests, r)
+	}
+	s.followRequests = requests
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func TestInsertFollowRequest(t *testing.T) {
+	s := NewStorage()
+	anna := addUser(t, s, "anna@corp.ru")
+	boris := addUser(t, s, "boris@corp.ru")
+	vera := addUser(t, s, "vera@corp.ru")
+	if err := s.Block(vera.Email, anna.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		target  int
+		wantErr error
+	}{
+		{name: "blocked", target: vera.ID, wantErr: domain.ErrBlocked},
+		{name: "sent", target: boris.ID},
+		{name: "sent twice", target: boris.ID, wantErr: domain.ErrExists},
+	}
+	// the cases share the storage and run in order
+	for _, tt := range tests {
+		_, err := s.InsertFollowRequest(anna.ID, tt.target, 0, time.Now())
+		if !errors.Is(err, tt.wantErr) {
+			t.Fatalf("%s: InsertFollowRequest() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// a pending request doesn't subscribe, so it gives no reminders
+	if got := following(t, s, anna.ID); len(got) != 0 {
+		t.Errorf("following %v while the request is pending, want none", got)
+	}
+	upcoming, err := s.GetUpcomingBirthdays(anna.ID, 365)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range upcoming {
+		if u.IsSubscribed {
+			t.Errorf("GetUpcomingBirthdays() has a subscription %+v while the request is pending", u)
+		}
+	}
+}
+
+func TestApproveFollowRequest(t *testing.T) {
+	tests := []struct {
+		name          string
+		approver      int
+		maxFollows    int
+		wantErr       error
+		wantFollowing []int
+	}{
+		{name: "approved", approver: 2, wantFollowing: []int{2}},
+		{name: "not the target", approver: 1, wantErr: domain.ErrNotFound},
+		{name: "follow limit reached while waiting", approver: 2, maxFollows: 1, wantErr: domain.ErrFollowLimit, wantFollowing: []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStorage()
+			anna := addUser(t, s, "anna@corp.ru")
+			boris := addUser(t, s, "boris@corp.ru")
+			vera := addUser(t, s, "vera@corp.ru")
+			request, err := s.InsertFollowRequest(anna.ID, boris.ID, 0, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.maxFollows > 0 {
+				if err := s.Subscribe(anna.ID, vera.ID, 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err = s.ApproveFollowRequest(tt.approver, request.ID, tt.maxFollows)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ApproveFollowRequest() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := following(t, s, anna.ID); !slices.Equal(got, tt.wantFollowing) {
+				t.Errorf("following %v, want %v", got, tt.wantFollowing)
+			}
+
+			// the request is gone only when it was approved
+			pending, err := s.GetFollowRequests(boris.ID, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wantPending := tt.wantErr != nil; (len(pending) == 1) != wantPending {
+				t.Errorf("GetFollowRequests() = %+v, want pending: %v", pending, wantPending)
+			}
+		})
+	}
+}
+
+func TestFollowRequestRemoved(t *testing.T) {
+	tests := []struct {
+		name   string
+		remove func(s *Storage, anna, boris domain.User, id int) error
+	}{
+		{
+			name:   "rejected",
+			remove: func(s *Storage, _, boris domain.User, id int) error { return s.DeleteFollowRequest(boris.ID, id, true) },
+		},
+		{
+			name:   "cancelled",
+			remove: func(s *Storage, anna, _ domain.User, id int) error { return s.DeleteFollowRequest(anna.ID, id, false) },
+		},
+		{
+			name:   "the follower is blocked",
+			remove: func(s *Storage, anna, boris domain.User, _ int) error { return s.Block(boris.Email, anna.ID) },
+		},
+		{
+			name: "the follower left",
+			remove: func(s *Storage, anna, _ domain.User, _ int) error {
+				_, err := s.DeactivateUser(anna.ID)
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStorage()
+			anna := addUser(t, s, "anna@corp.ru")
+			boris := addUser(t, s, "boris@corp.ru")
+			request, err := s.InsertFollowRequest(anna.ID, boris.ID, 0, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.remove(s, anna, boris, request.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			incoming, err := s.GetFollowRequests(boris.ID, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			outgoing, err := s.GetFollowRequests(anna.ID, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(incoming) != 0 || len(outgoing) != 0 {
+				t.Errorf("GetFollowRequests() = %+v and %+v, want none", incoming, outgoing)
+			}
+		})
+	}
+}
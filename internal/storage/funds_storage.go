@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"sort"
+	"strings"
+	"time"
+)
+
+// InsertFund opens the fund and invites the followers of the recipient.
+// There is one fund for every birthday, so colleagues don't collect twice.
+func (s *Storage) InsertFund(fund domain.Fund) (domain.FundResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.indexByID(fund.OrganizerID) < 0 || s.indexByID(fund.RecipientID) < 0 {
+		return domain.FundResponse{}, domain.ErrNotExists
+	}
+
+	for _, f := range s.funds {
+		if f.RecipientID == fund.RecipientID && f.Birthday == fund.Birthday {
+			return domain.FundResponse{}, domain.ErrExists
+		}
+	}
+
+	s.lastFundID++
+	fund.ID = s.lastFundID
+	fund.Participants = []int{fund.OrganizerID}
+	fund.Participants, _ = s.inviteFollowers(fund)
+	s.funds = append(s.funds, fund)
+
+	return s.fundResponse(fund, fund.OrganizerID), nil
+}
+
+// GetFunds returns the funds the user organizes or is invited to.
+func (s *Storage) GetFunds(userID int) ([]domain.FundResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	funds := make([]domain.FundResponse, 0)
+	for _, f := range s.funds {
+		if fundVisible(f, userID) {
+			funds = append(funds, s.fundResponse(f, userID))
+		}
+	}
+
+	sort.Slice(funds, func(i, j int) bool {
+		if funds[i].Birthday != funds[j].Birthday {
+			return funds[i].Birthday < funds[j].Birthday
+		}
+		return funds[i].ID < funds[j].ID
+	})
+
+	return funds, nil
+}
+
+func (s *Storage) GetFund(userID, id int) (domain.FundResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.fundIndex(id)
+	if i < 0 || !fundVisible(s.funds[i], userID) {
+		return domain.FundResponse{}, domain.ErrNotFound
+	}
+
+	return s.fundResponse(s.funds[i], userID), nil
+}
+
+// InviteFollowers adds the users that started following the recipient after
+// the fund was opened and returns their IDs.
+func (s *Storage) InviteFollowers(userID, id int) (domain.FundResponse, []int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.fundIndex(id)
+	if i < 0 || !fundVisible(s.funds[i], userID) {
+		return domain.FundResponse{}, nil, domain.ErrNotFound
+	}
+
+	fund := &s.funds[i]
+	if fund.OrganizerID != userID {
+		return domain.FundResponse{}, nil, domain.ErrForbidden
+	}
+
+	var invited []int
+	fund.Participants, invited = s.inviteFollowers(*fund)
+
+	return s.fundResponse(*fund, userID), invited, nil
+}
+
+// SetPledge changes the pledge of the participant. Participants set their own
+// amount, the organizer can also mark the pledges of others paid.
+func (s *Storage) SetPledge(userID, id, participantID int, request domain.PledgeRequest, now time.Time) (domain.FundResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.fundIndex(id)
+	if i < 0 || !fundVisible(s.funds[i], userID) {
+		return domain.FundResponse{}, domain.ErrNotFound
+	}
+
+	fund := &s.funds[i]
+	if !contains(fund.Participants, participantID) {
+		return domain.FundResponse{}, domain.ErrNotFound
+	}
+	if userID != participantID && (fund.OrganizerID != userID || request.Amount != nil) {
+		return domain.FundResponse{}, domain.ErrForbidden
+	}
+
+	p := pledgeIndex(*fund, participantID)
+	if p < 0 {
+		fund.Pledges = append(fund.Pledges, domain.Pledge{UserID: participantID})
+		p = len(fund.Pledges) - 1
+	}
+
+	pledge := &fund.Pledges[p]
+	if request.Amount != nil {
+		pledge.Amount = *request.Amount
+	}
+	if request.Paid != nil && *request.Paid != pledge.Paid {
+		pledge.Paid = *request.Paid
+		pledge.PaidAt = time.Time{}
+		if pledge.Paid {
+			pledge.PaidAt = now
+		}
+	}
+	pledge.UpdatedAt = now
+
+	if pledge.Amount == 0 && !pledge.Paid {
+		fund.Pledges = append(fund.Pledges[:p], fund.Pledges[p+1:]...)
+	}
+
+	return s.fundResponse(*fund, userID), nil
+}
+
+func (s *Storage) DeleteFund(userID, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.fundIndex(id)
+	if i < 0 || !fundVisible(s.funds[i], userID) {
+		return domain.ErrNotFound
+	}
+
+	if s.funds[i].OrganizerID != userID {
+		return domain.ErrForbidden
+	}
+
+	s.funds = append(s.funds[:i], s.funds[i+1:]...)
+
+	return nil
+}
+
+// fundVisible keeps the fund a surprise: the recipient never sees it,
+// even when they are invited to some other way.
+func fundVisible(fund domain.Fund, userID int) bool {
+	if fund.RecipientID == userID {
+		return false
+	}
+
+	return fund.OrganizerID == userID || contains(fund.Participants, userID)
+}
+
+func (s *Storage) fundIndex(id int) int {
+	for i := range s.funds {
+		if s.funds[i].ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// inviteFollowers returns the participants with the active followers of the
+// recipient added, and the IDs of the added ones.
+func (s *Storage) inviteFollowers(fund domain.Fund) ([]int, []int) {
+	participants := fund.Participants
+	invited := make([]int, 0)
+	for _, id := range mapKeys(s.followers[fund.RecipientID]) {
+		i := s.indexByID(id)
+		if i < 0 || !s.users[i].Verified || s.users[i].Disabled || contains(participants, id) {
+			continue
+		}
+		participants = append(participants, id)
+		invited = append(invited, id)
+	}
+
+	return participants, invited
+}
+
+// removeFunds drops the funds for or organized by the user and their pledges
+// in the other funds.
+func (s *Storage) removeFunds(userID int) {
+	funds := s.funds[:0]
+	for _, f := range s.funds {
+		if f.RecipientID == userID || f.OrganizerID == userID {
+			continue
+		}
+		f.Participants = removeID(f.Participants, userID)
+		if p := pledgeIndex(f, userID); p >= 0 {
+			f.Pledges = append(f.Pledges[:p], f.Pledges[p+1:]...)
+		}
+		funds = append(funds, f)
+	}
+	s.funds = funds
+}
+
+func (s *Storage) fundResponse(fund domain.Fund, viewerID int) domain.FundResponse {
+	response := domain.FundResponse{
+		ID:           fund.ID,
+		Recipient:    s.userSummary(fund.RecipientID, viewerID),
+		Organizer:    s.userSummary(fund.OrganizerID, viewerID),
+		Title:        fund.Title,
+		Birthday:     fund.Birthday,
+		Deadline:     fund.Deadline,
+		Target:       fund.Target,
+		Participants: make([]domain.FundParticipantResponse, 0, len(fund.Participants)),
+		CreatedAt:    fund.CreatedAt,
+	}
+
+	for _, id := range fund.Participants {
+		participant := domain.FundParticipantResponse{User: s.userSummary(id, viewerID)}
+		if p := pledgeIndex(fund, id); p >= 0 {
+			pledge := fund.Pledges[p]
+			participant.Amount = pledge.Amount
+			participant.Paid = pledge.Paid
+			if pledge.Paid {
+				response.Paid += pledge.Amount
+				participant.PaidAt = &pledge.PaidAt
+			}
+			response.Pledged += pledge.Amount
+		}
+		response.Participants = append(response.Participants, participant)
+	}
+	response.Remaining = max(fund.Target-response.Paid, 0)
+
+	sort.Slice(response.Participants, func(i, j int) bool {
+		return strings.ToLower(response.Participants[i].User.Name) < strings.ToLower(response.Participants[j].User.Name)
+	})
+
+	return response
+}
+
+func pledgeIndex(fund domain.Fund, userID int) int {
+	for i := range fund.Pledges {
+		if fund.Pledges[i].UserID == userID {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (s *Storage) userSummary(id, viewerID int) domain.UserSummary {
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.UserSummary{ID: id}
+	}
+
+	return domain.UserSummary{
+		ID:    id,
+		Email: s.users[i].VisibleEmail(viewerID),
+		Name:  s.users[i].Name,
+	}
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+// fundStorage holds a fund for boris organized by anna, vera follows boris.
+func fundStorage(t *testing.T) (*Storage, domain.FundResponse, []domain.User) {
+	t.Helper()
+
+	s := NewStorage()
+	users := []domain.User{
+		addUser(t, s, "anna@corp.ru"),
+		addUser(t, s, "boris@corp.ru"),
+		addUser(t, s, "vera@corp.ru"),
+	}
+	if err := s.Subscribe(users[2].ID, users[1].ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	fund, err := s.InsertFund(domain.Fund{
+		RecipientID: users[1].ID,
+		OrganizerID: users[0].ID,
+		Title:       "Gift",
+		Birthday:    "2024-05-10",
+		Deadline:    "2024-05-09",
+		Target:      1000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, fund, users
+}
+
+func TestInsertFundInvitesFollowers(t *testing.T) {
+	s, fund, users := fundStorage(t)
+	anna, boris, vera := users[0], users[1], users[2]
+
+	participants := make([]int, 0)
+	for _, p := range fund.Participants {
+		participants = append(participants, p.User.ID)
+	}
+	slices.Sort(participants)
+	if !slices.Equal(participants, []int{anna.ID, vera.ID}) {
+		t.Errorf("participants %v, want the organizer and the follower", participants)
+	}
+
+	_, err := s.InsertFund(domain.Fund{RecipientID: boris.ID, OrganizerID: vera.ID, Birthday: "2024-05-10", Target: 500})
+	if err != domain.ErrExists {
+		t.Errorf("second InsertFund() for the birthday error = %v, want ErrExists", err)
+	}
+}
+
+func TestFundInvisibleToRecipient(t *testing.T) {
+	s, fund, users := fundStorage(t)
+	boris := users[1]
+
+	// the recipient is never a participant, even added by mistake
+	s.funds[0].Participants = append(s.funds[0].Participants, boris.ID)
+	amount := 100
+
+	funds, err := s.GetFunds(boris.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(funds) != 0 {
+		t.Errorf("GetFunds() = %+v, want none for the recipient", funds)
+	}
+	if _, err = s.GetFund(boris.ID, fund.ID); err != domain.ErrNotFound {
+		t.Errorf("GetFund() error = %v, want ErrNotFound", err)
+	}
+	if _, err = s.SetPledge(boris.ID, fund.ID, boris.ID, domain.PledgeRequest{Amount: &amount}, time.Now()); err != domain.ErrNotFound {
+		t.Errorf("SetPledge() error = %v, want ErrNotFound", err)
+	}
+	if _, _, err = s.InviteFollowers(boris.ID, fund.ID); err != domain.ErrNotFound {
+		t.Errorf("InviteFollowers() error = %v, want ErrNotFound", err)
+	}
+	if err = s.DeleteFund(boris.ID, fund.ID); err != domain.ErrNotFound {
+		t.Errorf("DeleteFund() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSetPledge(t *testing.T) {
+	amount := func(v int) *int { return &v }
+	paid := func(v bool) *bool { return &v }
+
+	tests := []struct {
+		name        string
+		by          int
+		participant int
+		request     domain.PledgeRequest
+		wantErr     error
+		wantPledged int
+		wantPaid    int
+	}{
+		{name: "own amount", by: 2, participant: 2, request: domain.PledgeRequest{Amount: amount(300)}, wantPledged: 300},
+		{name: "own payment", by: 2, participant: 2, request: domain.PledgeRequest{Amount: amount(300), Paid: paid(true)}, wantPledged: 300, wantPaid: 300},
+		{name: "organizer marks paid", by: 0, participant: 2, request: domain.PledgeRequest{Paid: paid(true)}},
+		{name: "organizer can't set amounts of others", by: 0, participant: 2, request: domain.PledgeRequest{Amount: amount(300)}, wantErr: domain.ErrForbidden},
+		{name: "participant can't mark others paid", by: 2, participant: 0, request: domain.PledgeRequest{Paid: paid(true)}, wantErr: domain.ErrForbidden},
+		{name: "not a participant", by: 2, participant: 1, request: domain.PledgeRequest{Amount: amount(300)}, wantErr: domain.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, fund, users := fundStorage(t)
+
+			got, err := s.SetPledge(users[tt.by].ID, fund.ID, users[tt.participant].ID, tt.request, time.Now())
+			if err != tt.wantErr {
+				t.Fatalf("SetPledge() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.Pledged != tt.wantPledged || got.Paid != tt.wantPaid || got.Remaining != fund.Target-tt.wantPaid) {
+				t.Errorf("SetPledge() = %+v, want %d pledged and %d paid", got, tt.wantPledged, tt.wantPaid)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"sort"
+	"time"
+)
+
+func (s *Storage) InsertGreeting(greeting domain.Greeting) (domain.GreetingResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.indexByID(greeting.AuthorID) < 0 || s.indexByID(greeting.RecipientID) < 0 {
+		return domain.GreetingResponse{}, domain.ErrNotExists
+	}
+
+	s.lastGreetingID++
+	greeting.ID = s.lastGreetingID
+	s.greetings = append(s.greetings, greeting)
+
+	return s.greetingResponse(greeting, greeting.AuthorID), nil
+}
+
+// GetGreetings returns the greetings of the recipient for one birthday,
+// or for all of them when birthday is empty, the newest first.
+func (s *Storage) GetGreetings(viewerID, recipientID int, birthday string) ([]domain.GreetingResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	greetings := make([]domain.GreetingResponse, 0)
+	for i := len(s.greetings) - 1; i >= 0; i-- {
+		g := s.greetings[i]
+		if g.RecipientID != recipientID || (birthday != "" && g.Birthday != birthday) {
+			continue
+		}
+		greetings = append(greetings, s.greetingResponse(g, viewerID))
+	}
+
+	sort.SliceStable(greetings, func(i, j int) bool {
+		return greetings[i].Birthday > greetings[j].Birthday
+	})
+
+	return greetings, nil
+}
+
+func (s *Storage) GetGreeting(id int) (domain.Greeting, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.greetingIndex(id)
+	if i < 0 {
+		return domain.Greeting{}, domain.ErrNotFound
+	}
+
+	return s.greetings[i], nil
+}
+
+func (s *Storage) UpdateGreeting(authorID, id int, request domain.GreetingUpdateRequest, now time.Time) (domain.GreetingResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.greetingIndex(id)
+	if i < 0 {
+		return domain.GreetingResponse{}, domain.ErrNotFound
+	}
+
+	greeting := &s.greetings[i]
+	if greeting.AuthorID != authorID {
+		return domain.GreetingResponse{}, domain.ErrForbidden
+	}
+
+	text, reactions := greeting.Text, greeting.Reactions
+	if request.Text != nil {
+		text = *request.Text
+	}
+	if request.Reactions != nil {
+		reactions = *request.Reactions
+	}
+	// a greeting can't be edited down to nothing, it is deleted instead
+	if text == "" && len(reactions) == 0 {
+		return domain.GreetingResponse{}, domain.ErrInvalidGreeting
+	}
+
+	greeting.Text = text
+	greeting.Reactions = reactions
+	greeting.UpdatedAt = now
+
+	return s.greetingResponse(*greeting, authorID), nil
+}
+
+func (s *Storage) DeleteGreeting(authorID, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.greetingIndex(id)
+	if i < 0 {
+		return domain.ErrNotFound
+	}
+
+	if s.greetings[i].AuthorID != authorID {
+		return domain.ErrForbidden
+	}
+
+	s.greetings = append(s.greetings[:i], s.greetings[i+1:]...)
+
+	return nil
+}
+
+func (s *Storage) greetingIndex(id int) int {
+	for i := range s.greetings {
+		if s.greetings[i].ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// removeGreetings drops the greetings written by or to the user.
+func (s *Storage) removeGreetings(userID int) {
+	greetings := s.greetings[:0]
+	for _, g := range s.greetings {
+		if g.AuthorID != userID && g.RecipientID != userID {
+			greetings = append(greetings, g)
+		}
+	}
+	s.greetings = greetings
+}
+
+func (s *Storage) greetingResponse(greeting domain.Greeting, viewerID int) domain.GreetingResponse {
+	response := domain.GreetingResponse{
+		ID:          greeting.ID,
+		RecipientID: greeting.RecipientID,
+		Author:      s.userSummary(greeting.AuthorID, viewerID),
+		Birthday:    greeting.Birthday,
+		Text:        greeting.Text,
+		Reactions:   greeting.Reactions,
+		CreatedAt:   greeting.CreatedAt,
+	}
+	if response.Reactions == nil {
+		response.Reactions = []string{}
+	}
+	if !greeting.UpdatedAt.IsZero() {
+		response.UpdatedAt = &greeting.UpdatedAt
+	}
+
+	return response
+}
@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"time"
+)
+
+// MaxNotifications is how many notifications are kept for every user,
+// the oldest ones are dropped.
+const MaxNotifications = 500
+
+// NotificationKeyTTL is how long the key of a sent notification is kept.
+// Reminders come at most a year before the birthday, so a key older than
+// that can't be repeated.
+const NotificationKeyTTL = 366 * 24 * time.Hour
+
+// InsertNotification adds the notification to the inbox, ErrExists means
+// the notification with the same key was already sent, even if the inbox
+// has dropped it since.
+func (s *Storage) InsertNotification(notification domain.Notification) (domain.NotificationResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.indexByID(notification.UserID) < 0 {
+		return domain.NotificationResponse{}, domain.ErrNotExists
+	}
+
+	if notification.Key != "" {
+		keys := s.notificationKeys[notification.UserID]
+		if keys == nil {
+			keys = make(map[string]time.Time)
+			s.notificationKeys[notification.UserID] = keys
+		}
+
+		for key, sentAt := range keys {
+			if notification.CreatedAt.Sub(sentAt) > NotificationKeyTTL {
+				delete(keys, key)
+			}
+		}
+
+		if _, ok := keys[notification.Key]; ok {
+			return domain.NotificationResponse{}, domain.ErrExists
+		}
+		keys[notification.Key] = notification.CreatedAt
+	}
+
+	inbox := s.notifications[notification.UserID]
+	if len(inbox) >= MaxNotifications {
+		inbox = inbox[:copy(inbox, inbox[len(inbox)-MaxNotifications+1:])]
+	}
+
+	s.lastNotificationID++
+	notification.ID = s.lastNotificationID
+	s.notifications[notification.UserID] = append(inbox, notification)
+
+	return notificationResponse(notification), nil
+}
+
+// GetNotifications returns a page of the inbox, the newest first.
+func (s *Storage) GetNotifications(userID int, query domain.NotificationQuery) (domain.NotificationListResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+
+	before := 0
+	if query.Cursor != "" {
+		after, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return domain.NotificationListResponse{}, err
+		}
+		before = after.ID
+	}
+
+	page := domain.NotificationListResponse{
+		Notifications: make([]domain.NotificationResponse, 0),
+	}
+	inbox := s.notifications[userID]
+	for i := len(inbox) - 1; i >= 0; i-- {
+		n := inbox[i]
+		if (before > 0 && n.ID >= before) || (query.Unread && !n.ReadAt.IsZero()) {
+			continue
+		}
+
+		if len(page.Notifications) == limit {
+			last := page.Notifications[limit-1]
+			page.NextCursor = cursor{ID: last.ID}.encode()
+			break
+		}
+		page.Notifications = append(page.Notifications, notificationResponse(n))
+	}
+
+	return page, nil
+}
+
+func (s *Storage) CountUnread(userID int) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, n := range s.notifications[userID] {
+		if n.ReadAt.IsZero() {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (s *Storage) MarkNotificationRead(userID, id int, now time.Time) (domain.NotificationResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inbox := s.notifications[userID]
+	for i := range inbox {
+		n := &inbox[i]
+		if n.ID != id {
+			continue
+		}
+
+		if n.ReadAt.IsZero() {
+			n.ReadAt = now
+		}
+		return notificationResponse(*n), nil
+	}
+
+	return domain.NotificationResponse{}, domain.ErrNotFound
+}
+
+// MarkAllNotificationsRead returns how many notifications were unread.
+func (s *Storage) MarkAllNotificationsRead(userID int, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	marked := 0
+	inbox := s.notifications[userID]
+	for i := range inbox {
+		n := &inbox[i]
+		if n.ReadAt.IsZero() {
+			n.ReadAt = now
+			marked++
+		}
+	}
+
+	return marked, nil
+}
+
+// GetDueReminders returns the birthdays the active users should be reminded
+// about: the ones of the users they follow that are at most DaysToNotification
+// days away. Hidden birthdays and pending follow requests give no reminders.
+func (s *Storage) GetDueReminders(now time.Time) ([]domain.Reminder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	reminders := make([]domain.Reminder, 0)
+	for _, user := range s.users {
+		if !user.Verified || user.Disabled {
+			continue
+		}
+
+		for _, id := range user.SubscribeUsers {
+			i := s.indexByID(id)
+			if i < 0 {
+				continue
+			}
+
+			target := s.users[i]
+			if !target.Verified || target.Disabled || !target.BirthdayVisible(user.ID) {
+				continue
+			}
+
+			days, err := domain.DaysUntilBirthday(target.DateOfBirth, now)
+			if err != nil || days > user.DaysToNotification {
+				continue
+			}
+
+			reminders = append(reminders, domain.Reminder{
+				UserID: user.ID,
+				Email:  user.Email,
+				Name:   user.Name,
+				Birthday: domain.UpcomingBirthdayResponse{
+					ID:           target.ID,
+					Email:        target.VisibleEmail(user.ID),
+					Name:         target.Name,
+					DateOfBirth:  target.VisibleDateOfBirth(user.ID),
+					DaysLeft:     days,
+					IsSubscribed: true,
+				},
+				Date:   today.AddDate(0, 0, days).Format(domain.DateLayout),
+				ChatID: user.BotChatID,
+			})
+		}
+	}
+
+	return reminders, nil
+}
+
+// removeNotifications drops the inbox of the user.
+func (s *Storage) removeNotifications(userID int) {
+	delete(s.notifications, userID)
+	delete(s.notificationKeys, userID)
+}
+
+func notificationResponse(notification domain.Notification) domain.NotificationResponse {
+	response := domain.NotificationResponse{
+		ID:        notification.ID,
+		Type:      notification.Type,
+		Payload:   notification.Payload,
+		CreatedAt: notification.CreatedAt,
+	}
+	if response.Payload == nil {
+		response.Payload = map[string]any{}
+	}
+	if !notification.ReadAt.IsZero() {
+		response.ReadAt = &notification.ReadAt
+	}
+
+	return response
+}
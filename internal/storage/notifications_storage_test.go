@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func TestInsertNotificationKeys(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		// fill is how many notifications without a key come after the first one.
+		fill    int
+		after   time.Duration
+		key     string
+		wantErr error
+	}{
+		{name: "same key", key: "birthday:2:2024-03-05", wantErr: domain.ErrExists},
+		{name: "other key", key: "birthday:3:2024-03-05"},
+		{name: "same key after the inbox dropped it", fill: MaxNotifications, key: "birthday:2:2024-03-05", wantErr: domain.ErrExists},
+		{name: "same key a year later", after: NotificationKeyTTL + time.Hour, key: "birthday:2:2024-03-05"},
+		{name: "no key", key: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStorage()
+			anna := addUser(t, s, "anna@corp.ru")
+
+			_, err := s.InsertNotification(domain.Notification{UserID: anna.ID, Key: "birthday:2:2024-03-05", CreatedAt: now})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for n := 0; n < tt.fill; n++ {
+				_, err = s.InsertNotification(domain.Notification{UserID: anna.ID, CreatedAt: now})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err = s.InsertNotification(domain.Notification{UserID: anna.ID, Key: tt.key, CreatedAt: now.Add(tt.after)})
+			if err != tt.wantErr {
+				t.Errorf("InsertNotification() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNotificationInboxes(t *testing.T) {
+	s := NewStorage()
+	anna := addUser(t, s, "anna@corp.ru")
+	boris := addUser(t, s, "boris@corp.ru")
+	now := time.Now()
+
+	for n := 0; n < MaxNotifications+10; n++ {
+		_, err := s.InsertNotification(domain.Notification{UserID: anna.ID, Type: domain.NotificationGreeting, CreatedAt: now})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the key of another user doesn't count
+	_, err := s.InsertNotification(domain.Notification{UserID: boris.ID, Key: "k", CreatedAt: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.InsertNotification(domain.Notification{UserID: anna.ID, Key: "k", CreatedAt: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		userID     int
+		wantUnread int
+	}{
+		{userID: anna.ID, wantUnread: MaxNotifications},
+		{userID: boris.ID, wantUnread: 1},
+		{userID: 100, wantUnread: 0},
+	}
+	for _, tt := range tests {
+		unread, err := s.CountUnread(tt.userID)
+		if err != nil || unread != tt.wantUnread {
+			t.Errorf("CountUnread(%d) = %d, %v, want %d", tt.userID, unread, err, tt.wantUnread)
+		}
+	}
+
+	page, err := s.GetNotifications(anna.ID, domain.NotificationQuery{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Notifications) != 2 || page.Notifications[0].ID <= page.Notifications[1].ID || page.NextCursor == "" {
+		t.Fatalf("GetNotifications() = %+v, want the two newest with a cursor", page)
+	}
+
+	next, err := s.GetNotifications(anna.ID, domain.NotificationQuery{Limit: 2, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next.Notifications) != 2 || next.Notifications[0].ID >= page.Notifications[1].ID {
+		t.Errorf("the next page %+v doesn't continue %+v", next, page)
+	}
+
+	_, err = s.MarkNotificationRead(boris.ID, page.Notifications[0].ID, now)
+	if err != domain.ErrNotFound {
+		t.Errorf("MarkNotificationRead() of another user error = %v, want ErrNotFound", err)
+	}
+
+	marked, err := s.MarkAllNotificationsRead(anna.ID, now)
+	if err != nil || marked != MaxNotifications {
+		t.Errorf("MarkAllNotificationsRead() = %d, %v, want %d", marked, err, MaxNotifications)
+	}
+	if unread, _ := s.CountUnread(boris.ID); unread != 1 {
+		t.Errorf("CountUnread() of another user = %d after marking, want 1", unread)
+	}
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"time"
+)
+
+func (s *Storage) SetPasswordReset(id int, tokenHash string, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	s.users[i].PasswordResetHash = tokenHash
+	s.users[i].PasswordResetExpires = expires
+
+	return nil
+}
+
+// ResetPassword sets the password of the user who owns the reset token
+// and revokes all of their sessions. The token can be used only once.
+func (s *Storage) ResetPassword(tokenHash string, passwordHash string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.users {
+		if tokenHash == "" || s.users[i].PasswordResetHash != tokenHash {
+			continue
+		}
+
+		expired := now.After(s.users[i].PasswordResetExpires)
+		s.users[i].PasswordResetHash = ""
+		s.users[i].PasswordResetExpires = time.Time{}
+		if expired {
+			return domain.ErrInvalidToken
+		}
+
+		s.users[i].Password = passwordHash
+		s.users[i].Sessions = nil
+
+		return nil
+	}
+
+	return domain.ErrInvalidToken
+}
+
+// ChangePassword sets a new password hash and cancels a pending reset.
+// If keepToken is not empty, every other session of the user is revoked.
+func (s *Storage) ChangePassword(id int, passwordHash string, keepToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	s.users[i].Password = passwordHash
+	// a reset link sent before the change can't override the new password
+	s.users[i].PasswordResetHash = ""
+	s.users[i].PasswordResetExpires = time.Time{}
+	if keepToken != "" {
+		kept := make([]domain.Session, 0, 1)
+		for _, session := range s.users[i].Sessions {
+			if session.Token == keepToken {
+				kept = append(kept, session)
+			}
+		}
+		s.users[i].Sessions = kept
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func TestPrivacyHidesBirthdayAndEmail(t *testing.T) {
+	s := NewStorage()
+	anna := addUser(t, s, "anna@corp.ru")
+	today := time.Now().Format(domain.DateLayout)
+	hidden := []struct {
+		email   string
+		name    string
+		privacy domain.Privacy
+	}{
+		{email: "boris@corp.ru", name: "Boris", privacy: domain.Privacy{HideBirthday: true}},
+		{email: "vera@corp.ru", name: "Vera", privacy: domain.Privacy{HideEmail: true}},
+		{email: "gleb@corp.ru", name: "Gleb", privacy: domain.Privacy{HideBirthYear: true}},
+	}
+	for _, h := range hidden {
+		user := addUser(t, s, h.email)
+		i := s.indexByID(user.ID)
+		s.users[i].Name = h.name
+		s.users[i].DateOfBirth = today
+		s.users[i].Privacy = h.privacy
+	}
+
+	// reminders
+	reminders, err := s.GetAllUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantReminders := []domain.UserInListResponse{
+		{Email: "", Name: "Vera"},
+		{Email: "gleb@corp.ru", Name: "Gleb"},
+	}
+	if !slices.Equal(reminders, wantReminders) {
+		t.Errorf("GetAllUsers() = %+v, want %+v", reminders, wantReminders)
+	}
+
+	// dashboard
+	upcoming, err := s.GetUpcomingBirthdays(anna.ID, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantUpcoming := []domain.UpcomingBirthdayResponse{
+		{ID: 3, Email: "", Name: "Vera", DateOfBirth: today},
+		{ID: 4, Email: "gleb@corp.ru", Name: "Gleb", DateOfBirth: "--" + today[5:]},
+	}
+	if !slices.Equal(upcoming, wantUpcoming) {
+		t.Errorf("GetUpcomingBirthdays() = %+v, want %+v", upcoming, wantUpcoming)
+	}
+
+	// lists: the hidden email isn't searchable and the hidden birthday goes last
+	found, err := s.GetUsersByToken(anna.Email, domain.ListQuery{Search: "vera@"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Users) != 0 {
+		t.Errorf("GetUsersByToken() by the hidden email = %+v, want none", found.Users)
+	}
+
+	list, err := s.GetUsersByToken(anna.Email, domain.ListQuery{Sort: domain.SortByBirthday})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(list.Users); !slices.Equal(got, []int{3, 4, 2}) {
+		t.Errorf("GetUsersByToken() by birthday = %v, want [3 4 2]", got)
+	}
+	if list.Users[0].Email != "" {
+		t.Errorf("GetUsersByToken() shows the hidden email %q", list.Users[0].Email)
+	}
+
+	// the user still sees their own data
+	vera, err := s.GetUserByID(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vera.VisibleEmail(vera.ID) != "vera@corp.ru" || vera.VisibleDateOfBirth(vera.ID) != today {
+		t.Errorf("the user doesn't see their own data: %+v", vera)
+	}
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func (s *Storage) GetUserBySubject(subject string) (domain.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if subject == "" {
+		return domain.User{}, domain.ErrNotFound
+	}
+
+	for _, user := range s.users {
+		if user.OIDCSubject == subject {
+			return cloneUser(user), nil
+		}
+	}
+
+	return domain.User{}, domain.ErrNotFound
+}
+
+// LinkSubject links the account to the user of the identity provider,
+// one subject may be linked to one account only.
+func (s *Storage) LinkSubject(id int, subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.ErrNotFound
+	}
+
+	for _, user := range s.users {
+		if user.OIDCSubject == subject && user.ID != id {
+			return domain.ErrExists
+		}
+	}
+
+	s.users[i].OIDCSubject = subject
+
+	return nil
+}
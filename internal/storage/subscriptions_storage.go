@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"sort"
+)
+
+// Subscribe follows the user. The block and the follow limit are checked
+// here under the lock, so concurrent requests can't get past them.
+func (s *Storage) Subscribe(followerID int, userId int, maxFollows int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(followerID)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	t := s.indexByID(userId)
+	if t < 0 {
+		return domain.ErrNotFound
+	}
+
+	if contains(s.users[t].BlockedUsers, followerID) {
+		return domain.ErrBlocked
+	}
+
+	if contains(s.users[i].SubscribeUsers, userId) {
+		return domain.ErrExists
+	}
+
+	if maxFollows > 0 && len(s.users[i].SubscribeUsers) >= maxFollows {
+		return domain.ErrFollowLimit
+	}
+
+	s.users[i].SubscribeUsers = append(s.users[i].SubscribeUsers, userId)
+	s.users[i].TeamExceptions = removeID(s.users[i].TeamExceptions, userId)
+	s.addFollower(userId, s.users[i].ID)
+
+	return nil
+}
+
+func (s *Storage) Unsubscribe(token string, userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByToken(token)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	return s.unsubscribe(i, userId)
+}
+
+func (s *Storage) GetFollowers(token string) ([]domain.FollowResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	currentUser, err := s.userByToken(token)
+	if err != nil {
+		return []domain.FollowResponse{}, err
+	}
+
+	return s.followList(currentUser.ID, mapKeys(s.followers[currentUser.ID]), func(user domain.User) bool {
+		return contains(currentUser.SubscribeUsers, user.ID)
+	}), nil
+}
+
+func (s *Storage) GetFollowing(token string) ([]domain.FollowResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	currentUser, err := s.userByToken(token)
+	if err != nil {
+		return []domain.FollowResponse{}, err
+	}
+
+	return s.followList(currentUser.ID, currentUser.SubscribeUsers, func(user domain.User) bool {
+		return contains(user.SubscribeUsers, currentUser.ID)
+	}), nil
+}
+
+func (s *Storage) GetBlocked(token string) ([]domain.FollowResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	currentUser, err := s.userByToken(token)
+	if err != nil {
+		return []domain.FollowResponse{}, err
+	}
+
+	return s.followList(currentUser.ID, currentUser.BlockedUsers, func(domain.User) bool {
+		return false
+	}), nil
+}
+
+func (s *Storage) RemoveFollower(token string, followerId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByToken(token)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	follower := s.indexByID(followerId)
+	if follower < 0 {
+		return domain.ErrNotFound
+	}
+
+	return s.unsubscribe(follower, s.users[i].ID)
+}
+
+func (s *Storage) Block(token string, userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByToken(token)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	blocked := s.indexByID(userId)
+	if blocked < 0 {
+		return domain.ErrNotFound
+	}
+
+	if contains(s.users[i].BlockedUsers, userId) {
+		return domain.ErrExists
+	}
+
+	s.users[i].BlockedUsers = append(s.users[i].BlockedUsers, userId)
+	// a blocked user can't stay subscribed or wait for an approval
+	_ = s.unsubscribe(blocked, s.users[i].ID)
+	s.removeFollowRequests(s.users[i].ID, userId)
+
+	return nil
+}
+
+func (s *Storage) Unblock(token string, userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByToken(token)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	for ind, id := range s.users[i].BlockedUsers {
+		if id == userId {
+			s.users[i].BlockedUsers = append(s.users[i].BlockedUsers[:ind], s.users[i].BlockedUsers[ind+1:]...)
+			return nil
+		}
+	}
+
+	return domain.ErrNotExists
+}
+
+// unsubscribe removes userId from the subscriptions of s.users[i], a member
+// of a subscribed team stays an exception so the team doesn't bring them back.
+// The caller must hold the write lock.
+func (s *Storage) unsubscribe(i int, userId int) error {
+	if !s.removeSubscription(i, userId) {
+		return domain.ErrNotExists
+	}
+
+	m := s.indexByID(userId)
+	if m >= 0 && s.coveredByTeams(i, m) && !contains(s.users[i].TeamExceptions, userId) {
+		s.users[i].TeamExceptions = append(s.users[i].TeamExceptions, userId)
+	}
+
+	return nil
+}
+
+// removeSubscription removes userId from the subscriptions of s.users[i]
+// and from the reverse index, the reservations of s.users[i] in the wishlist
+// of userId are released. The caller must hold the write lock.
+func (s *Storage) removeSubscription(i int, userId int) bool {
+	for ind, id := range s.users[i].SubscribeUsers {
+		if id == userId {
+			s.users[i].SubscribeUsers = append(s.users[i].SubscribeUsers[:ind], s.users[i].SubscribeUsers[ind+1:]...)
+			s.users[i].SubscribedByTeam = removeID(s.users[i].SubscribedByTeam, userId)
+			s.removeFollower(userId, s.users[i].ID)
+			if m := s.indexByID(userId); m >= 0 {
+				s.releaseReservationsIn(m, s.users[i].ID)
+			}
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Storage) addFollower(userId, followerId int) {
+	if s.followers[userId] == nil {
+		s.followers[userId] = make(map[int]struct{})
+	}
+	s.followers[userId][followerId] = struct{}{}
+}
+
+func (s *Storage) removeFollower(userId, followerId int) {
+	delete(s.followers[userId], followerId)
+	if len(s.followers[userId]) == 0 {
+		delete(s.followers, userId)
+	}
+}
+
+func (s *Storage) followList(viewerID int, ids []int, isMutual func(user domain.User) bool) []domain.FollowResponse {
+	users := make([]domain.FollowResponse, 0, len(ids))
+	for _, id := range ids {
+		i := s.indexByID(id)
+		if i < 0 {
+			continue
+		}
+
+		users = append(users, domain.FollowResponse{
+			ID:       s.users[i].ID,
+			Email:    s.users[i].VisibleEmail(viewerID),
+			Name:     s.users[i].Name,
+			IsMutual: isMutual(s.users[i]),
+		})
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	return users
+}
+
+func mapKeys(m map[int]struct{}) []int {
+	keys := make([]int, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func TestSubscribe(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, s *Storage, anna, boris domain.User)
+		// target is boris when zero.
+		target     int
+		maxFollows int
+		wantErr    error
+	}{
+		{name: "subscribes"},
+		{
+			name:    "unknown user",
+			target:  100,
+			wantErr: domain.ErrNotFound,
+		},
+		{
+			name: "blocked",
+			setup: func(t *testing.T, s *Storage, anna, boris domain.User) {
+				if err := s.Block(boris.Email, anna.ID); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: domain.ErrBlocked,
+		},
+		{
+			name: "already subscribed",
+			setup: func(t *testing.T, s *Storage, anna, boris domain.User) {
+				if err := s.Subscribe(anna.ID, boris.ID, 0); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: domain.ErrExists,
+		},
+		{
+			name: "follow limit",
+			setup: func(t *testing.T, s *Storage, anna, _ domain.User) {
+				vera := addUser(t, s, "vera@corp.ru")
+				if err := s.Subscribe(anna.ID, vera.ID, 0); err != nil {
+					t.Fatal(err)
+				}
+			},
+			maxFollows: 1,
+			wantErr:    domain.ErrFollowLimit,
+		},
+		{
+			name: "under the follow limit",
+			setup: func(t *testing.T, s *Storage, anna, _ domain.User) {
+				vera := addUser(t, s, "vera@corp.ru")
+				if err := s.Subscribe(anna.ID, vera.ID, 0); err != nil {
+					t.Fatal(err)
+				}
+			},
+			maxFollows: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStorage()
+			anna := addUser(t, s, "anna@corp.ru")
+			boris := addUser(t, s, "boris@corp.ru")
+			if tt.setup != nil {
+				tt.setup(t, s, anna, boris)
+			}
+			target := boris.ID
+			if tt.target != 0 {
+				target = tt.target
+			}
+
+			err := s.Subscribe(anna.ID, target, tt.maxFollows)
+			if err != tt.wantErr {
+				t.Fatalf("Subscribe() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if _, ok := s.followers[target][anna.ID]; !ok {
+				t.Error("the subscriber isn't among the followers")
+			}
+		})
+	}
+}
+
+func TestFollowLists(t *testing.T) {
+	s := NewStorage()
+	anna := addUser(t, s, "anna@corp.ru")
+	boris := addUser(t, s, "boris@corp.ru")
+	vera := addUser(t, s, "vera@corp.ru")
+	subscriptions := [][2]domain.User{{anna, boris}, {boris, anna}, {vera, anna}}
+	for _, sub := range subscriptions {
+		if err := s.Subscribe(sub[0].ID, sub[1].ID, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	followers, err := s.GetFollowers(anna.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []domain.FollowResponse{
+		{ID: boris.ID, Email: boris.Email, Name: boris.Name, IsMutual: true},
+		{ID: vera.ID, Email: vera.Email, Name: vera.Name},
+	}
+	if !slices.Equal(followers, want) {
+		t.Errorf("followers %+v, want %+v", followers, want)
+	}
+
+	following, err := s.GetFollowing(vera.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []domain.FollowResponse{{ID: anna.ID, Email: anna.Email, Name: anna.Name}}
+	if !slices.Equal(following, want) {
+		t.Errorf("following %+v, want %+v", following, want)
+	}
+}
+
+func TestEndFollow(t *testing.T) {
+	tests := []struct {
+		name string
+		// end is called by boris, who is followed by anna.
+		end     func(s *Storage, anna, boris domain.User) error
+		blocked bool
+	}{
+		{
+			name: "unsubscribe",
+			end: func(s *Storage, anna, boris domain.User) error {
+				return s.Unsubscribe(anna.Email, boris.ID)
+			},
+		},
+		{
+			name: "remove follower",
+			end: func(s *Storage, anna, boris domain.User) error {
+				return s.RemoveFollower(boris.Email, anna.ID)
+			},
+		},
+		{
+			name: "block",
+			end: func(s *Storage, anna, boris domain.User) error {
+				return s.Block(boris.Email, anna.ID)
+			},
+			blocked: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStorage()
+			anna := addUser(t, s, "anna@corp.ru")
+			boris := addUser(t, s, "boris@corp.ru")
+			if err := s.Subscribe(anna.ID, boris.ID, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.end(s, anna, boris); err != nil {
+				t.Fatal(err)
+			}
+
+			following, err := s.GetFollowing(anna.Email)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(following) != 0 || len(s.followers[boris.ID]) != 0 {
+				t.Errorf("anna still follows boris: %+v", following)
+			}
+
+			err = s.Subscribe(anna.ID, boris.ID, 0)
+			if tt.blocked && err != domain.ErrBlocked {
+				t.Errorf("Subscribe() after the block error = %v, want ErrBlocked", err)
+			}
+			if !tt.blocked && err != nil {
+				t.Errorf("Subscribe() again error = %v", err)
+			}
+		})
+	}
+}
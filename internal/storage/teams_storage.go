@@ -0,0 +1,254 @@
+package storage
+
+import (
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"sort"
+)
+
+func (s *Storage) GetTeams(token string) ([]domain.TeamResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	currentUser, err := s.userByToken(token)
+	if err != nil {
+		return []domain.TeamResponse{}, err
+	}
+
+	teams := make([]domain.TeamResponse, 0, len(s.teams))
+	for _, team := range s.teams {
+		teams = append(teams, domain.TeamResponse{
+			ID:           team.ID,
+			Name:         team.Name,
+			MembersCount: len(s.teamMembers(team)),
+			IsSubscribed: contains(currentUser.SubscribeTeams, team.ID),
+		})
+	}
+
+	sort.Slice(teams, func(i, j int) bool {
+		return teams[i].Name < teams[j].Name
+	})
+
+	return teams, nil
+}
+
+func (s *Storage) GetTeamMembers(token string, teamID int) ([]domain.ProfileResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	currentUser, err := s.userByToken(token)
+	if err != nil {
+		return []domain.ProfileResponse{}, err
+	}
+
+	t := s.teamIndexByID(teamID)
+	if t < 0 {
+		return []domain.ProfileResponse{}, domain.ErrNotFound
+	}
+
+	members := make([]domain.ProfileResponse, 0)
+	for _, m := range s.teamMembers(s.teams[t]) {
+		members = append(members, s.profile(currentUser, s.users[m]))
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].ID < members[j].ID
+	})
+
+	return members, nil
+}
+
+// SubscribeTeam follows the team members. The follow limit counts the members
+// the user would actually follow and is checked under the lock.
+func (s *Storage) SubscribeTeam(token string, teamID int, maxFollows int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByToken(token)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	t := s.teamIndexByID(teamID)
+	if t < 0 {
+		return domain.ErrNotFound
+	}
+
+	if contains(s.users[i].SubscribeTeams, teamID) {
+		return domain.ErrExists
+	}
+
+	members := s.teamMembers(s.teams[t])
+	if maxFollows > 0 {
+		follows := len(s.users[i].SubscribeUsers)
+		for _, m := range members {
+			if s.teamFollowable(i, m) {
+				follows++
+			}
+		}
+		if follows > maxFollows {
+			return domain.ErrFollowLimit
+		}
+	}
+
+	s.users[i].SubscribeTeams = append(s.users[i].SubscribeTeams, teamID)
+	for _, m := range members {
+		s.followTeamMember(i, m)
+	}
+
+	return nil
+}
+
+func (s *Storage) UnsubscribeTeam(token string, teamID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByToken(token)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	t := s.teamIndexByID(teamID)
+	if t < 0 || !contains(s.users[i].SubscribeTeams, teamID) {
+		return domain.ErrNotExists
+	}
+
+	s.users[i].SubscribeTeams = removeID(s.users[i].SubscribeTeams, teamID)
+	for _, m := range s.teamMembers(s.teams[t]) {
+		s.dropTeamMember(i, m)
+	}
+
+	return nil
+}
+
+// setDepartment moves s.users[i] to the team of the department, the team is
+// created for a new department. Subscriptions of the old and the new team
+// followers are updated. The caller must hold the write lock.
+func (s *Storage) setDepartment(i int, department string) {
+	if s.users[i].Department == department {
+		return
+	}
+
+	s.users[i].Department = department
+	if department != "" && s.teamIndexByName(department) < 0 {
+		s.lastTeamID++
+		s.teams = append(s.teams, domain.Team{ID: s.lastTeamID, Name: department})
+	}
+
+	s.refreshTeamFollowers(i)
+}
+
+// refreshTeamFollowers makes the team subscribers of s.users[i] follow them
+// or stop following. The caller must hold the write lock.
+func (s *Storage) refreshTeamFollowers(i int) {
+	for j := range s.users {
+		if len(s.users[j].SubscribeTeams) == 0 {
+			continue
+		}
+
+		if s.coveredByTeams(j, i) {
+			s.followTeamMember(j, i)
+		} else {
+			s.dropTeamMember(j, i)
+		}
+	}
+}
+
+// followTeams subscribes s.users[i] to the members of the teams they
+// subscribed to. The caller must hold the write lock.
+func (s *Storage) followTeams(i int) {
+	for _, teamID := range s.users[i].SubscribeTeams {
+		if t := s.teamIndexByID(teamID); t >= 0 {
+			for _, m := range s.teamMembers(s.teams[t]) {
+				s.followTeamMember(i, m)
+			}
+		}
+	}
+}
+
+// followTeamMember subscribes s.users[i] to the team member s.users[m]
+// unless they unsubscribed from them, are blocked or the follow policy
+// of the member doesn't let them.
+func (s *Storage) followTeamMember(i, m int) {
+	if !s.teamFollowable(i, m) {
+		return
+	}
+
+	subscriber, member := &s.users[i], s.users[m]
+	subscriber.SubscribeUsers = append(subscriber.SubscribeUsers, member.ID)
+	subscriber.SubscribedByTeam = append(subscriber.SubscribedByTeam, member.ID)
+	s.addFollower(member.ID, subscriber.ID)
+}
+
+// teamFollowable reports whether followTeamMember would subscribe s.users[i]
+// to s.users[m].
+func (s *Storage) teamFollowable(i, m int) bool {
+	subscriber, member := s.users[i], s.users[m]
+	return subscriber.ID != member.ID && !subscriber.Disabled && !member.Disabled && member.Verified &&
+		!contains(subscriber.SubscribeUsers, member.ID) &&
+		!contains(subscriber.TeamExceptions, member.ID) &&
+		!contains(member.BlockedUsers, subscriber.ID) &&
+		member.AcceptsFollower(subscriber)
+}
+
+// dropTeamMember removes the subscription of s.users[i] to s.users[m] when it
+// came from a team and no subscribed team has them anymore.
+func (s *Storage) dropTeamMember(i, m int) {
+	if s.coveredByTeams(i, m) {
+		return
+	}
+
+	memberID := s.users[m].ID
+	s.users[i].TeamExceptions = removeID(s.users[i].TeamExceptions, memberID)
+	if contains(s.users[i].SubscribedByTeam, memberID) {
+		s.removeSubscription(i, memberID)
+	}
+}
+
+// coveredByTeams reports whether s.users[m] is in a team s.users[i] subscribed to.
+func (s *Storage) coveredByTeams(i, m int) bool {
+	department := s.users[m].Department
+	if department == "" {
+		return false
+	}
+
+	for _, teamID := range s.users[i].SubscribeTeams {
+		t := s.teamIndexByID(teamID)
+		if t >= 0 && s.teams[t].Name == department {
+			return true
+		}
+	}
+
+	return false
+}
+
+// teamMembers returns the indexes of the active team members.
+func (s *Storage) teamMembers(team domain.Team) []int {
+	members := make([]int, 0)
+	for i := range s.users {
+		if s.users[i].Department == team.Name && !s.users[i].Disabled && s.users[i].Verified {
+			members = append(members, i)
+		}
+	}
+
+	return members
+}
+
+func (s *Storage) teamIndexByID(id int) int {
+	for i := range s.teams {
+		if s.teams[i].ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (s *Storage) teamIndexByName(name string) int {
+	for i := range s.teams {
+		if s.teams[i].Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
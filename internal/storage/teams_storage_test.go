@@ -0,0 +1,291 @@
+package storage
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+// addMember adds a verified user of the department, the email is also their
+// session token.
+func addMember(t *testing.T, s *Storage, email, department string) domain.User {
+	t.Helper()
+
+	user := addUser(t, s, email)
+	_, err := s.UpdateUser(user.ID, domain.AdminSettingsRequest{Department: &department})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err = s.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+func teamID(t *testing.T, s *Storage, name string) int {
+	t.Helper()
+
+	i := s.teamIndexByName(name)
+	if i < 0 {
+		t.Fatalf("no team %q", name)
+	}
+
+	return s.teams[i].ID
+}
+
+func following(t *testing.T, s *Storage, id int) []int {
+	t.Helper()
+
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(user.SubscribeUsers)
+
+	return user.SubscribeUsers
+}
+
+func TestSubscribeTeamFollowsMembers(t *testing.T) {
+	tests := []struct {
+		name string
+		// member changes the only other member of the team before the subscription.
+		member     func(s *Storage, i int, subscriber domain.User)
+		wantFollow bool
+	}{
+		{name: "active member", member: func(*Storage, int, domain.User) {}, wantFollow: true},
+		{name: "unverified member", member: func(s *Storage, i int, _ domain.User) { s.users[i].Verified = false }},
+		{name: "disabled member", member: func(s *Storage, i int, _ domain.User) { s.users[i].Disabled = true }},
+		{
+			name: "member blocked the subscriber",
+			member: func(s *Storage, i int, subscriber domain.User) {
+				s.users[i].BlockedUsers = append(s.users[i].BlockedUsers, subscriber.ID)
+			},
+		},
+		{
+			name:   "member approves followers",
+			member: func(s *Storage, i int, _ domain.User) { s.users[i].Privacy.FollowPolicy = domain.FollowApproved },
+		},
+		{
+			name:   "member accepts only their team",
+			member: func(s *Storage, i int, _ domain.User) { s.users[i].Privacy.FollowPolicy = domain.FollowTeam },
+		},
+		{
+			name: "member is an exception",
+			member: func(s *Storage, i int, subscriber domain.User) {
+				j := s.indexByID(subscriber.ID)
+				s.users[j].TeamExceptions = append(s.users[j].TeamExceptions, s.users[i].ID)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStorage()
+			subscriber := addMember(t, s, "anna@corp.ru", "Sales")
+			member := addMember(t, s, "boris@corp.ru", "Ops")
+			tt.member(s, s.indexByID(member.ID), subscriber)
+
+			err := s.SubscribeTeam(subscriber.Email, teamID(t, s, "Ops"), 0)
+			if err != nil {
+				t.Fatalf("SubscribeTeam() error = %v", err)
+			}
+
+			got := following(t, s, subscriber.ID)
+			if follows := slices.Contains(got, member.ID); follows != tt.wantFollow {
+				t.Errorf("following %v, want the member followed: %v", got, tt.wantFollow)
+			}
+		})
+	}
+}
+
+func TestSubscribeTeamSkipsSelf(t *testing.T) {
+	s := NewStorage()
+	anna := addMember(t, s, "anna@corp.ru", "Ops")
+	boris := addMember(t, s, "boris@corp.ru", "Ops")
+
+	err := s.SubscribeTeam(anna.Email, teamID(t, s, "Ops"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := following(t, s, anna.ID); !slices.Equal(got, []int{boris.ID}) {
+		t.Errorf("following %v, want only %d", got, boris.ID)
+	}
+}
+
+func TestSubscribeTeamLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxFollows int
+		// followed is how many of the three members are followed already.
+		followed int
+		wantErr  error
+	}{
+		{name: "no limit", maxFollows: 0},
+		{name: "fits", maxFollows: 3},
+		{name: "over the limit", maxFollows: 2, wantErr: domain.ErrFollowLimit},
+		{name: "followed members don't count twice", maxFollows: 3, followed: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStorage()
+			anna := addUser(t, s, "anna@corp.ru")
+			members := []domain.User{
+				addMember(t, s, "boris@corp.ru", "Ops"),
+				addMember(t, s, "vera@corp.ru", "Ops"),
+				addMember(t, s, "gleb@corp.ru", "Ops"),
+			}
+			for _, m := range members[:tt.followed] {
+				if err := s.Subscribe(anna.ID, m.ID, 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := s.SubscribeTeam(anna.Email, teamID(t, s, "Ops"), tt.maxFollows)
+			if err != tt.wantErr {
+				t.Fatalf("SubscribeTeam() error = %v, want %v", err, tt.wantErr)
+			}
+
+			want := len(members)
+			if tt.wantErr != nil {
+				want = tt.followed
+			}
+			if got := following(t, s, anna.ID); len(got) != want {
+				t.Errorf("following %v, want %d users", got, want)
+			}
+		})
+	}
+}
+
+func TestUnsubscribeTeamException(t *testing.T) {
+	s := NewStorage()
+	anna := addUser(t, s, "anna@corp.ru")
+	boris := addMember(t, s, "boris@corp.ru", "Ops")
+	vera := addMember(t, s, "vera@corp.ru", "Ops")
+	ops := teamID(t, s, "Ops")
+
+	steps := []struct {
+		name          string
+		do            func() error
+		wantFollowing []int
+		wantException []int
+	}{
+		{
+			name:          "subscribe to the team",
+			do:            func() error { return s.SubscribeTeam(anna.Email, ops, 0) },
+			wantFollowing: []int{boris.ID, vera.ID},
+		},
+		{
+			name:          "unsubscribe from a member",
+			do:            func() error { return s.Unsubscribe(anna.Email, boris.ID) },
+			wantFollowing: []int{vera.ID},
+			wantException: []int{boris.ID},
+		},
+		{
+			name: "the exception is re-enabled",
+			do: func() error {
+				_, err := s.SetDisabled(boris.ID, true)
+				if err != nil {
+					return err
+				}
+				_, err = s.SetDisabled(boris.ID, false)
+				return err
+			},
+			wantFollowing: []int{vera.ID},
+			wantException: []int{boris.ID},
+		},
+		{
+			name: "the exception moves to another team and back",
+			do: func() error {
+				_, err := s.UpdateUser(boris.ID, domain.AdminSettingsRequest{Department: ptr("Sales")})
+				if err != nil {
+					return err
+				}
+				_, err = s.UpdateUser(boris.ID, domain.AdminSettingsRequest{Department: ptr("Ops")})
+				return err
+			},
+			wantFollowing: []int{boris.ID, vera.ID},
+		},
+		{
+			name:          "unsubscribe from the member again",
+			do:            func() error { return s.Unsubscribe(anna.Email, boris.ID) },
+			wantFollowing: []int{vera.ID},
+			wantException: []int{boris.ID},
+		},
+		{
+			name:          "subscribe to the member directly",
+			do:            func() error { return s.Subscribe(anna.ID, boris.ID, 0) },
+			wantFollowing: []int{boris.ID, vera.ID},
+		},
+		{
+			name:          "unsubscribe from the team keeps the direct subscription",
+			do:            func() error { return s.UnsubscribeTeam(anna.Email, ops) },
+			wantFollowing: []int{boris.ID},
+		},
+	}
+	for _, step := range steps {
+		err := step.do()
+		if err != nil {
+			t.Fatalf("%s: error = %v", step.name, err)
+		}
+
+		user, err := s.GetUserByID(anna.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(user.SubscribeUsers)
+		if !slices.Equal(user.SubscribeUsers, step.wantFollowing) {
+			t.Fatalf("%s: following %v, want %v", step.name, user.SubscribeUsers, step.wantFollowing)
+		}
+		if !slices.Equal(user.TeamExceptions, step.wantException) {
+			t.Fatalf("%s: exceptions %v, want %v", step.name, user.TeamExceptions, step.wantException)
+		}
+	}
+}
+
+func TestTeamFollowersOnVerifyAndDisable(t *testing.T) {
+	s := NewStorage()
+	anna := addUser(t, s, "anna@corp.ru")
+	boris := addMember(t, s, "boris@corp.ru", "Ops")
+	ops := teamID(t, s, "Ops")
+	if err := s.SubscribeTeam(anna.Email, ops, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	vera, err := s.InsertUser(domain.RegisterRequest{Email: "vera@corp.ru", Name: "Vera"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.UpdateUser(vera.ID, domain.AdminSettingsRequest{Department: ptr("Ops")}); err != nil {
+		t.Fatal(err)
+	}
+	if got := following(t, s, anna.ID); !slices.Equal(got, []int{boris.ID}) {
+		t.Fatalf("following %v before the verification, want %v", got, []int{boris.ID})
+	}
+
+	if err = s.MarkVerified(vera.ID, vera.Email); err != nil {
+		t.Fatal(err)
+	}
+	if got := following(t, s, anna.ID); !slices.Equal(got, []int{boris.ID, vera.ID}) {
+		t.Fatalf("following %v after the verification, want %v", got, []int{boris.ID, vera.ID})
+	}
+
+	// a disabled subscriber gets the members of the new team after enabling
+	if _, err = s.SetDisabled(anna.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	gleb := addMember(t, s, "gleb@corp.ru", "Ops")
+	if _, err = s.SetDisabled(anna.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := following(t, s, anna.ID); !slices.Contains(got, gleb.ID) {
+		t.Errorf("following %v after enabling, want %d among them", got, gleb.ID)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
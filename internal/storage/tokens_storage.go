@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"time"
+)
+
+func (s *Storage) InsertPersonalToken(token domain.PersonalToken) (domain.PersonalToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.indexByID(token.UserID) < 0 {
+		return domain.PersonalToken{}, domain.ErrNotExists
+	}
+
+	s.lastTokenID++
+	token.ID = s.lastTokenID
+	s.tokens = append(s.tokens, token)
+
+	return token, nil
+}
+
+func (s *Storage) GetPersonalTokens(userID int) ([]domain.PersonalToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := make([]domain.PersonalToken, 0)
+	for _, t := range s.tokens {
+		if t.UserID == userID {
+			tokens = append(tokens, t)
+		}
+	}
+
+	return tokens, nil
+}
+
+// GetPersonalToken finds an active token by its hash and records the use.
+func (s *Storage) GetPersonalToken(hash string, now time.Time) (domain.PersonalToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.tokens {
+		if s.tokens[i].Hash == hash {
+			if s.tokens[i].IsExpired(now) {
+				return domain.PersonalToken{}, domain.ErrInvalidToken
+			}
+
+			s.tokens[i].LastUsedAt = now
+			return s.tokens[i], nil
+		}
+	}
+
+	return domain.PersonalToken{}, domain.ErrInvalidToken
+}
+
+func (s *Storage) DeletePersonalToken(userID, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.tokens {
+		if s.tokens[i].ID == id && s.tokens[i].UserID == userID {
+			s.tokens = append(s.tokens[:i], s.tokens[i+1:]...)
+			return nil
+		}
+	}
+
+	return domain.ErrNotFound
+}
+
+func (s *Storage) personalToken(hash string, now time.Time) (domain.PersonalToken, bool) {
+	for _, t := range s.tokens {
+		if t.Hash == hash {
+			return t, !t.IsExpired(now)
+		}
+	}
+
+	return domain.PersonalToken{}, false
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/krevetkou/test-rutube/internal/auth"
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func TestPersonalTokenLogin(t *testing.T) {
+	s := NewStorage()
+	anna := addUser(t, s, "anna@corp.ru")
+	boris := addUser(t, s, "boris@corp.ru")
+	now := time.Now()
+
+	tokens := []domain.PersonalToken{
+		{UserID: anna.ID, Hash: auth.HashToken("pat_active")},
+		{UserID: anna.ID, Hash: auth.HashToken("pat_expired"), ExpiresAt: now.Add(-time.Minute)},
+		{UserID: anna.ID, Hash: auth.HashToken("pat_deleted")},
+	}
+	for _, token := range tokens {
+		if _, err := s.InsertPersonalToken(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.DeletePersonalToken(boris.ID, 3); err != domain.ErrNotFound {
+		t.Errorf("DeletePersonalToken() of another user error = %v, want ErrNotFound", err)
+	}
+	if err := s.DeletePersonalToken(anna.ID, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "active token", token: "pat_active"},
+		{name: "expired token", token: "pat_expired", wantErr: domain.ErrNotExists},
+		{name: "deleted token", token: "pat_deleted", wantErr: domain.ErrNotExists},
+		{name: "unknown token", token: "pat_unknown", wantErr: domain.ErrNotExists},
+		// a personal token is looked up by its hash, never among the sessions
+		{name: "session with the prefix", token: "pat_session", wantErr: domain.ErrNotExists},
+	}
+	login(t, s, boris.ID, "pat_session")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := s.GetUserByToken(tt.token)
+			if err != tt.wantErr {
+				t.Fatalf("GetUserByToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && user.ID != anna.ID {
+				t.Errorf("GetUserByToken() = user %d, want %d", user.ID, anna.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func (s *Storage) SetPendingTOTP(id int, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	if s.users[i].TOTPSecret != "" {
+		return domain.ErrTwoFactorEnabled
+	}
+
+	s.users[i].TOTPPendingSecret = secret
+
+	return nil
+}
+
+func (s *Storage) EnableTOTP(id int, secret string, step int64, recoveryHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	if s.users[i].TOTPSecret != "" {
+		return domain.ErrTwoFactorEnabled
+	}
+
+	if s.users[i].TOTPPendingSecret == "" || s.users[i].TOTPPendingSecret != secret {
+		return domain.ErrInvalidCode
+	}
+
+	s.users[i].TOTPSecret = secret
+	s.users[i].TOTPPendingSecret = ""
+	s.users[i].TOTPLastStep = step
+	s.users[i].RecoveryCodes = recoveryHashes
+
+	return nil
+}
+
+func (s *Storage) DisableTOTP(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	s.users[i].TOTPSecret = ""
+	s.users[i].TOTPPendingSecret = ""
+	s.users[i].TOTPLastStep = 0
+	s.users[i].RecoveryCodes = nil
+
+	return nil
+}
+
+// UseTOTPStep accepts every time step only once, so an intercepted code can't be replayed.
+func (s *Storage) UseTOTPStep(id int, step int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	if step <= s.users[i].TOTPLastStep {
+		return domain.ErrInvalidCode
+	}
+
+	s.users[i].TOTPLastStep = step
+
+	return nil
+}
+
+func (s *Storage) UseRecoveryCode(id int, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	for ind, code := range s.users[i].RecoveryCodes {
+		if code == hash {
+			s.users[i].RecoveryCodes = append(s.users[i].RecoveryCodes[:ind], s.users[i].RecoveryCodes[ind+1:]...)
+			return nil
+		}
+	}
+
+	return domain.ErrInvalidCode
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func TestUseTOTPStep(t *testing.T) {
+	s := NewStorage()
+	anna := addUser(t, s, "anna@corp.ru")
+
+	tests := []struct {
+		name    string
+		userID  int
+		step    int64
+		wantErr error
+	}{
+		{name: "first code", userID: anna.ID, step: 100},
+		{name: "same step replayed", userID: anna.ID, step: 100, wantErr: domain.ErrInvalidCode},
+		{name: "previous step", userID: anna.ID, step: 99, wantErr: domain.ErrInvalidCode},
+		{name: "next step", userID: anna.ID, step: 101},
+		{name: "next step replayed", userID: anna.ID, step: 101, wantErr: domain.ErrInvalidCode},
+		{name: "unknown user", userID: 100, step: 200, wantErr: domain.ErrNotExists},
+	}
+	// the steps depend on each other, so the cases run in order
+	for _, tt := range tests {
+		err := s.UseTOTPStep(tt.userID, tt.step)
+		if err != tt.wantErr {
+			t.Fatalf("%s: UseTOTPStep(%d) error = %v, want %v", tt.name, tt.step, err, tt.wantErr)
+		}
+	}
+}
+
+func TestUseRecoveryCode(t *testing.T) {
+	s := NewStorage()
+	anna := addUser(t, s, "anna@corp.ru")
+	if err := s.SetPendingTOTP(anna.ID, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.EnableTOTP(anna.ID, "secret", 0, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		hash    string
+		wantErr error
+	}{
+		{name: "first code", hash: "a"},
+		{name: "used code", hash: "a", wantErr: domain.ErrInvalidCode},
+		{name: "unknown code", hash: "c", wantErr: domain.ErrInvalidCode},
+		{name: "second code", hash: "b"},
+	}
+	// the codes are spent, so the cases run in order
+	for _, tt := range tests {
+		err := s.UseRecoveryCode(anna.ID, tt.hash)
+		if err != tt.wantErr {
+			t.Fatalf("%s: UseRecoveryCode(%q) error = %v, want %v", tt.name, tt.hash, err, tt.wantErr)
+		}
+	}
+}
@@ -1,70 +1,120 @@
 package storage
 
 import (
-	"github.com/dgrijalva/jwt-go"
+	"github.com/krevetkou/test-rutube/internal/auth"
 	"github.com/krevetkou/test-rutube/internal/domain"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
-const DefaultDays = 2
-
-var jwtSecretKey = []byte("very-secret-key")
+const (
+	DefaultDays      = 2
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
 
 type Storage struct {
+	mu    sync.RWMutex
 	users []domain.User
+	// followers is the reverse index of User.SubscribeUsers:
+	// user ID -> IDs of the users subscribed to them.
+	followers map[int]map[int]struct{}
+	// lastUserID keeps IDs of deleted users from being given out again.
+	lastUserID int
+
+	tokens      []domain.PersonalToken
+	lastTokenID int
+
+	teams      []domain.Team
+	lastTeamID int
+
+	greetings      []domain.Greeting
+	lastGreetingID int
+
+	funds      []domain.Fund
+	lastFundID int
+
+	// lastWishItemID numbers the items of all wishlists.
+	lastWishItemID int
+
+	followRequests      []domain.FollowRequest
+	lastFollowRequestID int
+
+	// notifications are the inboxes by the user ID, the oldest first.
+	notifications map[int][]domain.Notification
+	// notificationKeys remember when the keyed notifications were sent by
+	// the user ID, the inbox drops old entries but the keys stay.
+	notificationKeys   map[int]map[string]time.Time
+	lastNotificationID int
 }
 
 func NewStorage() *Storage {
 	return &Storage{
-		users: make([]domain.User, 0),
+		users:     make([]domain.User, 0),
+		followers: make(map[int]map[int]struct{}),
+		tokens:    make([]domain.PersonalToken, 0),
+		teams:     make([]domain.Team, 0),
+		greetings: make([]domain.Greeting, 0),
+		funds:     make([]domain.Fund, 0),
+
+		followRequests:   make([]domain.FollowRequest, 0),
+		notifications:    make(map[int][]domain.Notification),
+		notificationKeys: make(map[int]map[string]time.Time),
 	}
 }
 
 func (s *Storage) InsertUser(userReg domain.RegisterRequest) (domain.User, error) {
-	var lastID int
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	ifExists := s.IsUserExists(userReg.Email)
-	if ifExists {
+	if s.indexByEmail(userReg.Email) >= 0 {
 		return domain.User{}, domain.ErrExists
 	}
 
-	if len(s.users) > 0 {
-		lastID = s.users[len(s.users)-1:][0].ID
-	}
+	s.lastUserID++
 
 	user := domain.User{
-		ID:                 lastID + 1,
+		ID:                 s.lastUserID,
 		Email:              userReg.Email,
 		Password:           userReg.Password,
 		Name:               userReg.Name,
 		DateOfBirth:        userReg.DateOfBirth,
 		DaysToNotification: DefaultDays,
+		Role:               domain.RoleUser,
+		Privacy:            domain.Privacy{FollowPolicy: domain.FollowEveryone},
 	}
 
 	s.users = append(s.users, user)
 
-	return user, nil
+	return cloneUser(user), nil
 }
 
 func (s *Storage) IsUserExists(email string) bool {
-	for i := range s.users {
-		if s.users[i].Email == email {
-			return true
-		}
-	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	return false
+	return s.indexByEmail(email) >= 0
 }
 
 func (s *Storage) GetAllUsers() ([]domain.UserInListResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	users := make([]domain.UserInListResponse, 0)
 
 	for i, val := range s.users {
+		if val.Disabled || !val.Verified || val.Privacy.HideBirthday {
+			continue
+		}
+
 		isToday := val.DateOfBirth == time.Now().Format("2006-01-02")
 
 		if isToday {
 			users = append(users, domain.UserInListResponse{
-				Email: s.users[i].Email,
+				Email: s.users[i].VisibleEmail(0),
 				Name:  s.users[i].Name,
 			})
 		}
@@ -73,151 +123,406 @@ func (s *Storage) GetAllUsers() ([]domain.UserInListResponse, error) {
 	return users, nil
 }
 
-func (s *Storage) GetUsersByToken(token string) ([]domain.ProfileResponse, error) {
-	users := make([]domain.ProfileResponse, 0)
-	currentUser, err := getUserByToken(s.users, token)
+func (s *Storage) GetUsersByToken(token string, query domain.ListQuery) (domain.ProfileListResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	currentUser, err := s.userByToken(token)
 	if err != nil {
-		return []domain.ProfileResponse{}, err
+		return domain.ProfileListResponse{}, err
 	}
 
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+
+	now := time.Now()
+	search := strings.ToLower(strings.TrimSpace(query.Search))
+	list := listHash(query.Sort, search, query.Subscribed)
+
+	var after cursor
+	if query.Cursor != "" {
+		after, err = decodeCursor(query.Cursor)
+		if err != nil {
+			return domain.ProfileListResponse{}, err
+		}
+		if after.List != list {
+			return domain.ProfileListResponse{}, domain.ErrInvalidCursor
+		}
+		if after.Date != "" {
+			now, err = time.Parse(domain.DateLayout, after.Date)
+			if err != nil {
+				return domain.ProfileListResponse{}, domain.ErrInvalidCursor
+			}
+		}
+	}
+
+	items := make([]listItem, 0)
 	for _, user := range s.users {
-		users = append(users, domain.ProfileResponse{
+		if user.ID == currentUser.ID || !user.Verified || user.Disabled {
+			continue
+		}
+
+		isSubscribed := contains(currentUser.SubscribeUsers, user.ID)
+		if query.Subscribed != nil && *query.Subscribed != isSubscribed {
+			continue
+		}
+		if search != "" &&
+			!strings.Contains(strings.ToLower(user.Name), search) &&
+			!strings.Contains(strings.ToLower(user.VisibleEmail(currentUser.ID)), search) {
+			continue
+		}
+
+		items = append(items, listItem{
+			cursor:  cursor{Key: sortKey(user, query.Sort, now), ID: user.ID},
+			profile: s.profile(currentUser, user),
+		})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].cursor.less(items[j].cursor)
+	})
+
+	start := 0
+	if query.Cursor != "" {
+		start = sort.Search(len(items), func(i int) bool {
+			return after.less(items[i].cursor)
+		})
+	}
+
+	end := min(start+limit, len(items))
+	page := domain.ProfileListResponse{
+		Users: make([]domain.ProfileResponse, 0, end-start),
+	}
+	for _, item := range items[start:end] {
+		page.Users = append(page.Users, item.profile)
+	}
+	if end < len(items) {
+		next := items[end-1].cursor
+		next.List = list
+		if query.Sort == domain.SortByBirthday {
+			next.Date = now.Format(domain.DateLayout)
+		}
+		page.NextCursor = next.encode()
+	}
+
+	return page, nil
+}
+
+func (s *Storage) GetUpcomingBirthdays(userID int, days int) ([]domain.UpcomingBirthdayResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.indexByID(userID)
+	if i < 0 {
+		return []domain.UpcomingBirthdayResponse{}, domain.ErrNotExists
+	}
+	currentUser := s.users[i]
+
+	now := time.Now()
+	users := make([]domain.UpcomingBirthdayResponse, 0)
+	for _, user := range s.users {
+		if user.ID == currentUser.ID || !user.Verified || user.Disabled || !user.BirthdayVisible(currentUser.ID) {
+			continue
+		}
+
+		daysLeft, err := domain.DaysUntilBirthday(user.DateOfBirth, now)
+		if err != nil || daysLeft > days {
+			continue
+		}
+
+		users = append(users, domain.UpcomingBirthdayResponse{
 			ID:           user.ID,
-			Email:        user.Email,
+			Email:        user.VisibleEmail(currentUser.ID),
 			Name:         user.Name,
+			DateOfBirth:  user.VisibleDateOfBirth(currentUser.ID),
+			DaysLeft:     daysLeft,
 			IsSubscribed: contains(currentUser.SubscribeUsers, user.ID),
 		})
 	}
 
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].DaysLeft != users[j].DaysLeft {
+			return users[i].DaysLeft < users[j].DaysLeft
+		}
+		return users[i].ID < users[j].ID
+	})
+
 	return users, nil
 }
 
-func (s *Storage) GetUserByEmail(email string) (domain.User, error) {
-	var user *domain.User
-	for i := range s.users {
-		if s.users[i].Email == email {
-			user = &s.users[i]
-		}
+func (s *Storage) GetProfile(token string, id int) (domain.ProfileResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	currentUser, err := s.userByToken(token)
+	if err != nil {
+		return domain.ProfileResponse{}, err
 	}
 
-	if user == nil {
-		return domain.User{}, domain.ErrNotFound
+	// the unverified and disabled users are hidden from everyone but themselves
+	i := s.indexByID(id)
+	if i < 0 || s.users[i].ID != currentUser.ID && (s.users[i].Disabled || !s.users[i].Verified) {
+		return domain.ProfileResponse{}, domain.ErrNotFound
 	}
 
-	return *user, nil
+	return s.profile(currentUser, s.users[i]), nil
 }
 
-func (s *Storage) CreateToken(email string) (string, error) {
-	payload := jwt.MapClaims{
-		"sub": email,
-		"exp": time.Now().Add(time.Hour * 72).Unix(),
+func (s *Storage) GetUserByID(id int) (domain.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.User{}, domain.ErrNotFound
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
+	return cloneUser(s.users[i]), nil
+}
 
-	t, err := token.SignedString(jwtSecretKey)
+func (s *Storage) GetUserByToken(token string) (domain.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, err := s.userByToken(token)
 	if err != nil {
-		return "", domain.ErrTokenNotCreated
+		return domain.User{}, err
 	}
 
-	for i := range s.users {
-		if s.users[i].Email == email {
-			s.users[i].Token = t
+	return cloneUser(user), nil
+}
+
+func (s *Storage) GetUserByEmail(email string) (domain.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.indexByEmail(email)
+	if i < 0 {
+		return domain.User{}, domain.ErrNotFound
+	}
+
+	return cloneUser(s.users[i]), nil
+}
+
+// AddSession saves the token of a new login. The expired sessions are
+// dropped, and the oldest ones when the user has more than limit.
+func (s *Storage) AddSession(id int, session domain.Session, limit int, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(id)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	sessions := make([]domain.Session, 0, len(s.users[i].Sessions)+1)
+	for _, existing := range s.users[i].Sessions {
+		if now.Before(existing.ExpiresAt) {
+			sessions = append(sessions, existing)
 		}
 	}
+	sessions = append(sessions, session)
+	if limit > 0 && len(sessions) > limit {
+		sessions = sessions[len(sessions)-limit:]
+	}
+	s.users[i].Sessions = sessions
 
-	return t, nil
+	return nil
 }
 
 func (s *Storage) GetUserInfo(token string) (domain.UserResponse, error) {
-	for _, user := range s.users {
-		if user.Token == token {
-			return domain.UserResponse{
-				Email:              user.Email,
-				Name:               user.Name,
-				DaysToNotification: user.DaysToNotification,
-			}, nil
-		}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.indexByToken(token)
+	if i < 0 {
+		return domain.UserResponse{}, domain.ErrNotFound
 	}
 
-	return domain.UserResponse{}, domain.ErrNotFound
+	return userResponse(s.users[i]), nil
 }
 
 func (s *Storage) IsTokenExists(token string) (bool, error) {
-	for i := range s.users {
-		if s.users[i].Token == token {
-			return true, nil
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.indexByToken(token) < 0 {
+		return false, domain.ErrNotExists
+	}
+
+	return true, nil
+}
+
+func (s *Storage) Settings(token string, settings domain.SettingsRequest) (domain.UserResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByToken(token)
+	if i < 0 {
+		return domain.UserResponse{}, domain.ErrNotExists
+	}
+
+	if settings.Email != nil && *settings.Email != s.users[i].Email {
+		if j := s.indexByEmail(*settings.Email); j >= 0 && j != i {
+			return domain.UserResponse{}, domain.ErrExists
 		}
 	}
 
-	return false, domain.ErrNotExists
+	user := &s.users[i]
+	if settings.DaysToNotification != nil {
+		user.DaysToNotification = *settings.DaysToNotification
+	}
+	if settings.Email != nil {
+		user.Email = *settings.Email
+	}
+	if settings.Name != nil {
+		user.Name = *settings.Name
+	}
+	if settings.DateOfBirth != nil {
+		user.DateOfBirth = *settings.DateOfBirth
+	}
+	if settings.Privacy != nil {
+		s.setPrivacy(i, *settings.Privacy)
+	}
+
+	return userResponse(*user), nil
 }
 
-func (s *Storage) Subscribe(token string, userId int) error {
-	ifExistsUser, err := s.IsTokenExists(token)
-	if !ifExistsUser {
-		return domain.ErrNotExists
+func (s *Storage) profile(currentUser, user domain.User) domain.ProfileResponse {
+	return domain.ProfileResponse{
+		ID:             user.ID,
+		Email:          user.VisibleEmail(currentUser.ID),
+		Name:           user.Name,
+		Department:     user.Department,
+		IsSubscribed:   contains(currentUser.SubscribeUsers, user.ID),
+		FollowersCount: len(s.followers[user.ID]),
+		FollowingCount: len(user.SubscribeUsers),
 	}
-	if err != nil {
-		return err
+}
+
+func userResponse(user domain.User) domain.UserResponse {
+	return domain.UserResponse{
+		Email:              user.Email,
+		PendingEmail:       user.PendingEmail,
+		Name:               user.Name,
+		DateOfBirth:        user.DateOfBirth,
+		DaysToNotification: user.DaysToNotification,
+		Verified:           user.Verified,
+		TwoFactorEnabled:   user.TOTPSecret != "",
+		Role:               user.Role,
+		Privacy:            user.Privacy,
 	}
+}
 
-	for i := range s.users {
-		if s.users[i].Token == token {
-			for _, id := range s.users[i].SubscribeUsers {
-				if id == userId {
-					return domain.ErrExists
-				}
-			}
-			s.users[i].SubscribeUsers = append(s.users[i].SubscribeUsers, userId)
-		}
+// setPrivacy changes the privacy settings of s.users[i]. Existing followers
+// stay when the follow policy becomes stricter, a looser policy lets the
+// team subscribers in. The caller must hold the write lock.
+func (s *Storage) setPrivacy(i int, request domain.PrivacyRequest) {
+	privacy := &s.users[i].Privacy
+	if request.HideBirthYear != nil {
+		privacy.HideBirthYear = *request.HideBirthYear
+	}
+	if request.HideBirthday != nil {
+		privacy.HideBirthday = *request.HideBirthday
+	}
+	if request.HideEmail != nil {
+		privacy.HideEmail = *request.HideEmail
+	}
+	if request.FollowPolicy != nil && *request.FollowPolicy != privacy.Policy() {
+		privacy.FollowPolicy = *request.FollowPolicy
+		s.refreshTeamFollowers(i)
 	}
-	return nil
 }
 
-func (s *Storage) Unsubscribe(token string, userId int) error {
-	ifExistsUser, err := s.IsTokenExists(token)
-	if !ifExistsUser {
-		return domain.ErrNotExists
+// cloneUser copies the slices of the user, so the callers can read the
+// returned user without the lock while the storage changes it.
+func cloneUser(user domain.User) domain.User {
+	user.PreviousEmails = slices.Clone(user.PreviousEmails)
+	user.Sessions = slices.Clone(user.Sessions)
+	user.SubscribeUsers = slices.Clone(user.SubscribeUsers)
+	user.BlockedUsers = slices.Clone(user.BlockedUsers)
+	user.SubscribeTeams = slices.Clone(user.SubscribeTeams)
+	user.SubscribedByTeam = slices.Clone(user.SubscribedByTeam)
+	user.TeamExceptions = slices.Clone(user.TeamExceptions)
+	user.RecoveryCodes = slices.Clone(user.RecoveryCodes)
+	user.Wishlist = slices.Clone(user.Wishlist)
+
+	return user
+}
+
+// userByToken returns the user sharing the slices with the storage, it is
+// for reading under the lock.
+func (s *Storage) userByToken(token string) (domain.User, error) {
+	i := s.indexByToken(token)
+	if i < 0 {
+		return domain.User{}, domain.ErrNotExists
 	}
-	if err != nil {
-		return err
+
+	return s.users[i], nil
+}
+
+func (s *Storage) indexByID(id int) int {
+	for i := range s.users {
+		if s.users[i].ID == id {
+			return i
+		}
 	}
 
+	return -1
+}
+
+func (s *Storage) indexByEmail(email string) int {
 	for i := range s.users {
-		if s.users[i].Token == token {
-			for ind, id := range s.users[i].SubscribeUsers {
-				if id == userId {
-					s.users[i].SubscribeUsers = append(s.users[i].SubscribeUsers[:ind], s.users[i].SubscribeUsers[ind+1:]...)
-					return nil
-				}
-			}
-			return domain.ErrNotExists
+		if strings.EqualFold(s.users[i].Email, email) {
+			return i
 		}
 	}
-	return nil
+
+	return -1
 }
 
-func (s *Storage) Settings(token string, daysToNotification int, email string) error {
-	ifExistsUser, err := s.IsTokenExists(token)
-	if !ifExistsUser {
-		return domain.ErrNotExists
+func (s *Storage) indexByToken(token string) int {
+	i := s.indexByCredential(token)
+	// tokens of disabled accounts stop working until they are enabled again
+	if i < 0 || s.users[i].Disabled {
+		return -1
 	}
-	if err != nil {
-		return err
+
+	return i
+}
+
+func (s *Storage) indexByCredential(token string) int {
+	// users that never logged in have an empty token
+	if token == "" {
+		return -1
 	}
 
-	for _, user := range s.users {
-		if user.Token == token {
-			user.DaysToNotification = daysToNotification
-			user.Email = email
+	if strings.HasPrefix(token, domain.PersonalTokenPrefix) {
+		t, ok := s.personalToken(auth.HashToken(token), time.Now())
+		if !ok {
+			return -1
 		}
+		return s.indexByID(t.UserID)
 	}
 
-	return nil
+	now := time.Now()
+	for i := range s.users {
+		for _, session := range s.users[i].Sessions {
+			if session.Token == token && now.Before(session.ExpiresAt) {
+				return i
+			}
+		}
+	}
+
+	return -1
 }
 
-func contains(s []int, e int) bool {
+func contains[T comparable](s []T, e T) bool {
 	for _, a := range s {
 		if a == e {
 			return true
@@ -225,13 +530,3 @@ func contains(s []int, e int) bool {
 	}
 	return false
 }
-
-func getUserByToken(users []domain.User, token string) (domain.User, error) {
-	for _, user := range users {
-		if user.Token == token {
-			return user, nil
-		}
-	}
-
-	return domain.User{}, domain.ErrNotExists
-}
@@ -0,0 +1,383 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+// listStorage holds anna, who is logged in with the token "anna", four
+// colleagues and an unverified user.
+func listStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	s := NewStorage()
+	users := []domain.RegisterRequest{
+		{Email: "anna@corp.ru", Name: "Anna", DateOfBirth: "1990-01-01"},
+		{Email: "boris@corp.ru", Name: "boris", DateOfBirth: "1990-02-01"},
+		{Email: "vera@corp.ru", Name: "Vera", DateOfBirth: "1990-03-01"},
+		{Email: "gleb@mail.ru", Name: "Gleb", DateOfBirth: "1990-04-01"},
+		{Email: "dina@corp.ru", Name: "Anna", DateOfBirth: "1990-05-01"},
+	}
+	for _, user := range users {
+		inserted, err := s.InsertUser(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = s.MarkVerified(inserted.ID, inserted.Email); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.InsertUser(domain.RegisterRequest{Email: "alex@corp.ru", Name: "Alex"}); err != nil {
+		t.Fatal(err)
+	}
+	login(t, s, 1, "anna")
+
+	return s
+}
+
+// addUser adds a verified user, the email is also their session token.
+func addUser(t *testing.T, s *Storage, email string) domain.User {
+	t.Helper()
+
+	user, err := s.InsertUser(domain.RegisterRequest{Email: email, Name: email, DateOfBirth: "1990-01-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.MarkVerified(user.ID, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	login(t, s, user.ID, email)
+
+	user, err = s.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+// login starts a session of the user for an hour.
+func login(t *testing.T, s *Storage, id int, token string) {
+	t.Helper()
+
+	now := time.Now()
+	if err := s.AddSession(id, domain.Session{Token: token, ExpiresAt: now.Add(time.Hour)}, 0, now); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ids(users []domain.ProfileResponse) []int {
+	result := make([]int, 0, len(users))
+	for _, user := range users {
+		result = append(result, user.ID)
+	}
+
+	return result
+}
+
+func TestGetUsersByTokenSearchAndSort(t *testing.T) {
+	tests := []struct {
+		name  string
+		query domain.ListQuery
+		want  []int
+	}{
+		{name: "by ID without yourself", want: []int{2, 3, 4, 5}},
+		{name: "by name", query: domain.ListQuery{Sort: domain.SortByName}, want: []int{5, 2, 4, 3}},
+		{name: "search in names", query: domain.ListQuery{Search: "ANNA"}, want: []int{5}},
+		{name: "search in emails", query: domain.ListQuery{Search: " mail.ru "}, want: []int{4}},
+		{name: "nothing found", query: domain.ListQuery{Search: "nobody"}, want: []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := listStorage(t).GetUsersByToken("anna", tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(page.Users); !slices.Equal(got, tt.want) {
+				t.Errorf("users %v, want %v", got, tt.want)
+			}
+			if page.NextCursor != "" {
+				t.Errorf("next cursor %q on the last page", page.NextCursor)
+			}
+		})
+	}
+}
+
+func TestGetUsersByTokenPages(t *testing.T) {
+	s := listStorage(t)
+	query := domain.ListQuery{Limit: 2, Sort: domain.SortByName}
+
+	got := make([]int, 0)
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("the pages don't end")
+		}
+
+		page, err := s.GetUsersByToken("anna", query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, ids(page.Users)...)
+
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+
+		// a new user sorted before the cursor doesn't shift the next page
+		if pages == 0 {
+			if _, err = s.InsertUser(domain.RegisterRequest{Email: "alla@corp.ru", Name: "Alla"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if want := []int{5, 2, 4, 3}; !slices.Equal(got, want) {
+		t.Errorf("users %v, want %v", got, want)
+	}
+}
+
+func TestGetUsersByTokenBirthdayPages(t *testing.T) {
+	s := listStorage(t)
+	vera, err := s.GetUserByID(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first page was taken on 15 February: Vera, Gleb, Dina, then Boris
+	day := time.Date(2024, time.February, 15, 0, 0, 0, 0, time.UTC)
+	after := cursor{
+		Key:  sortKey(vera, domain.SortByBirthday, day),
+		ID:   vera.ID,
+		List: listHash(domain.SortByBirthday, "", nil),
+		Date: day.Format(domain.DateLayout),
+	}
+
+	page, err := s.GetUsersByToken("anna", domain.ListQuery{Sort: domain.SortByBirthday, Cursor: after.encode()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(page.Users), []int{4, 5, 2}; !slices.Equal(got, want) {
+		t.Errorf("users %v, want %v", got, want)
+	}
+}
+
+func TestGetUsersByTokenErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		query   domain.ListQuery
+		wantErr error
+	}{
+		{name: "unknown token", token: "nobody", wantErr: domain.ErrNotExists},
+		{name: "invalid cursor", token: "anna", query: domain.ListQuery{Cursor: "***"}, wantErr: domain.ErrInvalidCursor},
+		{
+			name:    "cursor of another sort",
+			token:   "anna",
+			query:   domain.ListQuery{Sort: domain.SortByName, Cursor: cursor{ID: 2, List: listHash("", "", nil)}.encode()},
+			wantErr: domain.ErrInvalidCursor,
+		},
+		{
+			name:    "cursor of another search",
+			token:   "anna",
+			query:   domain.ListQuery{Search: "corp", Cursor: cursor{ID: 2, List: listHash("", "", nil)}.encode()},
+			wantErr: domain.ErrInvalidCursor,
+		},
+		{
+			name:    "invalid cursor date",
+			token:   "anna",
+			query:   domain.ListQuery{Cursor: cursor{ID: 2, List: listHash("", "", nil), Date: "tomorrow"}.encode()},
+			wantErr: domain.ErrInvalidCursor,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := listStorage(t).GetUsersByToken(tt.token, tt.query)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetUsersByToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGettersCopyUsers(t *testing.T) {
+	s := NewStorage()
+	anna := addUser(t, s, "anna@corp.ru")
+	boris := addUser(t, s, "boris@corp.ru")
+	if err := s.Subscribe(anna.ID, boris.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Block(anna.Email, boris.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPendingTOTP(anna.ID, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.EnableTOTP(anna.ID, "secret", 0, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	getters := []struct {
+		name string
+		get  func() (domain.User, error)
+	}{
+		{name: "GetUserByID", get: func() (domain.User, error) { return s.GetUserByID(anna.ID) }},
+		{name: "GetUserByEmail", get: func() (domain.User, error) { return s.GetUserByEmail(anna.Email) }},
+		{name: "GetUserByToken", get: func() (domain.User, error) { return s.GetUserByToken(anna.Email) }},
+	}
+	for _, g := range getters {
+		t.Run(g.name, func(t *testing.T) {
+			user, err := g.get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			user.SubscribeUsers[0] = 100
+			user.BlockedUsers[0] = 100
+			user.Sessions[0].Token = "stolen"
+			user.RecoveryCodes[0] = "stolen"
+
+			stored := s.users[s.indexByID(anna.ID)]
+			if stored.SubscribeUsers[0] != boris.ID || stored.BlockedUsers[0] != boris.ID ||
+				stored.Sessions[0].Token != anna.Email || stored.RecoveryCodes[0] != "a" {
+				t.Error("changing the returned user changed the storage")
+			}
+		})
+	}
+}
+
+func TestAddSession(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	session := func(token string, expiresIn time.Duration) domain.Session {
+		return domain.Session{Token: token, ExpiresAt: now.Add(expiresIn)}
+	}
+
+	tests := []struct {
+		name     string
+		existing []domain.Session
+		limit    int
+		want     []string
+	}{
+		{name: "first session", limit: 3, want: []string{"new"}},
+		{
+			name:     "keeps the live sessions",
+			existing: []domain.Session{session("a", time.Hour), session("b", time.Hour)},
+			limit:    3,
+			want:     []string{"a", "b", "new"},
+		},
+		{
+			name:     "drops the expired sessions",
+			existing: []domain.Session{session("a", -time.Hour), session("b", time.Hour), session("c", 0)},
+			limit:    3,
+			want:     []string{"b", "new"},
+		},
+		{
+			name:     "evicts the oldest over the limit",
+			existing: []domain.Session{session("a", time.Hour), session("b", time.Hour), session("c", time.Hour)},
+			limit:    3,
+			want:     []string{"b", "c", "new"},
+		},
+		{
+			name:     "no limit",
+			existing: []domain.Session{session("a", time.Hour), session("b", time.Hour), session("c", time.Hour)},
+			want:     []string{"a", "b", "c", "new"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStorage()
+			anna := addUser(t, s, "anna@corp.ru")
+			i := s.indexByID(anna.ID)
+			s.users[i].Sessions = tt.existing
+
+			err := s.AddSession(anna.ID, session("new", time.Hour), tt.limit, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]string, 0)
+			for _, session := range s.users[i].Sessions {
+				got = append(got, session.Token)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("sessions %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetProfileHidesInactiveUsers(t *testing.T) {
+	s := NewStorage()
+	anna := addUser(t, s, "anna@corp.ru")
+	vera, err := s.InsertUser(domain.RegisterRequest{Email: "vera@corp.ru", Name: "Vera"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	login(t, s, vera.ID, vera.Email)
+	gleb := addUser(t, s, "gleb@corp.ru")
+	if _, err = s.SetDisabled(gleb.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		id      int
+		wantErr error
+	}{
+		{name: "own profile", token: anna.Email, id: anna.ID},
+		{name: "unverified user", token: anna.Email, id: vera.ID, wantErr: domain.ErrNotFound},
+		{name: "own unverified profile", token: vera.Email, id: vera.ID},
+		{name: "disabled user", token: anna.Email, id: gleb.ID, wantErr: domain.ErrNotFound},
+		{name: "session of a disabled user", token: gleb.Email, id: anna.ID, wantErr: domain.ErrNotExists},
+		{name: "unknown user", token: anna.Email, id: 100, wantErr: domain.ErrNotFound},
+		{name: "unknown token", token: "nobody", id: anna.ID, wantErr: domain.ErrNotExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.GetProfile(tt.token, tt.id)
+			if err != tt.wantErr {
+				t.Errorf("GetProfile() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetUpcomingBirthdays(t *testing.T) {
+	s := NewStorage()
+	anna := addUser(t, s, "anna@corp.ru")
+	// the year is a leap one, so a birthday on February 29 parses
+	birthday := func(in int) string { return "1992-" + time.Now().AddDate(0, 0, in).Format("01-02") }
+	users := []struct {
+		email, birthday string
+	}{
+		{email: "boris@corp.ru", birthday: birthday(3)},
+		{email: "vera@corp.ru", birthday: birthday(0)},
+		{email: "gleb@corp.ru", birthday: birthday(10)},
+	}
+	for _, u := range users {
+		user := addUser(t, s, u.email)
+		s.users[s.indexByID(user.ID)].DateOfBirth = u.birthday
+	}
+	s.users[s.indexByID(anna.ID)].DateOfBirth = birthday(1)
+	if _, err := s.InsertUser(domain.RegisterRequest{Email: "dina@corp.ru", Name: "Dina", DateOfBirth: birthday(1)}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetUpcomingBirthdays(anna.ID, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// without yourself, unverified users and birthdays after the window
+	want := []domain.UpcomingBirthdayResponse{
+		{ID: 3, Email: "vera@corp.ru", Name: "vera@corp.ru", DateOfBirth: birthday(0), DaysLeft: 0},
+		{ID: 2, Email: "boris@corp.ru", Name: "boris@corp.ru", DateOfBirth: birthday(3), DaysLeft: 3},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("GetUpcomingBirthdays() = %+v, want %+v", got, want)
+	}
+}
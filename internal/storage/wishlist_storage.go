@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"sort"
+	"time"
+)
+
+func (s *Storage) GetWishlist(ownerID int) ([]domain.WishItemResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.indexByID(ownerID)
+	if i < 0 {
+		return []domain.WishItemResponse{}, domain.ErrNotExists
+	}
+
+	return wishlist(s.users[i].Wishlist, 0), nil
+}
+
+// GetFollowerWishlist returns the wishlist of the owner as the follower sees it.
+func (s *Storage) GetFollowerWishlist(viewerID, ownerID int) ([]domain.WishItemResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i, err := s.followedWishlist(viewerID, ownerID)
+	if err != nil {
+		return []domain.WishItemResponse{}, err
+	}
+
+	return wishlist(s.users[i].Wishlist, viewerID), nil
+}
+
+func (s *Storage) AddWishItem(ownerID int, item domain.WishItem, limit int) (domain.WishItemResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(ownerID)
+	if i < 0 {
+		return domain.WishItemResponse{}, domain.ErrNotExists
+	}
+
+	if limit > 0 && len(s.users[i].Wishlist) >= limit {
+		return domain.WishItemResponse{}, domain.ErrWishlistFull
+	}
+
+	s.lastWishItemID++
+	item.ID = s.lastWishItemID
+	s.users[i].Wishlist = append(s.users[i].Wishlist, item)
+
+	return wishItemResponse(item, 0), nil
+}
+
+// UpdateWishItem keeps the reservation: the follower has already agreed to buy the gift.
+func (s *Storage) UpdateWishItem(ownerID, id int, request domain.WishItemUpdateRequest) (domain.WishItemResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(ownerID)
+	if i < 0 {
+		return domain.WishItemResponse{}, domain.ErrNotExists
+	}
+
+	w := wishItemIndex(s.users[i].Wishlist, id)
+	if w < 0 {
+		return domain.WishItemResponse{}, domain.ErrNotFound
+	}
+
+	item := s.users[i].Wishlist[w]
+	if request.Title != nil {
+		item.Title = *request.Title
+	}
+	if request.Link != nil {
+		item.Link = *request.Link
+	}
+	if request.PriceMin != nil {
+		item.PriceMin = *request.PriceMin
+	}
+	if request.PriceMax != nil {
+		item.PriceMax = *request.PriceMax
+	}
+	if request.Priority != nil {
+		item.Priority = *request.Priority
+	}
+	if item.PriceMax > 0 && item.PriceMin > item.PriceMax {
+		return domain.WishItemResponse{}, domain.ErrInvalidWishItem
+	}
+
+	s.users[i].Wishlist[w] = item
+
+	return wishItemResponse(item, 0), nil
+}
+
+func (s *Storage) DeleteWishItem(ownerID, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexByID(ownerID)
+	if i < 0 {
+		return domain.ErrNotExists
+	}
+
+	w := wishItemIndex(s.users[i].Wishlist, id)
+	if w < 0 {
+		return domain.ErrNotFound
+	}
+
+	s.users[i].Wishlist = append(s.users[i].Wishlist[:w], s.users[i].Wishlist[w+1:]...)
+
+	return nil
+}
+
+func (s *Storage) ReserveWishItem(viewerID, ownerID, id int, now time.Time) (domain.WishItemResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.followedWishlist(viewerID, ownerID)
+	if err != nil {
+		return domain.WishItemResponse{}, err
+	}
+
+	w := wishItemIndex(s.users[i].Wishlist, id)
+	if w < 0 {
+		return domain.WishItemResponse{}, domain.ErrNotFound
+	}
+
+	item := &s.users[i].Wishlist[w]
+	if item.ReservedBy != 0 && item.ReservedBy != viewerID {
+		return domain.WishItemResponse{}, domain.ErrExists
+	}
+
+	item.ReservedBy = viewerID
+	item.ReservedAt = now
+
+	return wishItemResponse(*item, viewerID), nil
+}
+
+// ReleaseWishItem cancels a reservation, only the follower who made it can do it.
+func (s *Storage) ReleaseWishItem(viewerID, ownerID, id int) (domain.WishItemResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.followedWishlist(viewerID, ownerID)
+	if err != nil {
+		return domain.WishItemResponse{}, err
+	}
+
+	w := wishItemIndex(s.users[i].Wishlist, id)
+	if w < 0 || s.users[i].Wishlist[w].ReservedBy != viewerID {
+		return domain.WishItemResponse{}, domain.ErrNotFound
+	}
+
+	item := &s.users[i].Wishlist[w]
+	item.ReservedBy = 0
+	item.ReservedAt = time.Time{}
+
+	return wishItemResponse(*item, viewerID), nil
+}
+
+// followedWishlist returns the index of the owner when the viewer can see
+// their wishlist: the viewer follows the owner and isn't blocked by them.
+func (s *Storage) followedWishlist(viewerID, ownerID int) (int, error) {
+	viewer := s.indexByID(viewerID)
+	if viewer < 0 {
+		return -1, domain.ErrNotExists
+	}
+
+	i := s.indexByID(ownerID)
+	if i < 0 || !s.users[i].Verified || s.users[i].Disabled {
+		return -1, domain.ErrNotFound
+	}
+
+	if !contains(s.users[viewer].SubscribeUsers, ownerID) || contains(s.users[i].BlockedUsers, viewerID) {
+		return -1, domain.ErrNotFollowing
+	}
+
+	return i, nil
+}
+
+// releaseReservations cancels the reservations of the user in all wishlists.
+func (s *Storage) releaseReservations(userID int) {
+	for i := range s.users {
+		s.releaseReservationsIn(i, userID)
+	}
+}
+
+// releaseReservationsIn cancels the reservations of the user in the wishlist
+// of s.users[i]. A follower who stops following can't see the wishlist, so
+// their reservations would otherwise hold the items forever.
+func (s *Storage) releaseReservationsIn(i int, userID int) {
+	for w := range s.users[i].Wishlist {
+		if s.users[i].Wishlist[w].ReservedBy == userID {
+			s.users[i].Wishlist[w].ReservedBy = 0
+			s.users[i].Wishlist[w].ReservedAt = time.Time{}
+		}
+	}
+}
+
+func wishItemIndex(items []domain.WishItem, id int) int {
+	for i := range items {
+		if items[i].ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// wishlist builds the responses for the viewer, zero viewerID is the owner.
+func wishlist(items []domain.WishItem, viewerID int) []domain.WishItemResponse {
+	result := make([]domain.WishItemResponse, 0, len(items))
+	for _, item := range items {
+		result = append(result, wishItemResponse(item, viewerID))
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Priority.Rank() < result[j].Priority.Rank()
+	})
+
+	return result
+}
+
+func wishItemResponse(item domain.WishItem, viewerID int) domain.WishItemResponse {
+	response := domain.WishItemResponse{
+		ID:        item.ID,
+		Title:     item.Title,
+		Link:      item.Link,
+		PriceMin:  item.PriceMin,
+		PriceMax:  item.PriceMax,
+		Priority:  item.Priority,
+		CreatedAt: item.CreatedAt,
+	}
+	if viewerID != 0 {
+		response.Reserved = item.ReservedBy != 0
+		response.ReservedByMe = item.ReservedBy == viewerID
+	}
+
+	return response
+}
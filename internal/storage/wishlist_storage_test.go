@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krevetkou/test-rutube/internal/domain"
+)
+
+func TestReserveWishItem(t *testing.T) {
+	s := NewStorage()
+	anna := addUser(t, s, "anna@corp.ru")
+	boris := addUser(t, s, "boris@corp.ru")
+	vera := addUser(t, s, "vera@corp.ru")
+	stranger := addUser(t, s, "gleb@corp.ru")
+	for _, follower := range []domain.User{boris, vera} {
+		if err := s.Subscribe(follower.ID, anna.ID, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	item, err := s.AddWishItem(anna.ID, domain.WishItem{Title: "Book", Priority: domain.PriorityHigh}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		viewer  int
+		wantErr error
+	}{
+		{name: "not a follower", viewer: stranger.ID, wantErr: domain.ErrNotFollowing},
+		{name: "follower", viewer: boris.ID},
+		{name: "same follower again", viewer: boris.ID},
+		{name: "reserved by another follower", viewer: vera.ID, wantErr: domain.ErrExists},
+	}
+	// the cases share the storage and run in order
+	for _, tt := range tests {
+		got, err := s.ReserveWishItem(tt.viewer, anna.ID, item.ID, time.Now())
+		if !errors.Is(err, tt.wantErr) {
+			t.Fatalf("%s: ReserveWishItem() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if err == nil && !got.ReservedByMe {
+			t.Errorf("%s: ReserveWishItem() = %+v, want reserved by the viewer", tt.name, got)
+		}
+	}
+
+	// the owner never learns about the reservation
+	owned, err := s.GetWishlist(anna.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(owned) != 1 || owned[0].Reserved || owned[0].ReservedByMe {
+		t.Errorf("GetWishlist() = %+v, want the item without the reservation", owned)
+	}
+
+	followed, err := s.GetFollowerWishlist(vera.ID, anna.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(followed) != 1 || !followed[0].Reserved || followed[0].ReservedByMe {
+		t.Errorf("GetFollowerWishlist() = %+v, want reserved by somebody else", followed)
+	}
+
+	if _, err := s.ReleaseWishItem(vera.ID, anna.ID, item.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("ReleaseWishItem() by another follower error = %v, want %v", err, domain.ErrNotFound)
+	}
+	if _, err := s.ReleaseWishItem(boris.ID, anna.ID, item.ID); err != nil {
+		t.Errorf("ReleaseWishItem() error = %v", err)
+	}
+}
+
+func TestFollowEndReleasesReservations(t *testing.T) {
+	tests := []struct {
+		name string
+		// end stops boris from following anna.
+		end func(s *Storage, anna, boris domain.User) error
+	}{
+		{
+			name: "unsubscribe",
+			end:  func(s *Storage, anna, boris domain.User) error { return s.Unsubscribe(boris.Email, anna.ID) },
+		},
+		{
+			name: "follower removed",
+			end:  func(s *Storage, anna, boris domain.User) error { return s.RemoveFollower(anna.Email, boris.ID) },
+		},
+		{
+			name: "follower blocked",
+			end:  func(s *Storage, anna, boris domain.User) error { return s.Block(anna.Email, boris.ID) },
+		},
+		{
+			name: "follower left",
+			end: func(s *Storage, _, boris domain.User) error {
+				_, err := s.DeactivateUser(boris.ID)
+				return err
+			},
+		},
+		{
+			name: "team unsubscribed",
+			end: func(s *Storage, anna, boris domain.User) error {
+				if err := s.Unsubscribe(boris.Email, anna.ID); err != nil {
+					return err
+				}
+				department := "Ops"
+				if _, err := s.UpdateUser(anna.ID, domain.AdminSettingsRequest{Department: &department}); err != nil {
+					return err
+				}
+				team := teamID(t, s, department)
+				if err := s.SubscribeTeam(boris.Email, team, 0); err != nil {
+					return err
+				}
+				return s.UnsubscribeTeam(boris.Email, team)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStorage()
+			anna := addUser(t, s, "anna@corp.ru")
+			boris := addUser(t, s, "boris@corp.ru")
+			vera := addUser(t, s, "vera@corp.ru")
+			for _, follower := range []domain.User{boris, vera} {
+				if err := s.Subscribe(follower.ID, anna.ID, 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+			items := make([]int, 0)
+			for _, title := range []string{"Book", "Mug"} {
+				item, err := s.AddWishItem(anna.ID, domain.WishItem{Title: title, Priority: domain.PriorityMedium}, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				items = append(items, item.ID)
+			}
+			if _, err := s.ReserveWishItem(boris.ID, anna.ID, items[0], time.Now()); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.ReserveWishItem(vera.ID, anna.ID, items[1], time.Now()); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.end(s, anna, boris); err != nil {
+				t.Fatal(err)
+			}
+
+			wishlist, err := s.GetFollowerWishlist(vera.ID, anna.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, item := range wishlist {
+				// only the reservation of the follower who stayed is kept
+				if item.Reserved != item.ReservedByMe {
+					t.Errorf("item %+v is still reserved by boris", item)
+				}
+			}
+			if _, err := s.ReserveWishItem(vera.ID, anna.ID, items[0], time.Now()); err != nil {
+				t.Errorf("ReserveWishItem() of the released item error = %v", err)
+			}
+		})
+	}
+}
+
+func TestDeactivateUserReleasesAllReservations(t *testing.T) {
+	s := NewStorage()
+	anna := addUser(t, s, "anna@corp.ru")
+	boris := addUser(t, s, "boris@corp.ru")
+	vera := addUser(t, s, "vera@corp.ru")
+	for _, owner := range []domain.User{anna, boris} {
+		if err := s.Subscribe(vera.ID, owner.ID, 0); err != nil {
+			t.Fatal(err)
+		}
+		item, err := s.AddWishItem(owner.ID, domain.WishItem{Title: "Book", Priority: domain.PriorityMedium}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.ReserveWishItem(vera.ID, owner.ID, item.ID, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := s.DeactivateUser(vera.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, user := range s.users {
+		for _, item := range user.Wishlist {
+			if item.ReservedBy != 0 {
+				t.Errorf("item %+v of %s is still reserved", item, user.Email)
+			}
+		}
+	}
+}
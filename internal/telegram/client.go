@@ -0,0 +1,140 @@
+// Package telegram is a client of the Telegram Bot API. Servers that
+// implement the same methods work through the base URL, like a local
+// stand-in for tests.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/krevetkou/test-rutube/internal/domain"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const DefaultBaseURL = "https://api.telegram.org"
+
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+type response struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+}
+
+type update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *message `json:"message"`
+}
+
+type message struct {
+	Chat struct {
+		ID   int64  `json:"id"`
+		Type string `json:"type"`
+	} `json:"chat"`
+	Text string `json:"text"`
+}
+
+func NewClient(baseURL, token string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		// the timeouts come from the contexts, long polling holds the request
+		httpClient: &http.Client{},
+	}
+}
+
+// GetUpdates waits up to timeout for the messages after offset, the updates
+// before offset are confirmed and not sent again.
+func (c *Client) GetUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]domain.BotUpdate, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout+time.Second*10)
+	defer cancel()
+
+	var updates []update
+	err := c.call(ctx, "getUpdates", map[string]any{
+		"offset":          offset,
+		"timeout":         int(timeout.Seconds()),
+		"allowed_updates": []string{"message"},
+	}, &updates)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]domain.BotUpdate, 0, len(updates))
+	for _, u := range updates {
+		update := domain.BotUpdate{ID: u.UpdateID}
+		if u.Message != nil {
+			update.ChatID = u.Message.Chat.ID
+			update.Private = u.Message.Chat.Type == "private"
+			update.Text = u.Message.Text
+		}
+		result = append(result, update)
+	}
+
+	return result, nil
+}
+
+func (c *Client) SendMessage(ctx context.Context, chatID int64, text string) error {
+	return c.call(ctx, "sendMessage", map[string]any{
+		"chat_id": chatID,
+		"text":    text,
+	}, nil)
+}
+
+func (c *Client) call(ctx context.Context, method string, params any, result any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/bot"+c.token+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("telegram %s: %w", method, withoutURL(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram %s: %w", method, withoutURL(err))
+	}
+	defer resp.Body.Close()
+
+	var r response
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	if err != nil {
+		return fmt.Errorf("telegram %s: status %d: %w", method, resp.StatusCode, err)
+	}
+
+	if !r.OK {
+		return fmt.Errorf("telegram %s: %d %s", method, r.ErrorCode, r.Description)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(r.Result, result)
+}
+
+// withoutURL keeps the bot token, which is a part of the URL, out of the logs.
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+
+	return err
+}
@@ -0,0 +1,47 @@
+// Package websocket holds the rules the API applies to the websocket
+// handshakes, the connections themselves are served by
+// github.com/coder/websocket.
+package websocket
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Protocols returns the subprotocols the client offered.
+func Protocols(r *http.Request) []string {
+	protocols := make([]string, 0)
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				protocols = append(protocols, v)
+			}
+		}
+	}
+
+	return protocols
+}
+
+// CheckOrigin reports whether the page that opens the connection may use it.
+// Clients without the Origin header aren't browsers and pass, pages of the
+// same host and of the allowed origins pass too.
+func CheckOrigin(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	return slices.ContainsFunc(allowed, func(a string) bool {
+		return strings.EqualFold(strings.TrimSuffix(a, "/"), origin)
+	})
+}
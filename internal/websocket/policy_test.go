@@ -0,0 +1,63 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestProtocols(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   []string
+	}{
+		{name: "none", want: []string{}},
+		{name: "one header", values: []string{"chat, birthday-dashboard"}, want: []string{"chat", "birthday-dashboard"}},
+		{name: "several headers", values: []string{"chat", " pat_abc ,"}, want: []string{"chat", "pat_abc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+			for _, v := range tt.values {
+				r.Header.Add("Sec-WebSocket-Protocol", v)
+			}
+			if got := Protocols(r); !slices.Equal(got, tt.want) {
+				t.Errorf("Protocols() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	allowed := []string{"https://intranet.corp.ru/", "http://localhost:3000"}
+
+	tests := []struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{name: "no origin", origin: "", want: true},
+		{name: "same host", origin: "https://birthdays.corp.ru", want: true},
+		{name: "same host in other case", origin: "https://Birthdays.Corp.RU", want: true},
+		{name: "allowed", origin: "https://intranet.corp.ru", want: true},
+		{name: "allowed with a port", origin: "http://localhost:3000", want: true},
+		{name: "other port", origin: "http://localhost:4000", want: false},
+		{name: "other scheme", origin: "http://intranet.corp.ru", want: false},
+		{name: "other site", origin: "https://evil.example", want: false},
+		{name: "suffix of the host", origin: "https://birthdays.corp.ru.evil.example", want: false},
+		{name: "invalid", origin: "://%zz", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "https://birthdays.corp.ru/ws", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := CheckOrigin(r, allowed); got != tt.want {
+				t.Errorf("CheckOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}